@@ -1655,6 +1655,12 @@ func main() {
 	// path for local extra link data
 	smmn := ""
 
+	// flag for schema-driven indexing of XML records
+	e2ix := false
+
+	// index configuration file with field names, paths, and tokenizers
+	schm := ""
+
 	// flag for inverted index
 	nvrt := false
 
@@ -1673,6 +1679,19 @@ func main() {
 	// fields for promoting inverted index files
 	fild := ""
 
+	// convert existing postings files to packed format
+	pack := false
+
+	// build term dictionaries for leading and internal wildcards
+	kgrm := false
+
+	// postings directory for MeSH thesaurus used by [EXPN] queries
+	thes := ""
+
+	// identifier type and position width for promoted postings files
+	idtp := ""
+	psbt := ""
+
 	// base for queries
 	base := ""
 
@@ -1682,12 +1701,24 @@ func main() {
 	xact := false
 	titl := false
 	mock := false
+	xpln := false
 	btch := false
+	fcts := ""
+
+	// saved search results for "#n" references
+	hist := false
+	noHist := false
+
+	// matching word positions, and snippets from archived records
+	hits := false
+	hlgt := ""
+	asXML := false
 
 	// print term list with counts
 	trms := ""
 	plrl := false
 	psns := false
+	sugg := false
 
 	// use gzip compression on local data files
 	zipp := false
@@ -1795,6 +1826,15 @@ func main() {
 			indx = eutils.GetStringArg(args, "Index element")
 			args = args[1:]
 
+		// create IdxDocument records from XML using index schema
+		case "-e2index":
+			e2ix = true
+
+		// index configuration file
+		case "-schema":
+			schm = eutils.GetStringArg(args, "Index schema file")
+			args = args[1:]
+
 		// build inverted index
 		case "-invert":
 			nvrt = true
@@ -1822,6 +1862,50 @@ func main() {
 			// skip past first and second arguments
 			args = args[2:]
 
+		// convert postings files to compressed format with skip pointers
+		case "-repack":
+			if len(args) < 3 {
+				fmt.Fprintf(os.Stderr, "\nERROR: Repack path is missing\n")
+				os.Exit(1)
+			}
+			prom = args[1]
+			fild = args[2]
+			pack = true
+			// skip past first and second arguments
+			args = args[2:]
+
+		// identifier type (int32, int64, string) for promoted postings
+		case "-idtype":
+			idtp = eutils.GetStringArg(args, "Identifier type")
+			args = args[1:]
+
+		// word position width (16 or 32 bits) for promoted postings
+		case "-posbits":
+			psbt = eutils.GetStringArg(args, "Position bits")
+			args = args[1:]
+
+		// create term dictionaries from existing postings files
+		case "-kgrams":
+			if len(args) < 3 {
+				fmt.Fprintf(os.Stderr, "\nERROR: Dictionary path is missing\n")
+				os.Exit(1)
+			}
+			prom = args[1]
+			fild = args[2]
+			kgrm = true
+			// skip past first and second arguments
+			args = args[2:]
+
+		// save entry terms and tree numbers from MeSH descriptor records
+		case "-thesaurus":
+			thes = eutils.GetStringArg(args, "Thesaurus path")
+			args = args[1:]
+
+		// maximum number of terms matched by one wildcard
+		case "-wildlimit":
+			eutils.SetWildcardLimit(eutils.GetNumericArg(args, "Wildcard expansion limit", 1000, 1, 10000000))
+			args = args[1:]
+
 		case "-path":
 			base = eutils.GetStringArg(args, "Postings path")
 			args = args[1:]
@@ -1845,6 +1929,34 @@ func main() {
 		case "-batch":
 			btch = true
 
+		// show normalized query, counts for each node, and wildcard expansions
+		case "-explain":
+			xpln = true
+
+		// list saved queries, or skip saving the current result
+		case "-history":
+			hist = true
+		case "-nohistory":
+			noHist = true
+
+		// report matching word positions, optionally with highlighted text from the archive
+		case "-hits":
+			hits = true
+		case "-highlight":
+			hits = true
+			hlgt = eutils.GetStringArg(args, "Highlight archive path")
+			if hlgt != "" && !strings.HasSuffix(hlgt, "/") {
+				hlgt += "/"
+			}
+			args = args[1:]
+		case "-xml":
+			asXML = true
+
+		// count query results by value of each facet field
+		case "-facets":
+			fcts = eutils.GetStringArg(args, "Facet fields")
+			args = args[1:]
+
 		case "-mockt":
 			titl = true
 			fallthrough
@@ -1879,6 +1991,12 @@ func main() {
 			trms = eutils.GetStringArg(args, "Count argument")
 			args = args[1:]
 
+		// suggest more frequent terms with similar spelling
+		case "-suggest":
+			sugg = true
+			trms = eutils.GetStringArg(args, "Suggest argument")
+			args = args[1:]
+
 		case "-gzip":
 			zipp = true
 		case "-hash":
@@ -2040,6 +2158,15 @@ func main() {
 
 	eutils.SetOptions(doStrict, doMixed, doSelf, deAccent, doASCII, doCompress, doCleanup)
 
+	// index schema for -e2index, -invert, and -promote
+	var schema *eutils.IndexSchema
+	if schm != "" {
+		schema = eutils.LoadIndexSchema(schm)
+		if schema == nil {
+			os.Exit(1)
+		}
+	}
+
 	// -stats prints number of CPUs and performance tuning values if no other arguments (undocumented)
 	if stts && len(args) < 1 {
 
@@ -2071,6 +2198,18 @@ func main() {
 		}
 	}
 
+	// expand -highlight ~/ to home directory path
+	if hlgt != "" {
+
+		if hlgt[:2] == "~/" {
+			cur, err := user.Current()
+			if err == nil {
+				hom := cur.HomeDir
+				hlgt = strings.Replace(hlgt, "~/", hom+"/", 1)
+			}
+		}
+	}
+
 	// expand -fetch ~/ to home directory path
 	if ftch != "" {
 
@@ -2561,7 +2700,54 @@ func main() {
 
 	if prom != "" && fild != "" {
 
-		prmq := eutils.CreatePromoters(prom, fild, args)
+		var prmq <-chan string
+
+		if schema != nil {
+			// promoted fields must be defined in the schema, which is saved for phrase search
+			for _, fld := range strings.Fields(fild) {
+				if schema.Field(fld) == nil {
+					fmt.Fprintf(os.Stderr, "\nERROR: Field '%s' is not in index schema\n", fld)
+					os.Exit(1)
+				}
+			}
+			if !eutils.WriteIndexSchema(prom, schema) {
+				os.Exit(1)
+			}
+			// identifier type and position width from the schema, unless given on the command line
+			if idtp == "" {
+				idtp = schema.IDType
+			}
+			if psbt == "" {
+				psbt = schema.PosBits
+			}
+		}
+
+		if idtp != "" || psbt != "" {
+			hdr, ok := eutils.ParsePostingsHeader(idtp, psbt)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized -idtype '%s' or -posbits '%s'\n", idtp, psbt)
+				os.Exit(1)
+			}
+			if !eutils.WritePostingsHeader(prom, hdr) {
+				os.Exit(1)
+			}
+		}
+
+		if kgrm {
+			for _, fld := range strings.Fields(fild) {
+				if !eutils.WriteTermDictionary(prom, fld) {
+					fmt.Fprintf(os.Stderr, "\nERROR: Unable to create term dictionary for %s\n", fld)
+					os.Exit(1)
+				}
+			}
+			return
+		}
+
+		if pack {
+			prmq = eutils.CreatePackers(prom, fild)
+		} else {
+			prmq = eutils.CreatePromoters(prom, fild, args)
+		}
 
 		if prmq == nil {
 			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create new postings file generator\n")
@@ -2593,6 +2779,16 @@ func main() {
 			fmt.Fprintf(os.Stdout, "\n")
 		}
 
+		if !pack {
+			// vocabulary of each promoted field supports leading and internal wildcards
+			for _, fld := range strings.Fields(fild) {
+				if !eutils.WriteTermDictionary(prom, fld) {
+					fmt.Fprintf(os.Stderr, "\nERROR: Unable to create term dictionary for %s\n", fld)
+					os.Exit(1)
+				}
+			}
+		}
+
 		debug.FreeOSMemory()
 
 		if timr {
@@ -2604,7 +2800,7 @@ func main() {
 
 	// QUERY POSTINGS FILES
 
-	if phrs != "" || trms != "" || btch {
+	if phrs != "" || trms != "" || btch || hist {
 		if base == "" {
 			// obtain path from environment variable within rchive as a convenience
 			base = os.Getenv("EDIRECT_PUBMED_MASTER")
@@ -2615,6 +2811,26 @@ func main() {
 				base += "Postings"
 			}
 		}
+
+		// history folder can be moved with an environment variable
+		hdir := os.Getenv("EDIRECT_HISTORY")
+		if hdir == "" {
+			home, err := os.UserHomeDir()
+			if err == nil {
+				hdir = filepath.Join(home, ".edirect", "history")
+			}
+		}
+
+		// only interactive -query results are saved, not batch, -exact, -title, or [PIPE] filter searches
+		record := phrs != "" && !btch && !xact && !noHist && !strings.Contains(phrs, "[PIPE]")
+		eutils.SetQueryHistory(hdir, record)
+	}
+
+	if base != "" && hist {
+
+		recordCount = eutils.ProcessHistory(base)
+
+		return
 	}
 
 	if base != "" && btch {
@@ -2643,6 +2859,12 @@ func main() {
 		// deStop should match value used in building the indices
 		if mock {
 			recordCount = eutils.ProcessMock(base, phrs, xact, titl, rlxd, deStop)
+		} else if xpln {
+			recordCount = eutils.ProcessExplain(base, phrs, xact, titl, rlxd, deStop)
+		} else if hits {
+			recordCount = eutils.ProcessHighlight(base, phrs, hlgt, asXML, xact, titl, rlxd, deStop)
+		} else if fcts != "" {
+			recordCount = eutils.ProcessFacets(base, phrs, fcts, xact, titl, rlxd, deStop)
 		} else {
 			recordCount = eutils.ProcessSearch(base, phrs, xact, titl, rlxd, deStop)
 		}
@@ -2659,7 +2881,11 @@ func main() {
 	if base != "" && trms != "" {
 
 		// deStop should match value used in building the indices
-		recordCount = eutils.ProcessCount(base, trms, plrl, psns, rlxd, deStop)
+		if sugg {
+			recordCount = eutils.ProcessSuggest(base, trms, rlxd, deStop)
+		} else {
+			recordCount = eutils.ProcessCount(base, trms, plrl, psns, rlxd, deStop)
+		}
 
 		debug.FreeOSMemory()
 
@@ -3156,6 +3382,72 @@ func main() {
 		os.Exit(1)
 	}
 
+	// MESH THESAURUS
+
+	// -thesaurus reads MeSH DescriptorRecord XML and saves synonyms and tree numbers for [EXPN] queries
+	if thes != "" {
+
+		colq := eutils.CreateXMLProducer("DescriptorRecord", "", false, rdr)
+
+		if !eutils.WriteThesaurus(thes, colq) {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create thesaurus\n")
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	// SCHEMA-DRIVEN ENTREZ INDEXING
+
+	// -e2index reads XML records and creates IdxDocumentSet XML from schema field definitions
+	if e2ix {
+
+		if schema == nil {
+			schema = eutils.DefaultIndexSchema()
+		}
+
+		colq := eutils.CreateXMLProducer(schema.Pattern, "", false, rdr)
+		idxq := eutils.CreateSchemaIndexers(schema, colq)
+		unsq := eutils.CreateXMLUnshuffler(idxq)
+
+		if colq == nil || idxq == nil || unsq == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create schema indexer\n")
+			os.Exit(1)
+		}
+
+		wrtr := bufio.NewWriter(os.Stdout)
+
+		wrtr.WriteString("<IdxDocumentSet>\n")
+
+		// drain output channel
+		for curr := range unsq {
+
+			str := curr.Text
+
+			if str == "" {
+				continue
+			}
+
+			wrtr.WriteString(str)
+			wrtr.WriteString("\n")
+
+			recordCount++
+			runtime.Gosched()
+		}
+
+		wrtr.WriteString("</IdxDocumentSet>\n")
+
+		wrtr.Flush()
+
+		debug.FreeOSMemory()
+
+		if timr {
+			printDuration("records")
+		}
+
+		return
+	}
+
 	// ENTREZ INDEX INVERSION
 
 	// -invert reads IdxDocumentSet XML and creates an inverted index
@@ -3189,7 +3481,7 @@ func main() {
 		}
 
 		colq := eutils.CreateXMLProducer("IdxDocument", "", false, rdr)
-		dspq := eutils.CreateDispensers(colq)
+		dspq := eutils.CreateDispensers(schema, colq)
 		invq := eutils.CreateInverters(dspq)
 		rslq := eutils.CreateResolver(invq)
 
@@ -31,6 +31,7 @@
 package main
 
 import (
+	"bufio"
 	"encoding/base64"
 	"eutils"
 	"fmt"
@@ -1235,6 +1236,11 @@ func sequenceSearch(inp io.Reader, args []string) {
 	circular := false
 	topStrand := false
 
+	// approximate matching allows substitutions, or insertions and deletions
+	approx := false
+	indels := false
+	maxErrors := 0
+
 	for len(args) > 0 {
 		if args[0] == "-protein" {
 			protein = true
@@ -1245,6 +1251,16 @@ func sequenceSearch(inp io.Reader, args []string) {
 		} else if args[0] == "-top" {
 			topStrand = true
 			args = args[1:]
+		} else if args[0] == "-mismatch" || args[0] == "-mismatches" {
+			maxErrors = eutils.GetNumericArg(args, "Maximum number of mismatches", 0, 0, 32)
+			approx = true
+			indels = false
+			args = args[2:]
+		} else if args[0] == "-edit" || args[0] == "-edits" {
+			maxErrors = eutils.GetNumericArg(args, "Maximum edit distance", 0, 0, 32)
+			approx = true
+			indels = true
+			args = args[2:]
 		} else {
 			break
 		}
@@ -1271,13 +1287,23 @@ func sequenceSearch(inp io.Reader, args []string) {
 
 	str := readOneFastaSequence(inp)
 
-	srch := eutils.SequenceSearcher(arry, protein, circular, topStrand)
+	var srch *eutils.Searcher
+	if approx {
+		srch = eutils.ApproximateSearcher(arry, protein, circular, topStrand, maxErrors, indels)
+	} else {
+		srch = eutils.SequenceSearcher(arry, protein, circular, topStrand)
+	}
 
 	res := srch.Search(str)
 
 	txt := ""
 	for _, hit := range res {
-		txt = fmt.Sprintf("%d\t%s\n", hit.Point, hit.Match)
+		if approx {
+			// approximate match also reports strand and number of mismatches or edits
+			txt = fmt.Sprintf("%d\t%s\t%s\t%d\n", hit.Point, hit.Match, hit.Strand, hit.Mismatches)
+		} else {
+			txt = fmt.Sprintf("%d\t%s\n", hit.Point, hit.Match)
+		}
 		os.Stdout.WriteString(txt)
 	}
 	if !strings.HasSuffix(txt, "\n") {
@@ -1285,6 +1311,210 @@ func sequenceSearch(inp io.Reader, args []string) {
 	}
 }
 
+// motifScan reports PROSITE pattern or regular expression matches in each protein sequence
+func motifScan(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	// skip past command name
+	args = args[1:]
+
+	isRegex := false
+	library := ""
+
+	for len(args) > 0 {
+		if args[0] == "-regex" || args[0] == "-regexp" {
+			isRegex = true
+			args = args[1:]
+		} else if args[0] == "-library" || args[0] == "-motifs" {
+			library = eutils.GetStringArg(args, "Motif library file name")
+			args = args[2:]
+		} else {
+			break
+		}
+	}
+
+	var motifs []eutils.Motif
+
+	if library != "" {
+		f, err := os.Open(library)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to open motif library '%s'\n", library)
+			os.Exit(1)
+		}
+		motifs = append(motifs, eutils.ReadMotifLibrary(f)...)
+		f.Close()
+	}
+
+	// each PROSITE pattern can optionally be followed by a colon and a name, e.g., "N-{P}-[ST]-{P}:glyc"
+	for _, pat := range args {
+		pat = strings.TrimSpace(pat)
+		if pat == "" {
+			continue
+		}
+		if isRegex {
+			motifs = append(motifs, eutils.Motif{Name: pat, Pattern: pat, IsRegex: true})
+			continue
+		}
+		txt, name := eutils.SplitInTwoLeft(pat, ":")
+		if name == "" {
+			name = txt
+		}
+		motifs = append(motifs, eutils.Motif{Name: name, Pattern: txt})
+	}
+
+	// default to bundled PROSITE library
+	if library == "" && len(motifs) < 1 {
+		motifs = eutils.DefaultMotifs()
+	}
+
+	srch := eutils.MotifSearcher(motifs)
+	if srch == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: No valid motifs supplied to -motif command\n")
+		os.Exit(1)
+	}
+
+	fsta := eutils.FASTAConverter(inp, false)
+
+	// print hit table with 1-based positions
+	for fsa := range fsta {
+
+		seq := fsa.Sequence
+
+		res := srch.Search(seq)
+
+		for _, hit := range res {
+			from := hit.Point
+			to := hit.Point + hit.Length
+			fmt.Fprintf(os.Stdout, "%s\t%d\t%d\t%s\t%s\n", fsa.SeqID, from+1, to, hit.Match, strings.ToUpper(seq[from:to]))
+		}
+	}
+}
+
+// restrictionDigest lists cut positions and fragment sizes for each enzyme and for the combined digest
+func restrictionDigest(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	// skip past command name
+	args = args[1:]
+
+	circular := false
+	unique := false
+	table := ""
+
+	for len(args) > 0 {
+		if args[0] == "-circular" {
+			circular = true
+			args = args[1:]
+		} else if args[0] == "-unique" {
+			unique = true
+			args = args[1:]
+		} else if args[0] == "-enzymes" || args[0] == "-rebase" {
+			table = eutils.GetStringArg(args, "Enzyme table file name")
+			args = args[2:]
+		} else if strings.HasPrefix(args[0], "-") {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -digest command\n")
+			os.Exit(1)
+		} else {
+			break
+		}
+	}
+
+	var enzymes []eutils.Enzyme
+
+	if table != "" {
+		f, err := os.Open(table)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to open enzyme table '%s'\n", table)
+			os.Exit(1)
+		}
+		enzymes = eutils.ReadEnzymeTable(f)
+		f.Close()
+	} else {
+		enzymes = eutils.DefaultEnzymes()
+	}
+
+	// remaining arguments select enzymes by name, otherwise report every enzyme that cuts
+	if len(args) > 0 {
+		byName := make(map[string]eutils.Enzyme)
+		for _, enz := range enzymes {
+			byName[strings.ToLower(enz.Name)] = enz
+		}
+		var selected []eutils.Enzyme
+		for _, name := range args {
+			enz, ok := byName[strings.ToLower(name)]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "\nERROR: Enzyme '%s' not found in table\n", name)
+				os.Exit(1)
+			}
+			selected = append(selected, enz)
+		}
+		enzymes = selected
+	}
+
+	explicit := len(args) > 0
+
+	joinInts := func(vals []int) string {
+		if len(vals) < 1 {
+			return "-"
+		}
+		strs := make([]string, len(vals))
+		for i, val := range vals {
+			strs[i] = strconv.Itoa(val)
+		}
+		return strings.Join(strs, ",")
+	}
+
+	fsta := eutils.FASTAConverter(inp, false)
+
+	for fsa := range fsta {
+
+		seq := fsa.Sequence
+		seqlen := len(seq)
+
+		var combined []int
+		var names []string
+
+		for _, enz := range enzymes {
+
+			cuts := eutils.DigestCuts(seq, enz, circular)
+
+			if len(cuts) < 1 && !explicit {
+				continue
+			}
+			if unique && len(cuts) != 1 {
+				continue
+			}
+
+			frags := eutils.DigestFragments(cuts, seqlen, circular)
+
+			fmt.Fprintf(os.Stdout, "%s\t%s\t%s\t%d\t%s\t%s\n", fsa.SeqID, enz.Name, enz.Site, len(cuts), joinInts(cuts), joinInts(frags))
+
+			combined = append(combined, cuts...)
+			names = append(names, enz.Name)
+		}
+
+		// multi-enzyme digest
+		if explicit && len(names) > 1 {
+			frags := eutils.DigestFragments(combined, seqlen, circular)
+			// remove positions shared by more than one enzyme
+			sort.Ints(combined)
+			var cuts []int
+			for i, pos := range combined {
+				if i == 0 || pos != combined[i-1] {
+					cuts = append(cuts, pos)
+				}
+			}
+			fmt.Fprintf(os.Stdout, "%s\t%s\t%s\t%d\t%s\t%s\n", fsa.SeqID, strings.Join(names, "+"), "-", len(cuts), joinInts(cuts), joinInts(frags))
+		}
+	}
+}
+
 func readAllIntoString(inp io.Reader) string {
 
 	if inp == nil {
@@ -1426,6 +1656,275 @@ func baseCount(inp io.Reader) {
 	}
 }
 
+// SMALL MOLECULE PROPERTIES
+
+// smilesProperties reads identifier and SMILES columns, printing formula, masses, and structural counts
+func smilesProperties(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	header := false
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-header", "-heading":
+			header = true
+			args = args[1:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -smiles command\n")
+			os.Exit(1)
+		}
+	}
+
+	if header {
+		fmt.Fprintf(os.Stdout, "ID\tFormula\tAverage\tMonoisotopic\tHeavy\tRings\tRotatable\n")
+	}
+
+	scanr := bufio.NewScanner(inp)
+	scanr.Buffer(make([]byte, 0, 65536), 1048576)
+
+	row := 0
+	for scanr.Scan() {
+
+		line := strings.TrimSpace(scanr.Text())
+		row++
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// accept ID<tab>SMILES, or a lone SMILES column numbered by row
+		id, smi := eutils.SplitInTwoLeft(line, "\t")
+		if smi == "" {
+			smi = id
+			id = strconv.Itoa(row)
+		}
+		smi = strings.TrimSpace(smi)
+		// drop trailing name field of SMILES file format
+		if pos := strings.IndexAny(smi, " \t"); pos > 0 {
+			smi = smi[:pos]
+		}
+
+		mol := eutils.ParseSMILES(smi)
+		if mol == nil {
+			continue
+		}
+
+		avg, mono := mol.Masses()
+
+		fmt.Fprintf(os.Stdout, "%s\t%s\t%.3f\t%.6f\t%d\t%d\t%d\n", id, mol.Formula(), avg, mono,
+			mol.HeavyAtoms(), mol.Rings(), mol.RotatableBonds())
+	}
+}
+
+// SLIDING WINDOW STATISTICS
+
+// isProteinSequence guesses molecule type, allowing occasional ambiguity codes in nucleotides
+func isProteinSequence(seq string) bool {
+
+	if seq == "" {
+		return false
+	}
+
+	nuc := 0
+	for _, ch := range strings.ToUpper(seq) {
+		if strings.ContainsRune("ACGTUN-", ch) {
+			nuc++
+		}
+	}
+
+	return nuc*10 < len(seq)*9
+}
+
+// windowStats prints GC content, GC skew, and CpG observed/expected for nucleotides,
+// or Kyte-Doolittle hydropathy for proteins, in each window of every FASTA sequence
+func windowStats(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	size := 0
+	step := 0
+	molType := ""
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-window", "-size":
+			size = eutils.GetNumericArg(args, "window size", 0, 1, 0)
+			args = args[2:]
+		case "-step", "-shift":
+			step = eutils.GetNumericArg(args, "window step", 0, 1, 0)
+			args = args[2:]
+		case "-nuc", "-nucleotide":
+			molType = "nuc"
+			args = args[1:]
+		case "-prot", "-protein":
+			molType = "prot"
+			args = args[1:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -windows command\n")
+			os.Exit(1)
+		}
+	}
+
+	fsta := eutils.FASTAConverter(inp, false)
+
+	for fsa := range fsta {
+
+		isProt := (molType == "prot")
+		if molType == "" {
+			isProt = isProteinSequence(fsa.Sequence)
+		}
+
+		if isProt {
+			sz := size
+			if sz < 1 {
+				sz = 9
+			}
+			st := step
+			if st < 1 {
+				st = 1
+			}
+			for _, ws := range eutils.HydropathyWindows(fsa.Sequence, sz, st) {
+				fmt.Fprintf(os.Stdout, "%s\t%d\t%d\t%.3f\n", fsa.SeqID, ws.Start, ws.End, ws.Hydropathy)
+			}
+			continue
+		}
+
+		sz := size
+		if sz < 1 {
+			sz = 100
+		}
+		st := step
+		if st < 1 {
+			st = sz
+		}
+		for _, ws := range eutils.NucleotideWindows(fsa.Sequence, sz, st) {
+			fmt.Fprintf(os.Stdout, "%s\t%d\t%d\t%.4f\t%.4f\t%.4f\n", fsa.SeqID, ws.Start, ws.End, ws.GC, ws.GCSkew, ws.CpGRatio)
+		}
+	}
+}
+
+// lowComplexityMask applies DUST to nucleotides or SEG to proteins, printing soft-masked or hard-masked FASTA,
+// or a table of masked intervals
+func lowComplexityMask(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	method := ""
+	window := 0
+	hard := false
+	table := false
+	dustLevel := 20.0
+	locut := 2.2
+	hicut := 2.5
+
+	getFloat := func(args []string, name string) float64 {
+		str := eutils.GetStringArg(args, name)
+		val, err := strconv.ParseFloat(str, 64)
+		if err != nil || val < 0 {
+			fmt.Fprintf(os.Stderr, "\nERROR: %s '%s' is not a positive number\n", name, str)
+			os.Exit(1)
+		}
+		return val
+	}
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-dust":
+			method = "dust"
+			args = args[1:]
+		case "-seg":
+			method = "seg"
+			args = args[1:]
+		case "-window":
+			window = eutils.GetNumericArg(args, "window size", 0, 1, 0)
+			args = args[2:]
+		case "-level":
+			dustLevel = getFloat(args, "DUST level")
+			args = args[2:]
+		case "-locut":
+			locut = getFloat(args, "SEG trigger complexity")
+			args = args[2:]
+		case "-hicut":
+			hicut = getFloat(args, "SEG extension complexity")
+			args = args[2:]
+		case "-hard":
+			hard = true
+			args = args[1:]
+		case "-table", "-intervals":
+			table = true
+			args = args[1:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -mask command\n")
+			os.Exit(1)
+		}
+	}
+
+	fsta := eutils.FASTAConverter(inp, false)
+
+	for fsa := range fsta {
+
+		isProt := (method == "seg")
+		if method == "" {
+			isProt = isProteinSequence(fsa.Sequence)
+		}
+
+		var ivals [][2]int
+		letter := byte(0)
+
+		if isProt {
+			ivals = eutils.SegIntervals(fsa.Sequence, window, locut, hicut)
+			if hard {
+				letter = 'X'
+			}
+		} else {
+			ivals = eutils.DustIntervals(fsa.Sequence, window, dustLevel)
+			if hard {
+				letter = 'N'
+			}
+		}
+
+		if table {
+			for _, iv := range ivals {
+				fmt.Fprintf(os.Stdout, "%s\t%d\t%d\n", fsa.SeqID, iv[0]+1, iv[1])
+			}
+			continue
+		}
+
+		str := eutils.MaskIntervals(fsa.Sequence, ivals, letter)
+
+		os.Stdout.WriteString(">" + fsa.SeqID)
+		if fsa.Title != "" {
+			os.Stdout.WriteString(" " + fsa.Title)
+		}
+		os.Stdout.WriteString("\n")
+		for i := 0; i < len(str); i += 60 {
+			j := i + 60
+			if j > len(str) {
+				j = len(str)
+			}
+			os.Stdout.WriteString(str[i:j] + "\n")
+		}
+	}
+}
+
 // REVERSE SEQUENCE
 
 // seqFlip reverses without complementing - e.g., minus strand proteins translated in reverse order
@@ -1526,110 +2025,889 @@ func printFastaPairs(frst, scnd string) {
 		ch := scd[0]
 		scd = scd[1:]
 
-		return rune(ch)
+		return rune(ch)
+	}
+
+	var fs []rune
+	var sc []rune
+	mx := 0
+
+	// populate output arrays
+	for {
+
+		f, s := nextF(), nextS()
+		// if both spaces, end of both sequences
+		if f == ' ' && s == ' ' {
+			break
+		}
+		if f == s {
+			fs = append(fs, f)
+			sc = append(sc, ' ')
+		} else {
+			// show mismatches in upper case
+			fs = append(fs, unicode.ToUpper(f))
+			sc = append(sc, unicode.ToUpper(s))
+		}
+		mx++
+	}
+
+	// pad output to multiple of 50
+	j := mx % 50
+	if j > 0 {
+		for j < 50 {
+			fs = append(fs, ' ')
+			sc = append(sc, ' ')
+			j++
+			mx++
+		}
+	}
+
+	// print in blocks of 50 bases or residues
+	for i := 0; i < mx; i += 50 {
+		dl := 50
+		if mx-i < 50 {
+			dl = mx - i
+		}
+		lf := fs[:dl]
+		rt := sc[:dl]
+		fs = fs[dl:]
+		sc = sc[dl:]
+		tm := strings.TrimRight(string(lf), " ")
+		fmt.Fprintf(os.Stdout, "%s %6d\n%s\n", string(lf), i+len(tm), string(rt))
+	}
+}
+
+func fastaDiff(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	// skip past command name
+	args = args[1:]
+
+	if len(args) != 2 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Two files required by -diff command\n")
+		os.Exit(1)
+	}
+
+	frst := args[0]
+	scnd := args[1]
+
+	readSeqFromFile := func(fname string) string {
+
+		f, err := os.Open(fname)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to open file %s - %s\n", fname, err.Error())
+			os.Exit(1)
+		}
+
+		defer f.Close()
+
+		seq := readOneFastaSequence(f)
+
+		return seq
+	}
+
+	frstFasta := readSeqFromFile(frst)
+	scndFasta := readSeqFromFile(scnd)
+
+	if frstFasta == scndFasta {
+		return
+	}
+
+	// sequences are assumed to be aligned, this code highlight mismatches
+	printFastaPairs(frstFasta, scndFasta)
+}
+
+// VCF CONVERSION
+
+// readReferenceFasta loads all sequences from a FASTA file, indexed by SeqID, by
+// accession without version, and by accession within "ref|NC_000001.11|" style IDs
+func readReferenceFasta(fname string) map[string]string {
+
+	refs := make(map[string]string)
+
+	if fname == "" {
+		return refs
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to open reference file '%s'\n", fname)
+		os.Exit(1)
+	}
+
+	defer f.Close()
+
+	fsta := eutils.FASTAConverter(f, false)
+
+	for fsa := range fsta {
+
+		id := fsa.SeqID
+		refs[id] = fsa.Sequence
+
+		for _, itm := range strings.Split(id, "|") {
+			if itm == "" {
+				continue
+			}
+			if _, ok := refs[itm]; !ok {
+				refs[itm] = fsa.Sequence
+			}
+			acc, _ := eutils.SplitInTwoLeft(itm, ".")
+			if _, ok := refs[acc]; !ok {
+				refs[acc] = fsa.Sequence
+			}
+		}
+	}
+
+	return refs
+}
+
+func lookupReference(refs map[string]string, acc string) string {
+
+	if seq, ok := refs[acc]; ok {
+		return seq
+	}
+
+	unv, _ := eutils.SplitInTwoLeft(acc, ".")
+
+	return refs[unv]
+}
+
+// spdiToVcf reads SPDI lines and writes left-normalized VCF records
+func spdiToVcf(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	reference := ""
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-reference", "-ref":
+			reference = eutils.GetStringArg(args, "Reference FASTA file name")
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -spdi2vcf command\n")
+			os.Exit(1)
+		}
+	}
+
+	if reference == "" {
+		fmt.Fprintf(os.Stderr, "\nERROR: -spdi2vcf requires -reference FASTA file\n")
+		os.Exit(1)
+	}
+
+	refs := readReferenceFasta(reference)
+
+	var recs []eutils.VCFRecord
+	var contigs []string
+	seen := make(map[string]bool)
+
+	scanr := bufio.NewScanner(inp)
+
+	for scanr.Scan() {
+
+		line := strings.TrimSpace(scanr.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		acc, pos, del, ins, ok := eutils.ParseSPDI(line)
+		if !ok {
+			continue
+		}
+
+		seq := lookupReference(refs, acc)
+
+		rec, ok := eutils.SPDIToVCF(seq, acc, pos, del, ins)
+		if !ok {
+			continue
+		}
+
+		if !seen[acc] {
+			seen[acc] = true
+			contigs = append(contigs, acc)
+		}
+
+		recs = append(recs, rec)
+	}
+
+	os.Stdout.WriteString("##fileformat=VCFv4.3\n")
+	for _, acc := range contigs {
+		fmt.Fprintf(os.Stdout, "##contig=<ID=%s,length=%d>\n", acc, len(lookupReference(refs, acc)))
+	}
+	os.Stdout.WriteString("#CHROM\tPOS\tID\tREF\tALT\tQUAL\tFILTER\tINFO\n")
+
+	for _, rec := range recs {
+		fmt.Fprintf(os.Stdout, "%s\t%d\t%s\t%s\t%s\t.\t.\t.\n", rec.Chrom, rec.Pos, rec.ID, rec.Ref, rec.Alt)
+	}
+}
+
+// vcfToSpdi reads VCF data lines and writes one normalized SPDI per alternate allele
+func vcfToSpdi(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	reference := ""
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-reference", "-ref":
+			reference = eutils.GetStringArg(args, "Reference FASTA file name")
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -vcf2spdi command\n")
+			os.Exit(1)
+		}
+	}
+
+	// without reference, alleles are trimmed but cannot be shifted left
+	refs := readReferenceFasta(reference)
+
+	scanr := bufio.NewScanner(inp)
+	scanr.Buffer(make([]byte, 65536), 16777216)
+
+	for scanr.Scan() {
+
+		line := scanr.Text()
+
+		for _, rec := range eutils.ParseVCFLine(line) {
+
+			seq := lookupReference(refs, rec.Chrom)
+
+			spdi, ok := eutils.VCFToSPDI(seq, rec)
+			if !ok {
+				continue
+			}
+
+			os.Stdout.WriteString(spdi)
+			os.Stdout.WriteString("\n")
+		}
+	}
+}
+
+// VARIANT CONSEQUENCE
+
+// variantEffect applies each SPDI or HGVS c. variant to a transcript and reports the protein change
+func variantEffect(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	genCode := 1
+	cdsLoc := ""
+	varFile := ""
+
+	var variants []string
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-code", "-gencode":
+			genCode = eutils.GetNumericArg(args, "genetic code number", 0, 1, 0)
+			args = args[2:]
+		case "-cds":
+			cdsLoc = eutils.GetStringArg(args, "1-based coding region interval")
+			args = args[2:]
+		case "-variants":
+			varFile = eutils.GetStringArg(args, "Variant file name")
+			args = args[2:]
+		default:
+			// remaining arguments are SPDI or HGVS c. variants
+			variants = append(variants, args[0])
+			args = args[1:]
+		}
+	}
+
+	if !eutils.IsGeneticCode(genCode) {
+		fmt.Fprintf(os.Stderr, "\nERROR: Genetic code %d does not exist\n", genCode)
+		os.Exit(1)
+	}
+
+	if varFile != "" {
+		f, err := os.Open(varFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to open variant file '%s'\n", varFile)
+			os.Exit(1)
+		}
+		scanr := bufio.NewScanner(f)
+		for scanr.Scan() {
+			line := strings.TrimSpace(scanr.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				variants = append(variants, line)
+			}
+		}
+		f.Close()
+	}
+
+	if len(variants) < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: No variants supplied to -effect command\n")
+		os.Exit(1)
+	}
+
+	seq := readOneFastaSequence(inp)
+	if seq == "" {
+		fmt.Fprintf(os.Stderr, "\nERROR: No transcript sequence supplied to -effect command\n")
+		os.Exit(1)
+	}
+
+	// default coding region is entire sequence
+	cdsFrom := 0
+	cdsTo := len(seq)
+
+	if cdsLoc != "" {
+		// also allow dash separator, e.g., "51-494"
+		loc := strings.Replace(cdsLoc, "-", "..", -1)
+		fr, to := eutils.SplitInTwoLeft(loc, "..")
+		min, err := strconv.Atoi(strings.TrimSpace(fr))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized -cds start '%s'\n", fr)
+			os.Exit(1)
+		}
+		max, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized -cds stop '%s'\n", to)
+			os.Exit(1)
+		}
+		cdsFrom = min - 1
+		cdsTo = max
+	}
+
+	for _, vrn := range variants {
+
+		pos := 0
+		del := ""
+		ins := ""
+		ok := false
+
+		if strings.Contains(vrn, "c.") {
+			pos, del, ins, ok = eutils.ParseCodingHGVS(vrn, seq, cdsFrom, cdsTo)
+		} else {
+			_, pos, del, ins, ok = eutils.ParseSPDI(vrn)
+			if ok && eutils.IsAllDigits(del) && del != "" {
+				// deletion given as count
+				num, _ := strconv.Atoi(del)
+				if pos+num <= len(seq) {
+					del = seq[pos : pos+num]
+				}
+			}
+		}
+
+		if !ok {
+			continue
+		}
+
+		eff := eutils.PredictVariantEffect(seq, cdsFrom, cdsTo, genCode, pos, del, ins)
+		if eff.Type == "" {
+			continue
+		}
+
+		fmt.Fprintf(os.Stdout, "%s\t%s\t%s\n", vrn, eff.Type, eff.Protein)
+	}
+}
+
+// FEATURE TABLE EXPORT
+
+// insdToGFF writes INSDSeq feature tables as GFF3, GTF, or BED12
+func insdToGFF(rdr <-chan eutils.XMLBlock, args []string) {
+
+	if rdr == nil || args == nil {
+		return
+	}
+
+	format := "gff3"
+
+	switch args[0] {
+	case "-insd2gtf":
+		format = "gtf"
+	case "-insd2bed":
+		format = "bed"
+	}
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+		switch args[0] {
+		case "-gff", "-gff3":
+			format = "gff3"
+		case "-gtf":
+			format = "gtf"
+		case "-bed", "-bed12":
+			format = "bed"
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized -insd2gff option '%s'\n", args[0])
+			os.Exit(1)
+		}
+		args = args[1:]
+	}
+
+	if format == "gff3" {
+		os.Stdout.WriteString("##gff-version 3\n")
+	}
+
+	eutils.PartitionPattern("INSDSeq", "", false, rdr,
+		func(str string) {
+			txt := eutils.INSDSeqToGFF(str, format)
+			if txt != "" {
+				os.Stdout.WriteString(txt)
+			}
+		})
+}
+
+// PROTEIN WEIGHT
+
+func protWeight(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	trimLeadingMet := true
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-met":
+			trimLeadingMet = false
+			args = args[1:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -molwt command\n")
+			os.Exit(1)
+		}
+	}
+
+	str := readOneFastaSequence(inp)
+
+	str = eutils.ProteinWeight(str, trimLeadingMet)
+
+	os.Stdout.WriteString(str)
+	if !strings.HasSuffix(str, "\n") {
+		os.Stdout.WriteString("\n")
+	}
+}
+
+// cdRegionToProtein reads all of stdin as sequence data
+func cdRegionToProtein(inp io.Reader, args []string) {
+
+	if inp == nil {
+		return
+	}
+
+	genCode := 1
+	frame := 0
+	includeStop := false
+	doEveryCodon := false
+	removeTrailingX := false
+	is5primeComplete := true
+	is3primeComplete := true
+	between := ""
+	featLoc := ""
+
+	repeat := 1
+
+	// skip past command name
+	args = args[1:]
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-code", "-gencode":
+			genCode = eutils.GetNumericArg(args, "genetic code number", 0, 1, 0)
+			args = args[2:]
+		case "-frame":
+			frame = eutils.GetNumericArg(args, "offset into coding sequence", 0, 1, 30)
+			args = args[2:]
+		case "-stop", "-stops":
+			includeStop = true
+			args = args[1:]
+		case "-every", "-all":
+			doEveryCodon = true
+			args = args[1:]
+		case "-trim", "-trailing":
+			removeTrailingX = true
+			args = args[1:]
+		case "-part5", "-partial5", "-lt5":
+			is5primeComplete = false
+			args = args[1:]
+		case "-part3", "-partial3", "-gt3":
+			is3primeComplete = false
+			args = args[1:]
+		case "-between":
+			between = eutils.GetStringArg(args, "separator between residues")
+			args = args[2:]
+		case "-loc", "-location":
+			featLoc = eutils.GetStringArg(args, "coding region location")
+			args = args[2:]
+		case "-repeat":
+			repeat = eutils.GetNumericArg(args, "number of repetitions for testing", 1, 1, 100)
+			args = args[2:]
+		case "-":
+			// lone dash is default for -every -trim
+			doEveryCodon = true
+			removeTrailingX = true
+			args = args[1:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -cds2prot command\n")
+			os.Exit(1)
+		}
+	}
+
+	if !eutils.IsGeneticCode(genCode) {
+		fmt.Fprintf(os.Stderr, "\nERROR: Genetic code %d does not exist\n", genCode)
+		os.Exit(1)
+	}
+
+	txt := readOneFastaSequence(inp)
+
+	if featLoc != "" {
+		// extract coding region from genomic sequence, partial ends taken from location
+		loc, ok := eutils.ParseLocation(featLoc)
+		if !ok {
+			os.Exit(1)
+		}
+		txt, ok = loc.Extract(txt)
+		if !ok {
+			os.Exit(1)
+		}
+		if loc.IsPartial5() {
+			is5primeComplete = false
+		}
+		if loc.IsPartial3() {
+			is3primeComplete = false
+		}
+	}
+
+	for i := 0; i < repeat; i++ {
+
+		// repeat multiple times for performance testing (undocumented)
+		str := eutils.TranslateCdRegion(txt, genCode, frame, includeStop, doEveryCodon, removeTrailingX, is5primeComplete, is3primeComplete, between)
+
+		os.Stdout.WriteString(str)
+		if !strings.HasSuffix(str, "\n") {
+			os.Stdout.WriteString("\n")
+		}
+	}
+}
+
+// locationReport normalizes a feature location and maps positions between sequence, feature, and protein
+func locationReport(args []string) {
+
+	// skip past command name
+	args = args[1:]
+
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "\nERROR: Missing argument after -location command\n")
+		os.Exit(1)
+	}
+
+	loc, ok := eutils.ParseLocation(args[0])
+	if !ok {
+		os.Exit(1)
+	}
+	args = args[1:]
+
+	codonStart := 1
+
+	printLoc := func(lc *eutils.Location) {
+		os.Stdout.WriteString(lc.String())
+		os.Stdout.WriteString("\n")
+	}
+
+	if len(args) < 1 {
+		printLoc(loc)
+		return
+	}
+
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-merge":
+			loc = loc.Merge()
+			printLoc(loc)
+			args = args[1:]
+		case "-complement":
+			loc = loc.Complement()
+			printLoc(loc)
+			args = args[1:]
+		case "-revcomp":
+			seqLen := eutils.GetNumericArg(args, "sequence length", 0, 1, 0)
+			loc = loc.ReverseComplement(seqLen)
+			printLoc(loc)
+			args = args[2:]
+		case "-intersect":
+			str := eutils.GetStringArg(args, "second location")
+			other, ok := eutils.ParseLocation(str)
+			if !ok {
+				os.Exit(1)
+			}
+			loc = loc.Intersect(other)
+			if loc == nil {
+				return
+			}
+			printLoc(loc)
+			args = args[2:]
+		case "-codon", "-codon_start", "-codon-start":
+			codonStart = eutils.GetNumericArg(args, "codon start", 1, 1, 3)
+			args = args[2:]
+		case "-length":
+			fmt.Fprintf(os.Stdout, "%d\n", loc.Length())
+			args = args[1:]
+		case "-position", "-genomic":
+			// sequence position to feature offset and protein residue
+			pos := eutils.GetNumericArg(args, "sequence position", 0, 1, 0)
+			res, cdn := loc.MapToProtein(pos, codonStart)
+			fmt.Fprintf(os.Stdout, "%d\t%d\t%d\t%d\n", pos, loc.MapToFeature(pos), res, cdn)
+			args = args[2:]
+		case "-offset", "-feature":
+			// feature offset to sequence position
+			ofs := eutils.GetNumericArg(args, "feature offset", 0, 1, 0)
+			fmt.Fprintf(os.Stdout, "%d\t%d\n", ofs, loc.MapToSequence(ofs))
+			args = args[2:]
+		case "-residue", "-protein":
+			// protein residue to codon positions
+			res := eutils.GetNumericArg(args, "residue number", 0, 1, 0)
+			var arry []string
+			for _, pos := range loc.MapFromProtein(res, codonStart) {
+				arry = append(arry, strconv.Itoa(pos))
+			}
+			fmt.Fprintf(os.Stdout, "%d\t%s\n", res, strings.Join(arry, ","))
+			args = args[2:]
+		default:
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -location command\n")
+			os.Exit(1)
+		}
+	}
+}
+
+// INDEXED SEQUENCE ACCESS
+
+// fastaIndex writes a samtools-compatible .fai file next to each FASTA file
+func fastaIndex(args []string) {
+
+	// skip past command name
+	args = args[1:]
+
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Missing FASTA file after -faidx command\n")
+		os.Exit(1)
 	}
 
-	var fs []rune
-	var sc []rune
-	mx := 0
-
-	// populate output arrays
-	for {
+	for _, fname := range args {
 
-		f, s := nextF(), nextS()
-		// if both spaces, end of both sequences
-		if f == ' ' && s == ' ' {
-			break
-		}
-		if f == s {
-			fs = append(fs, f)
-			sc = append(sc, ' ')
-		} else {
-			// show mismatches in upper case
-			fs = append(fs, unicode.ToUpper(f))
-			sc = append(sc, unicode.ToUpper(s))
+		fl, err := os.Open(fname)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to open FASTA file '%s'\n", fname)
+			os.Exit(1)
 		}
-		mx++
-	}
 
-	// pad output to multiple of 50
-	j := mx % 50
-	if j > 0 {
-		for j < 50 {
-			fs = append(fs, ' ')
-			sc = append(sc, ' ')
-			j++
-			mx++
+		entries := eutils.BuildFastaIndex(fl)
+		fl.Close()
+
+		if entries == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to index FASTA file '%s'\n", fname)
+			os.Exit(1)
 		}
-	}
 
-	// print in blocks of 50 bases or residues
-	for i := 0; i < mx; i += 50 {
-		dl := 50
-		if mx-i < 50 {
-			dl = mx - i
+		out, err := os.Create(fname + ".fai")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to create index file '%s.fai'\n", fname)
+			os.Exit(1)
 		}
-		lf := fs[:dl]
-		rt := sc[:dl]
-		fs = fs[dl:]
-		sc = sc[dl:]
-		tm := strings.TrimRight(string(lf), " ")
-		fmt.Fprintf(os.Stdout, "%s %6d\n%s\n", string(lf), i+len(tm), string(rt))
+
+		wrtr := bufio.NewWriter(out)
+		eutils.WriteFastaIndex(wrtr, entries)
+		wrtr.Flush()
+		out.Close()
 	}
 }
 
-func fastaDiff(inp io.Reader, args []string) {
-
-	if inp == nil {
-		return
-	}
+// fastaFetch prints regions from an indexed FASTA or UCSC .2bit file without reading whole sequences
+func fastaFetch(args []string) {
 
 	// skip past command name
 	args = args[1:]
 
-	if len(args) != 2 {
-		fmt.Fprintf(os.Stderr, "\nERROR: Two files required by -diff command\n")
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintf(os.Stderr, "\nERROR: Missing sequence file after -fetch command\n")
 		os.Exit(1)
 	}
 
-	frst := args[0]
-	scnd := args[1]
+	fname := args[0]
+	args = args[1:]
 
-	readSeqFromFile := func(fname string) string {
+	var regions []string
 
-		f, err := os.Open(fname)
+	for len(args) > 0 {
+
+		switch args[0] {
+		case "-regions", "-bed":
+			// one region per line, or BED with 0-based start and exclusive end
+			rname := eutils.GetStringArg(args, "region file name")
+			fl, err := os.Open(rname)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unable to open region file '%s'\n", rname)
+				os.Exit(1)
+			}
+			scanr := bufio.NewScanner(fl)
+			for scanr.Scan() {
+				line := strings.TrimSpace(scanr.Text())
+				if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "track") {
+					continue
+				}
+				cols := strings.Split(line, "\t")
+				if len(cols) > 2 {
+					start, err1 := strconv.Atoi(cols[1])
+					stop, err2 := strconv.Atoi(cols[2])
+					if err1 == nil && err2 == nil {
+						line = fmt.Sprintf("%s:%d-%d", cols[0], start+1, stop)
+					}
+				}
+				regions = append(regions, line)
+			}
+			fl.Close()
+			args = args[2:]
+		default:
+			if strings.HasPrefix(args[0], "-") {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -fetch command\n")
+				os.Exit(1)
+			}
+			regions = append(regions, args[0])
+			args = args[1:]
+		}
+	}
+
+	hasRegions := (len(regions) > 0)
+
+	var fetch func(name string, from, to int) (string, bool)
+
+	if strings.HasSuffix(fname, ".2bit") {
+
+		tb := eutils.OpenTwoBit(fname)
+		if tb == nil {
+			os.Exit(1)
+		}
+		defer tb.Close()
+
+		if !hasRegions {
+			regions = tb.Names()
+		}
+
+		fetch = func(name string, from, to int) (string, bool) {
+			if tb.Length(name) < 0 {
+				return "", false
+			}
+			return tb.Fetch(name, from, to), true
+		}
+
+	} else {
+
+		fl, err := os.Open(fname)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Unable to open file %s - %s\n", fname, err.Error())
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to open FASTA file '%s'\n", fname)
 			os.Exit(1)
 		}
+		defer fl.Close()
 
-		defer f.Close()
+		var entries []eutils.FastaIndexEntry
 
-		seq := readOneFastaSequence(f)
+		// use existing .fai file, otherwise index on the fly
+		if fai, err := os.Open(fname + ".fai"); err == nil {
+			entries = eutils.ReadFastaIndex(fai)
+			fai.Close()
+		} else {
+			entries = eutils.BuildFastaIndex(fl)
+		}
 
-		return seq
+		index := make(map[string]eutils.FastaIndexEntry)
+		for _, ent := range entries {
+			index[ent.Name] = ent
+			if !hasRegions {
+				regions = append(regions, ent.Name)
+			}
+		}
+
+		fetch = func(name string, from, to int) (string, bool) {
+			ent, ok := index[name]
+			if !ok {
+				return "", false
+			}
+			return eutils.FetchFastaRegion(fl, ent, from, to), true
+		}
 	}
 
-	frstFasta := readSeqFromFile(frst)
-	scndFasta := readSeqFromFile(scnd)
+	wrtr := bufio.NewWriter(os.Stdout)
+	defer wrtr.Flush()
 
-	if frstFasta == scndFasta {
-		return
-	}
+	for _, rgn := range regions {
 
-	// sequences are assumed to be aligned, this code highlight mismatches
-	printFastaPairs(frstFasta, scndFasta)
+		name, from, to, ok := eutils.ParseRegion(rgn)
+		if !ok {
+			// deferred flush does not run after os.Exit, so keep regions already fetched
+			wrtr.Flush()
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized region '%s'\n", rgn)
+			os.Exit(1)
+		}
+
+		seq, ok := fetch(name, from, to)
+		if !ok {
+			wrtr.Flush()
+			fmt.Fprintf(os.Stderr, "\nERROR: Sequence '%s' not found in '%s'\n", name, fname)
+			os.Exit(1)
+		}
+		if seq == "" && from > 0 {
+			// region outside sequence bounds was already reported
+			wrtr.Flush()
+			os.Exit(1)
+		}
+
+		wrtr.WriteString(">" + rgn + "\n")
+		for i := 0; i < len(seq); i += 60 {
+			j := i + 60
+			if j > len(seq) {
+				j = len(seq)
+			}
+			wrtr.WriteString(seq[i:j])
+			wrtr.WriteString("\n")
+		}
+	}
 }
 
-// PROTEIN WEIGHT
+// CODON USAGE
 
-func protWeight(inp io.Reader, args []string) {
+// codonUsage tabulates codon counts and RSCU values, or CAI against a reference table,
+// or reverse translates proteins with the most frequently used codons
+func codonUsage(inp io.Reader, args []string) {
 
 	if inp == nil {
 		return
 	}
 
-	trimLeadingMet := true
+	cmd := args[0]
+
+	genCode := 1
+	frame := 0
+	usageFile := ""
 
 	// skip past command name
 	args = args[1:]
@@ -1637,42 +2915,98 @@ func protWeight(inp io.Reader, args []string) {
 	for len(args) > 0 {
 
 		switch args[0] {
-		case "-met":
-			trimLeadingMet = false
-			args = args[1:]
+		case "-code", "-gencode":
+			genCode = eutils.GetNumericArg(args, "genetic code number", 0, 1, 0)
+			args = args[2:]
+		case "-frame":
+			frame = eutils.GetNumericArg(args, "offset into coding sequence", 0, 1, 30)
+			args = args[2:]
+		case "-usage", "-table", "-reference":
+			usageFile = eutils.GetStringArg(args, "codon usage table file name")
+			args = args[2:]
 		default:
-			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -molwt command\n")
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after %s command\n", cmd)
 			os.Exit(1)
 		}
 	}
 
-	str := readOneFastaSequence(inp)
+	if !eutils.IsGeneticCode(genCode) {
+		fmt.Fprintf(os.Stderr, "\nERROR: Genetic code %d does not exist\n", genCode)
+		os.Exit(1)
+	}
 
-	str = eutils.ProteinWeight(str, trimLeadingMet)
+	var ref *eutils.CodonUsage
 
-	os.Stdout.WriteString(str)
-	if !strings.HasSuffix(str, "\n") {
-		os.Stdout.WriteString("\n")
+	if usageFile != "" {
+		fl, err := os.Open(usageFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to open codon usage file '%s'\n", usageFile)
+			os.Exit(1)
+		}
+		ref = eutils.ReadCodonUsage(fl, genCode)
+		fl.Close()
+		if ref == nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: No codon counts found in '%s'\n", usageFile)
+			os.Exit(1)
+		}
+	}
+
+	if cmd == "-cai" && ref == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: -cai requires -usage reference table\n")
+		os.Exit(1)
+	}
+
+	cu := eutils.NewCodonUsage(genCode)
+
+	fsta := eutils.FASTAConverter(inp, false)
+
+	for fsa := range fsta {
+
+		switch cmd {
+		case "-cai":
+			cai := ref.CAI(fsa.Sequence, frame)
+			if cai < 0 {
+				fmt.Fprintf(os.Stdout, "%s\t-\n", fsa.SeqID)
+			} else {
+				fmt.Fprintf(os.Stdout, "%s\t%.4f\n", fsa.SeqID, cai)
+			}
+		case "-revtrans", "-backtrans":
+			str := eutils.ReverseTranslate(fsa.Sequence, genCode, ref)
+			os.Stdout.WriteString(">" + fsa.SeqID + "\n")
+			for i := 0; i < len(str); i += 60 {
+				j := i + 60
+				if j > len(str) {
+					j = len(str)
+				}
+				os.Stdout.WriteString(str[i:j] + "\n")
+			}
+		default:
+			cu.AddSequence(fsa.Sequence, frame)
+		}
+	}
+
+	if cmd == "-codonusage" || cmd == "-usage" {
+		os.Stdout.WriteString(cu.Report())
 	}
 }
 
-// cdRegionToProtein reads all of stdin as sequence data
-func cdRegionToProtein(inp io.Reader, args []string) {
+// MULTIPLE SEQUENCE ALIGNMENT
+
+// alignmentProcess reads a Clustal, Stockholm, or aligned FASTA alignment and reports column
+// statistics, consensus, or a gap-stripped or column-range sub-alignment
+func alignmentProcess(inp io.Reader, args []string) {
 
 	if inp == nil {
 		return
 	}
 
-	genCode := 1
-	frame := 0
-	includeStop := false
-	doEveryCodon := false
-	removeTrailingX := false
-	is5primeComplete := true
-	is3primeComplete := true
-	between := ""
+	toXML := (args[0] == "-msa2x")
 
-	repeat := 1
+	mode := ""
+	threshold := 0.5
+	maxGaps := 0.0
+	from := 0
+	to := 0
 
 	// skip past command name
 	args = args[1:]
@@ -1680,55 +3014,79 @@ func cdRegionToProtein(inp io.Reader, args []string) {
 	for len(args) > 0 {
 
 		switch args[0] {
-		case "-code", "-gencode":
-			genCode = eutils.GetNumericArg(args, "genetic code number", 0, 1, 30)
-			args = args[2:]
-		case "-frame":
-			frame = eutils.GetNumericArg(args, "offset into coding sequence", 0, 1, 30)
-			args = args[2:]
-		case "-stop", "-stops":
-			includeStop = true
-			args = args[1:]
-		case "-every", "-all":
-			doEveryCodon = true
+		case "-xml":
+			toXML = true
 			args = args[1:]
-		case "-trim", "-trailing":
-			removeTrailingX = true
+		case "-conservation", "-entropy":
+			mode = "conservation"
 			args = args[1:]
-		case "-part5", "-partial5", "-lt5":
-			is5primeComplete = false
+		case "-consensus":
+			mode = "consensus"
 			args = args[1:]
-		case "-part3", "-partial3", "-gt3":
-			is3primeComplete = false
+		case "-threshold":
+			str := eutils.GetStringArg(args, "consensus threshold")
+			val, err := strconv.ParseFloat(str, 64)
+			if err != nil || val < 0 || val > 1 {
+				fmt.Fprintf(os.Stderr, "\nERROR: Consensus threshold '%s' must be between 0 and 1\n", str)
+				os.Exit(1)
+			}
+			threshold = val
+			args = args[2:]
+		case "-degap", "-strip":
+			mode = "degap"
 			args = args[1:]
-		case "-between":
-			between = eutils.GetStringArg(args, "separator between residues")
+		case "-gaps":
+			str := eutils.GetStringArg(args, "maximum gap fraction")
+			val, err := strconv.ParseFloat(str, 64)
+			if err != nil || val < 0 || val > 1 {
+				fmt.Fprintf(os.Stderr, "\nERROR: Gap fraction '%s' must be between 0 and 1\n", str)
+				os.Exit(1)
+			}
+			maxGaps = val
 			args = args[2:]
-		case "-repeat":
-			repeat = eutils.GetNumericArg(args, "number of repetitions for testing", 1, 1, 100)
+		case "-columns":
+			mode = "columns"
+			str := eutils.GetStringArg(args, "column range")
+			_, from, to, _ = eutils.ParseRegion("cols:" + str)
+			if from < 1 {
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized column range '%s'\n", str)
+				os.Exit(1)
+			}
 			args = args[2:]
-		case "-":
-			// lone dash is default for -every -trim
-			doEveryCodon = true
-			removeTrailingX = true
-			args = args[1:]
 		default:
-			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -cds2prot command\n")
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized option after -alignment command\n")
 			os.Exit(1)
 		}
 	}
 
-	txt := readOneFastaSequence(inp)
-
-	for i := 0; i < repeat; i++ {
-
-		// repeat multiple times for performance testing (undocumented)
-		str := eutils.TranslateCdRegion(txt, genCode, frame, includeStop, doEveryCodon, removeTrailingX, is5primeComplete, is3primeComplete, between)
+	aln := eutils.ReadAlignment(inp)
+	if aln == nil {
+		os.Exit(1)
+	}
 
-		os.Stdout.WriteString(str)
-		if !strings.HasSuffix(str, "\n") {
-			os.Stdout.WriteString("\n")
+	switch mode {
+	case "conservation":
+		for _, st := range aln.ColumnStats() {
+			fmt.Fprintf(os.Stdout, "%d\t%c\t%.3f\t%.3f\t%.3f\n", st.Column, st.Residue, st.Identity, st.Entropy, st.Gaps)
+		}
+		return
+	case "consensus":
+		str := aln.Consensus(threshold)
+		os.Stdout.WriteString(">consensus\n" + str + "\n")
+		return
+	case "degap":
+		aln = aln.StripGaps(maxGaps)
+	case "columns":
+		if to < 1 {
+			to = aln.Length()
 		}
+		aln = aln.Columns(from, to)
+	}
+
+	if toXML {
+		os.Stdout.WriteString(aln.XML())
+	} else {
+		os.Stdout.WriteString(aln.FASTA())
 	}
 }
 
@@ -1835,6 +3193,10 @@ func main() {
 	// read data from file instead of stdin
 	fileName := ""
 
+	// load additional genetic codes from file
+	codeFile := ""
+	codeID := 0
+
 	// debugging
 	stts := false
 	timr := false
@@ -1891,6 +3253,14 @@ func main() {
 			// skip past first of two arguments
 			args = args[1:]
 
+		// runtime genetic code definitions
+		case "-gencodes", "-gcfile":
+			codeFile = eutils.GetStringArg(args, "Genetic code file name")
+			args = args[1:]
+		case "-gencode-id", "-gcid":
+			codeID = eutils.GetNumericArg(args, "Genetic code ID for codon table", 0, 1, 0)
+			args = args[1:]
+
 		// data cleanup flags
 		case "-compress", "-compressed":
 			doCompress = true
@@ -1969,6 +3339,29 @@ func main() {
 		}
 	}
 
+	if codeFile != "" {
+		fl, err := os.Open(codeFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unable to open genetic code file '%s'\n", codeFile)
+			os.Exit(1)
+		}
+		codes := eutils.ReadGeneticCodes(fl)
+		fl.Close()
+		if len(codes) < 1 {
+			fmt.Fprintf(os.Stderr, "\nERROR: No genetic codes found in '%s'\n", codeFile)
+			os.Exit(1)
+		}
+		for _, gc := range codes {
+			// -gencode-id assigns number to codon table, or renumbers single gc.prt entry
+			if codeID > 0 && len(codes) == 1 {
+				gc.ID = codeID
+			}
+			if !eutils.RegisterGeneticCode(gc) {
+				os.Exit(1)
+			}
+		}
+	}
+
 	// -flag allows script to set -strict or -mixed (or -stems, or -stops) from argument
 	switch flgs {
 	case "strict":
@@ -2498,6 +3891,12 @@ func main() {
 		decodeB64(in)
 	case "-hgvs":
 		decodeHGVS(in)
+	case "-spdi2vcf":
+		spdiToVcf(in, args)
+	case "-vcf2spdi":
+		vcfToSpdi(in, args)
+	case "-effect", "-consequence":
+		variantEffect(in, args)
 	case "-align":
 		processAlign(in, args)
 	case "-remove":
@@ -2512,6 +3911,10 @@ func main() {
 		sequenceSearch(in, args)
 	case "-find":
 		stringFind(in, args)
+	case "-motif", "-motifs", "-prosite":
+		motifScan(in, args)
+	case "-digest":
+		restrictionDigest(in, args)
 	case "-relax":
 		relaxString(in)
 	case "-upper":
@@ -2530,6 +3933,22 @@ func main() {
 		cdRegionToProtein(in, args)
 	case "-codons":
 		nucProtCodonReport(args)
+	case "-codonusage", "-usage", "-cai", "-revtrans", "-backtrans":
+		codonUsage(in, args)
+	case "-smiles":
+		smilesProperties(in, args)
+	case "-windows":
+		windowStats(in, args)
+	case "-mask":
+		lowComplexityMask(in, args)
+	case "-msa2x", "-alignment":
+		alignmentProcess(in, args)
+	case "-location":
+		locationReport(args)
+	case "-faidx":
+		fastaIndex(args)
+	case "-fetch":
+		fastaFetch(args)
 	case "-diff":
 		fastaDiff(in, args)
 	default:
@@ -2584,6 +4003,45 @@ func main() {
 		processFormat(rdr, args)
 	case "-filter":
 		processFilter(rdr, args)
+	case "-insd2gff", "-insd2gtf", "-insd2bed":
+		insdToGFF(rdr, args)
+	case "-x2g", "-insd2gb":
+		eutils.PartitionPattern("INSDSeq", "", false, rdr,
+			func(str string) {
+				txt := eutils.INSDSeqToGenBank(str)
+				if txt != "" {
+					os.Stdout.WriteString(txt)
+				}
+			})
+	case "-pc2mol":
+		// molfile holds a single structure, held back until the input is known to have only one
+		mol := ""
+		count := 0
+		eutils.PartitionPattern("PC-Compound", "", false, rdr,
+			func(str string) {
+				count++
+				if count == 1 {
+					mol = eutils.PCCompoundToMOL(str)
+				}
+			})
+		if count > 1 {
+			fmt.Fprintf(os.Stderr, "\nERROR: -pc2mol found %d compounds, use -pc2sdf for multiple records\n", count)
+			os.Exit(1)
+		}
+		os.Stdout.WriteString(mol)
+	case "-pc2sdf", "-pc2tsv", "-pc2table":
+		convert := eutils.PCCompoundToSDF
+		switch args[0] {
+		case "-pc2tsv", "-pc2table":
+			convert = eutils.PCCompoundToTable
+		}
+		eutils.PartitionPattern("PC-Compound", "", false, rdr,
+			func(str string) {
+				txt := convert(str)
+				if txt != "" {
+					os.Stdout.WriteString(txt)
+				}
+			})
 	case "-normalize", "-normal":
 		if len(args) < 2 {
 			fmt.Fprintf(os.Stderr, "\nERROR: No database supplied to -normalize\n")
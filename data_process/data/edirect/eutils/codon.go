@@ -0,0 +1,326 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  codon.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Relative synonymous codon usage and the codon adaptation index are from
+// Sharp PM, Li WH. Nucleic Acids Res. 1987 Feb 11;15(3):1281-95
+
+// codons are indexed in TCAG order, matching the ncbieaaCode strings
+const codonBases = "TCAG"
+
+// CodonUsage holds codon counts for one genetic code
+type CodonUsage struct {
+	GenCode int
+	Counts  [64]float64
+}
+
+// codonIndex returns 0-63 for an unambiguous codon, or -1
+func codonIndex(codon string) int {
+
+	if len(codon) != 3 {
+		return -1
+	}
+
+	idx := 0
+	for i := 0; i < 3; i++ {
+		ch := codon[i]
+		if ch >= 'a' && ch <= 'z' {
+			ch -= 'a' - 'A'
+		}
+		if ch == 'U' {
+			ch = 'T'
+		}
+		pos := strings.IndexByte(codonBases, ch)
+		if pos < 0 {
+			return -1
+		}
+		idx = idx*4 + pos
+	}
+
+	return idx
+}
+
+// codonFromIndex returns the DNA codon for an index
+func codonFromIndex(idx int) string {
+
+	return string([]byte{codonBases[idx/16], codonBases[(idx/4)%4], codonBases[idx%4]})
+}
+
+// codonAminoAcid translates a codon index with the finite state machine tables
+func codonAminoAcid(genCode, idx int) byte {
+
+	codon := codonFromIndex(idx)
+
+	return byte(GetCodonResidue(genCode, SetCodonState(int(codon[0]), int(codon[1]), int(codon[2]))))
+}
+
+// NewCodonUsage creates an empty usage table
+func NewCodonUsage(genCode int) *CodonUsage {
+
+	return &CodonUsage{GenCode: correctGenCode(genCode)}
+}
+
+// AddSequence counts the codons of a coding sequence, skipping codons with ambiguous bases
+func (cu *CodonUsage) AddSequence(seq string, frame int) {
+
+	for i := frame; i+3 <= len(seq); i += 3 {
+		idx := codonIndex(seq[i : i+3])
+		if idx >= 0 {
+			cu.Counts[idx]++
+		}
+	}
+}
+
+// synonyms groups codon indices by encoded amino acid
+func (cu *CodonUsage) synonyms() map[byte][]int {
+
+	syns := make(map[byte][]int)
+
+	for idx := 0; idx < 64; idx++ {
+		aa := codonAminoAcid(cu.GenCode, idx)
+		syns[aa] = append(syns[aa], idx)
+	}
+
+	return syns
+}
+
+// RSCU returns observed codon counts divided by the count expected if synonymous codons were used equally
+func (cu *CodonUsage) RSCU() [64]float64 {
+
+	var rscu [64]float64
+
+	for _, idxs := range cu.synonyms() {
+		total := 0.0
+		for _, idx := range idxs {
+			total += cu.Counts[idx]
+		}
+		if total == 0 {
+			continue
+		}
+		expect := total / float64(len(idxs))
+		for _, idx := range idxs {
+			rscu[idx] = cu.Counts[idx] / expect
+		}
+	}
+
+	return rscu
+}
+
+// Adaptiveness returns the relative adaptiveness of each codon, the ratio to the most used synonymous
+// codon, with a count of 0.5 substituted for unobserved codons, and 0 for single-codon amino acids and stops
+func (cu *CodonUsage) Adaptiveness() [64]float64 {
+
+	var wts [64]float64
+
+	for aa, idxs := range cu.synonyms() {
+		if len(idxs) < 2 || aa == '*' {
+			continue
+		}
+		max := 0.0
+		for _, idx := range idxs {
+			if cu.Counts[idx] > max {
+				max = cu.Counts[idx]
+			}
+		}
+		if max == 0 {
+			continue
+		}
+		for _, idx := range idxs {
+			cnt := cu.Counts[idx]
+			if cnt == 0 {
+				cnt = 0.5
+			}
+			wts[idx] = cnt / max
+		}
+	}
+
+	return wts
+}
+
+// CAI calculates the codon adaptation index of a coding sequence as the geometric mean of the relative
+// adaptiveness of its codons, returning -1 if no informative codons are present
+func (cu *CodonUsage) CAI(seq string, frame int) float64 {
+
+	wts := cu.Adaptiveness()
+
+	sum := 0.0
+	num := 0
+
+	for i := frame; i+3 <= len(seq); i += 3 {
+		idx := codonIndex(seq[i : i+3])
+		if idx < 0 || wts[idx] == 0 {
+			continue
+		}
+		sum += math.Log(wts[idx])
+		num++
+	}
+
+	if num == 0 {
+		return -1
+	}
+
+	return math.Exp(sum / float64(num))
+}
+
+// Report prints codon, amino acid, count, frequency per thousand, and RSCU lines
+func (cu *CodonUsage) Report() string {
+
+	total := 0.0
+	for _, cnt := range cu.Counts {
+		total += cnt
+	}
+
+	rscu := cu.RSCU()
+
+	var buffer strings.Builder
+
+	for idx := 0; idx < 64; idx++ {
+		perK := 0.0
+		if total > 0 {
+			perK = cu.Counts[idx] * 1000 / total
+		}
+		cnt := strconv.FormatFloat(cu.Counts[idx], 'f', -1, 64)
+		buffer.WriteString(fmt.Sprintf("%s\t%c\t%s\t%.2f\t%.3f\n", codonFromIndex(idx), codonAminoAcid(cu.GenCode, idx), cnt, perK, rscu[idx]))
+	}
+
+	return buffer.String()
+}
+
+// ReadCodonUsage loads counts from the Report format, a Kazusa/CUTG table, e.g., "UUU 17.6(714298)",
+// or a GCG codon frequency table, using the first number after each codon when no count is in parentheses
+func ReadCodonUsage(inp io.Reader, genCode int) *CodonUsage {
+
+	if inp == nil {
+		return nil
+	}
+
+	cu := NewCodonUsage(genCode)
+	found := false
+
+	scanr := bufio.NewScanner(inp)
+
+	for scanr.Scan() {
+
+		line := scanr.Text()
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		// separate per thousand frequency from count, e.g., "17.6(714298)" or "17.6( 714298)"
+		line = strings.Replace(line, "(", " ( ", -1)
+		line = strings.Replace(line, ")", " ) ", -1)
+
+		flds := strings.Fields(line)
+
+		for i := 0; i < len(flds); i++ {
+
+			idx := codonIndex(flds[i])
+			if idx < 0 || len(flds[i]) != 3 {
+				continue
+			}
+
+			value := -1.0
+			for j := i + 1; j < len(flds); j++ {
+				if codonIndex(flds[j]) >= 0 {
+					break
+				}
+				if flds[j] == "(" && j+1 < len(flds) {
+					if num, err := strconv.ParseFloat(flds[j+1], 64); err == nil {
+						value = num
+					}
+					break
+				}
+				if value < 0 {
+					if num, err := strconv.ParseFloat(flds[j], 64); err == nil {
+						value = num
+					}
+				}
+			}
+
+			if value >= 0 {
+				cu.Counts[idx] = value
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		return nil
+	}
+
+	return cu
+}
+
+// ReverseTranslate converts a protein to nucleotides, choosing the most used codon for each residue,
+// or the IUPAC ambiguity code covering all synonymous codons if no usage table is supplied
+func ReverseTranslate(prot string, genCode int, cu *CodonUsage) string {
+
+	genCode = correctGenCode(genCode)
+
+	best := make(map[byte]string)
+
+	for aa, idxs := range NewCodonUsage(genCode).synonyms() {
+
+		if cu != nil {
+			pick := idxs[0]
+			for _, idx := range idxs {
+				if cu.Counts[idx] > cu.Counts[pick] {
+					pick = idx
+				}
+			}
+			best[aa] = codonFromIndex(pick)
+			continue
+		}
+
+		// combine synonymous codons position by position as ncbi4na bit flags
+		var flags [3]int
+		for _, idx := range idxs {
+			codon := codonFromIndex(idx)
+			for k := 0; k < 3; k++ {
+				flags[k] |= baseToIdx[int(codon[k])]
+			}
+		}
+		const upperToBase = "-ACMGRSVTWYHKDBN"
+		best[aa] = string([]byte{upperToBase[flags[0]], upperToBase[flags[1]], upperToBase[flags[2]]})
+	}
+
+	var buffer strings.Builder
+
+	for i := 0; i < len(prot); i++ {
+		ch := prot[i]
+		if ch >= 'a' && ch <= 'z' {
+			ch -= 'a' - 'A'
+		}
+		codon, ok := best[ch]
+		if !ok {
+			codon = "NNN"
+		}
+		buffer.WriteString(codon)
+	}
+
+	return buffer.String()
+}
@@ -0,0 +1,377 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  digest.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Enzyme contains a restriction enzyme recognition site and cleavage offsets. Offsets are
+// 0-based positions on the top strand relative to the start of the site, so G^AATTC has
+// TopCut 1 and BotCut 5. Enzymes that cut on both sides of the site have a second pair.
+type Enzyme struct {
+	Name    string
+	Site    string
+	TopCut  int
+	BotCut  int
+	TopCut2 int
+	BotCut2 int
+	NumCuts int
+}
+
+// commonly used commercial enzymes in REBASE site notation
+var defaultEnzymes = []string{
+	"AatII\tGACGT^C",
+	"AccI\tGT^MKAC",
+	"AgeI\tA^CCGGT",
+	"AluI\tAG^CT",
+	"ApaI\tGGGCC^C",
+	"AscI\tGG^CGCGCC",
+	"AvrII\tC^CTAGG",
+	"BamHI\tG^GATCC",
+	"BglI\tGCCNNNN^NGGC",
+	"BglII\tA^GATCT",
+	"BsaI\tGGTCTC(1/5)",
+	"BsmBI\tCGTCTC(1/5)",
+	"BspQI\tGCTCTTC(1/4)",
+	"BsrFI\tR^CCGGY",
+	"ClaI\tAT^CGAT",
+	"DraI\tTTT^AAA",
+	"EcoRI\tG^AATTC",
+	"EcoRV\tGAT^ATC",
+	"HaeIII\tGG^CC",
+	"HindIII\tA^AGCTT",
+	"HinfI\tG^ANTC",
+	"KpnI\tGGTAC^C",
+	"MluI\tA^CGCGT",
+	"MspI\tC^CGG",
+	"NcoI\tC^CATGG",
+	"NdeI\tCA^TATG",
+	"NheI\tG^CTAGC",
+	"NotI\tGC^GGCCGC",
+	"PacI\tTTAAT^TAA",
+	"PstI\tCTGCA^G",
+	"SacI\tGAGCT^C",
+	"SalI\tG^TCGAC",
+	"Sau3AI\t^GATC",
+	"SmaI\tCCC^GGG",
+	"SpeI\tA^CTAGT",
+	"SphI\tGCATG^C",
+	"TaqI\tT^CGA",
+	"XbaI\tT^CTAGA",
+	"XhoI\tC^TCGAG",
+}
+
+// ParseEnzymeSite interprets REBASE site notation, with either a caret for symmetric cleavage,
+// e.g., "G^AATTC", or top and bottom offsets past the end of the site, e.g., "GGTCTC(1/5)",
+// and optionally before the start of the site, e.g., "(10/15)ACNNNNGTAYC(12/7)"
+func ParseEnzymeSite(name, site string) (Enzyme, bool) {
+
+	site = strings.ToUpper(strings.TrimSpace(site))
+
+	reportError := func() (Enzyme, bool) {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Unrecognized site '%s' for enzyme '%s'%s\n", INVT, LOUD, site, name, INIT)
+		return Enzyme{}, false
+	}
+
+	// parse "(top/bottom)" offset pair
+	parsePair := func(str string) (int, int, bool) {
+		str = strings.TrimPrefix(str, "(")
+		str = strings.TrimSuffix(str, ")")
+		lft, rgt := SplitInTwoLeft(str, "/")
+		top, err := strconv.Atoi(strings.TrimSpace(lft))
+		if err != nil {
+			return 0, 0, false
+		}
+		bot, err := strconv.Atoi(strings.TrimSpace(rgt))
+		if err != nil {
+			return 0, 0, false
+		}
+		return top, bot, true
+	}
+
+	enz := Enzyme{Name: name}
+
+	// offsets before the site
+	pre := ""
+	if strings.HasPrefix(site, "(") {
+		idx := strings.Index(site, ")")
+		if idx < 0 {
+			return reportError()
+		}
+		pre = site[:idx+1]
+		site = site[idx+1:]
+	}
+
+	// offsets after the site
+	post := ""
+	if strings.HasSuffix(site, ")") {
+		idx := strings.LastIndex(site, "(")
+		if idx < 0 {
+			return reportError()
+		}
+		post = site[idx:]
+		site = site[:idx]
+	}
+
+	if strings.Contains(site, "^") {
+		if pre != "" || post != "" || strings.Count(site, "^") > 1 {
+			return reportError()
+		}
+		idx := strings.Index(site, "^")
+		site = strings.Replace(site, "^", "", 1)
+		// palindromic cleavage leaves the same overhang on both strands
+		enz.TopCut = idx
+		enz.BotCut = len(site) - idx
+		enz.NumCuts = 2
+	} else if post != "" {
+		top, bot, ok := parsePair(post)
+		if !ok {
+			return reportError()
+		}
+		enz.TopCut = len(site) + top
+		enz.BotCut = len(site) + bot
+		enz.NumCuts = 2
+	}
+
+	if pre != "" {
+		top, bot, ok := parsePair(pre)
+		if !ok {
+			return reportError()
+		}
+		if enz.NumCuts == 2 {
+			enz.TopCut2, enz.BotCut2 = enz.TopCut, enz.BotCut
+			enz.NumCuts = 4
+		} else {
+			enz.NumCuts = 2
+		}
+		enz.TopCut = -top
+		enz.BotCut = -bot
+	}
+
+	for _, ch := range site {
+		if _, ok := expandNuc[string(ch)]; !ok {
+			return reportError()
+		}
+	}
+
+	if site == "" || enz.NumCuts == 0 {
+		return reportError()
+	}
+
+	enz.Site = site
+
+	return enz, true
+}
+
+// DefaultEnzymes returns the bundled table of common restriction enzymes
+func DefaultEnzymes() []Enzyme {
+
+	var arry []Enzyme
+
+	for _, str := range defaultEnzymes {
+		name, site := SplitInTwoLeft(str, "\t")
+		enz, ok := ParseEnzymeSite(name, site)
+		if ok {
+			arry = append(arry, enz)
+		}
+	}
+
+	return arry
+}
+
+// ReadEnzymeTable reads a REBASE emboss_e file, with name, site, length, number of cuts,
+// blunt flag, and up to four cut positions, or a simple table of name and site notation
+func ReadEnzymeTable(inp io.Reader) []Enzyme {
+
+	if inp == nil {
+		return nil
+	}
+
+	var arry []Enzyme
+
+	// emboss_e positions count from 1 and skip 0, so -1 is immediately before the site
+	embossOffset := func(str string) (int, bool) {
+		val, err := strconv.Atoi(str)
+		if err != nil {
+			return 0, false
+		}
+		if val < 0 {
+			val++
+		}
+		return val, true
+	}
+
+	scanr := bufio.NewScanner(inp)
+
+	for scanr.Scan() {
+
+		line := strings.TrimSpace(scanr.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		cols := strings.Fields(line)
+
+		if len(cols) >= 9 && IsAllDigits(cols[2]) {
+			// emboss_e format
+			name := cols[0]
+			site := strings.ToUpper(cols[1])
+			ncuts, _ := strconv.Atoi(cols[3])
+			if ncuts != 2 && ncuts != 4 {
+				// cleavage position unknown
+				continue
+			}
+			var pos [4]int
+			ok := true
+			for i := 0; i < ncuts; i++ {
+				pos[i], ok = embossOffset(cols[5+i])
+				if !ok {
+					break
+				}
+			}
+			if !ok {
+				fmt.Fprintf(os.Stderr, "%s ERROR: %s Unrecognized enzyme line '%s'%s\n", INVT, LOUD, line, INIT)
+				continue
+			}
+			enz := Enzyme{Name: name, Site: site, TopCut: pos[0], BotCut: pos[1], NumCuts: ncuts}
+			if ncuts == 4 {
+				enz.TopCut2 = pos[2]
+				enz.BotCut2 = pos[3]
+			}
+			arry = append(arry, enz)
+			continue
+		}
+
+		if len(cols) < 2 {
+			fmt.Fprintf(os.Stderr, "%s ERROR: %s Unrecognized enzyme line '%s'%s\n", INVT, LOUD, line, INIT)
+			continue
+		}
+
+		enz, ok := ParseEnzymeSite(cols[0], cols[1])
+		if ok {
+			arry = append(arry, enz)
+		}
+	}
+
+	return arry
+}
+
+// DigestCuts returns sorted 0-based top-strand cleavage positions, where a cut at N falls
+// between bases N and N+1 in 1-based numbering. Sites are found on both strands with the
+// approximate searcher, so degenerate recognition sequences do not overflow.
+func DigestCuts(seq string, enz Enzyme, isCircular bool) []int {
+
+	seqlen := len(seq)
+	sitelen := len(enz.Site)
+
+	if seqlen < 1 || sitelen < 1 {
+		return nil
+	}
+
+	srch := ApproximateSearcher([]string{enz.Site + ":" + enz.Name}, false, isCircular, false, 0, false)
+	if srch == nil {
+		return nil
+	}
+
+	seen := make(map[int]bool)
+
+	addCut := func(pos int) {
+		if isCircular {
+			pos = ((pos % seqlen) + seqlen) % seqlen
+		} else if pos <= 0 || pos >= seqlen {
+			// cleavage falls outside linear molecule
+			return
+		}
+		seen[pos] = true
+	}
+
+	addPair := func(hit SearchHit, top, bot int) {
+		if hit.Strand == "-" {
+			// enzyme bottom strand cut is on the top strand when site is on minus strand
+			addCut(hit.Point + sitelen - bot)
+		} else {
+			addCut(hit.Point + top)
+		}
+	}
+
+	for _, hit := range srch.Search(seq) {
+		addPair(hit, enz.TopCut, enz.BotCut)
+		if enz.NumCuts == 4 {
+			addPair(hit, enz.TopCut2, enz.BotCut2)
+		}
+	}
+
+	var arry []int
+	for pos := range seen {
+		arry = append(arry, pos)
+	}
+	sort.Ints(arry)
+
+	return arry
+}
+
+// DigestFragments returns fragment lengths, in sequence order, produced by a set of cuts
+func DigestFragments(cuts []int, seqlen int, isCircular bool) []int {
+
+	if seqlen < 1 {
+		return nil
+	}
+
+	// combine and sort cuts from multiple enzymes
+	srt := make([]int, 0, len(cuts))
+	seen := make(map[int]bool)
+	for _, pos := range cuts {
+		if !seen[pos] {
+			seen[pos] = true
+			srt = append(srt, pos)
+		}
+	}
+	sort.Ints(srt)
+
+	if len(srt) < 1 {
+		return []int{seqlen}
+	}
+
+	var arry []int
+
+	if isCircular {
+		for i := 1; i < len(srt); i++ {
+			arry = append(arry, srt[i]-srt[i-1])
+		}
+		// fragment spanning the origin
+		arry = append(arry, seqlen-srt[len(srt)-1]+srt[0])
+		return arry
+	}
+
+	prev := 0
+	for _, pos := range srt {
+		arry = append(arry, pos-prev)
+		prev = pos
+	}
+	arry = append(arry, seqlen-prev)
+
+	return arry
+}
@@ -0,0 +1,351 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  effect.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// VariantEffect contains the predicted protein consequence of a coding sequence variant
+type VariantEffect struct {
+	Type    string
+	Protein string
+}
+
+// additional consequence types not used by ParseHGVS
+const (
+	effectStartLoss = "StartLoss"
+	effectUTR       = "UTR"
+)
+
+// threeLetterResidue converts one-letter residue to HGVS three-letter abbreviation
+func threeLetterResidue(ch byte) string {
+
+	res, ok := aaTo3[string(ch)]
+	if !ok {
+		return "Xaa"
+	}
+
+	return res
+}
+
+// threeLetterProtein converts a peptide string to concatenated three-letter abbreviations
+func threeLetterProtein(prt string) string {
+
+	var buffer strings.Builder
+
+	for i := 0; i < len(prt); i++ {
+		buffer.WriteString(threeLetterResidue(prt[i]))
+	}
+
+	return buffer.String()
+}
+
+// ParseCodingHGVS interprets a c. variant, e.g., "NM_000518.5:c.20A>T", "c.27delG",
+// "c.27_29del", "c.27dup", "c.27_28insTTT", or "c.27_29delinsGG", relative to a
+// transcript sequence with a 0-based CDS start and exclusive CDS stop. It returns the
+// 0-based transcript position, deleted sequence, and inserted sequence. Positions
+// upstream (c.-14) and downstream (c.*32) of the CDS are allowed, intronic offsets are not.
+func ParseCodingHGVS(str, seq string, cdsFrom, cdsTo int) (int, string, string, bool) {
+
+	reportError := func(msg string) (int, string, string, bool) {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s %s in '%s'%s\n", INVT, LOUD, msg, str, INIT)
+		return 0, "", "", false
+	}
+
+	seq = strings.ToUpper(seq)
+	ln := len(seq)
+
+	vrn := str
+	if idx := strings.Index(vrn, ":"); idx >= 0 {
+		vrn = vrn[idx+1:]
+	}
+	if !strings.HasPrefix(vrn, "c.") {
+		return reportError("Expected c. variant")
+	}
+	vrn = vrn[2:]
+
+	// convert c. coordinate to 0-based transcript position
+	convertPos := func(num string) (int, bool) {
+		if strings.ContainsAny(num, "+") || (strings.Index(num, "-") > 0) {
+			return 0, false
+		}
+		if strings.HasPrefix(num, "*") {
+			val, err := strconv.Atoi(num[1:])
+			if err != nil || val < 1 {
+				return 0, false
+			}
+			return cdsTo + val - 1, true
+		}
+		if strings.HasPrefix(num, "-") {
+			val, err := strconv.Atoi(num[1:])
+			if err != nil || val < 1 {
+				return 0, false
+			}
+			return cdsFrom - val, true
+		}
+		val, err := strconv.Atoi(num)
+		if err != nil || val < 1 {
+			return 0, false
+		}
+		return cdsFrom + val - 1, true
+	}
+
+	// split leading location from variant operation
+	idx := strings.IndexAny(vrn, "ACGTUacgtudi")
+	if idx < 1 {
+		return reportError("Unrecognized variant")
+	}
+	loc := vrn[:idx]
+	op := vrn[idx:]
+
+	fr, to := SplitInTwoLeft(loc, "_")
+	if to == "" {
+		to = fr
+	}
+	start, ok := convertPos(fr)
+	if !ok {
+		return reportError("Unsupported position '" + fr + "'")
+	}
+	stop, ok := convertPos(to)
+	if !ok {
+		return reportError("Unsupported position '" + to + "'")
+	}
+	if start < 0 || stop >= ln || stop < start {
+		return reportError("Position out of range")
+	}
+
+	ref := seq[start : stop+1]
+
+	// explicit sequence must match the transcript, as with SPDI deletions in PredictVariantEffect
+	refMismatch := func(del string) bool {
+		return del != "" && !IsAllDigits(del) && strings.ToUpper(del) != ref
+	}
+	mismatch := func(del string) (int, string, string, bool) {
+		return reportError("Reference mismatch, transcript has " + ref + " instead of " + strings.ToUpper(del))
+	}
+
+	switch {
+	case strings.HasPrefix(op, "delins"):
+		return start, ref, strings.ToUpper(strings.TrimPrefix(op, "delins")), true
+	case strings.HasPrefix(op, "del"):
+		if del := strings.TrimPrefix(op, "del"); refMismatch(del) {
+			return mismatch(del)
+		}
+		return start, ref, "", true
+	case strings.HasPrefix(op, "dup"):
+		if dup := strings.TrimPrefix(op, "dup"); refMismatch(dup) {
+			return mismatch(dup)
+		}
+		return stop + 1, "", ref, true
+	case strings.HasPrefix(op, "ins"):
+		// insertion lies between two flanking positions
+		if stop != start+1 {
+			return reportError("Insertion must be between adjacent positions")
+		}
+		return stop, "", strings.ToUpper(strings.TrimPrefix(op, "ins")), true
+	case strings.Contains(op, ">"):
+		lft, rgt := SplitInTwoLeft(op, ">")
+		if len(rgt) != 1 || start != stop {
+			return reportError("Unrecognized substitution")
+		}
+		if refMismatch(lft) {
+			return mismatch(lft)
+		}
+		return start, ref, strings.ToUpper(rgt), true
+	}
+
+	return reportError("Unrecognized variant operation")
+}
+
+// PredictVariantEffect applies a variant at a 0-based transcript position, re-translates
+// the coding region, and classifies the change as Synonymous, Missense, Termination
+// (nonsense), Extension (stop loss), Frameshift, Deletion, Duplication, Insertion, Indel,
+// StartLoss, or UTR, with predicted protein HGVS in p.(Arg97Ter) style. The CDS stop is exclusive
+// and includes the stop codon. Deleted bases that do not match the transcript are reported as
+// a reference mismatch.
+func PredictVariantEffect(seq string, cdsFrom, cdsTo, genCode, pos int, del, ins string) VariantEffect {
+
+	seq = strings.ToUpper(seq)
+	del = strings.ToUpper(del)
+	ins = strings.ToUpper(ins)
+
+	ln := len(seq)
+
+	if cdsFrom < 0 || cdsTo > ln || cdsTo-cdsFrom < 3 || pos < 0 || pos+len(del) > ln {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Variant or coding region out of range%s\n", INVT, LOUD, INIT)
+		return VariantEffect{}
+	}
+
+	// deleted bases must match the transcript, otherwise the variant refers to another version
+	if seq[pos:pos+len(del)] != del {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Reference mismatch at transcript offset %d, expected '%s', found '%s'%s\n", INVT, LOUD, pos, del, seq[pos:pos+len(del)], INIT)
+		return VariantEffect{}
+	}
+
+	if del == ins {
+		return VariantEffect{Type: hgvsType[SYN], Protein: "p.(=)"}
+	}
+
+	// variant entirely outside of coding region, insertion at CDS boundary is also outside
+	if pos+len(del) <= cdsFrom || pos >= cdsTo {
+		return VariantEffect{Type: effectUTR, Protein: "p.(=)"}
+	}
+
+	refStart := SetCodonState(int(seq[cdsFrom]), int(seq[cdsFrom+1]), int(seq[cdsFrom+2]))
+	hasStart := IsOrfStart(genCode, refStart)
+
+	// change that removes the first base of the coding region
+	if pos < cdsFrom {
+		if hasStart {
+			return VariantEffect{Type: effectStartLoss, Protein: "p.(Met1?)"}
+		}
+		return VariantEffect{Type: hgvsType[INDEL], Protein: "p.?"}
+	}
+
+	mut := seq[:pos] + ins + seq[pos+len(del):]
+
+	// translate reference coding region, and variant through end of transcript to find new stop
+	refPrt := TranslateCdRegion(seq[cdsFrom:cdsTo], genCode, 0, true, false, false, false, true, "")
+	mutPrt := TranslateCdRegion(mut[cdsFrom:], genCode, 0, true, false, false, false, true, "")
+
+	// remove unknown residue from trailing incomplete codon
+	if !strings.HasSuffix(mutPrt, "*") {
+		mutPrt = strings.TrimRight(mutPrt, "X")
+	}
+
+	// change in initiation codon
+	if pos < cdsFrom+3 {
+		if hasStart {
+			lost := len(mut) < cdsFrom+3
+			if !lost {
+				mutStart := SetCodonState(int(mut[cdsFrom]), int(mut[cdsFrom+1]), int(mut[cdsFrom+2]))
+				lost = !IsOrfStart(genCode, mutStart)
+			}
+			if lost {
+				return VariantEffect{Type: effectStartLoss, Protein: "p.(Met1?)"}
+			}
+			// start codon replaced by alternative start still initiates with methionine
+			if len(mutPrt) > 0 {
+				mutPrt = "M" + mutPrt[1:]
+			}
+			if len(refPrt) > 0 {
+				refPrt = "M" + refPrt[1:]
+			}
+		}
+	}
+
+	// find first differing residue
+	i := 0
+	for i < len(refPrt) && i < len(mutPrt) && refPrt[i] == mutPrt[i] {
+		i++
+	}
+
+	aa := func(k int) string {
+		return threeLetterResidue(refPrt[k]) + strconv.Itoa(k+1)
+	}
+
+	// extension reports loss of the stop codon at residue k, in frame or after a frameshift
+	extension := func(k int) VariantEffect {
+		if k >= len(mutPrt) {
+			return VariantEffect{Type: hgvsType[EXT], Protein: "p.(" + aa(k) + "extTer?)"}
+		}
+		ter := "?"
+		if strings.HasSuffix(mutPrt, "*") {
+			ter = strconv.Itoa(len(mutPrt) - 1 - k)
+		}
+		return VariantEffect{Type: hgvsType[EXT], Protein: "p.(" + aa(k) + threeLetterResidue(mutPrt[k]) + "extTer" + ter + ")"}
+	}
+
+	// frameshift within coding region
+	if (len(ins)-len(del))%3 != 0 {
+
+		if i >= len(refPrt) {
+			// change only affects bases after the stop codon
+			return VariantEffect{Type: hgvsType[SYN], Protein: "p.(=)"}
+		}
+		if refPrt[i] == '*' {
+			// new frame only changes the stop codon
+			return extension(i)
+		}
+		if i >= len(mutPrt) {
+			return VariantEffect{Type: hgvsType[FS], Protein: "p.(" + aa(i) + "fs)"}
+		}
+		if mutPrt[i] == '*' {
+			// immediate stop
+			return VariantEffect{Type: hgvsType[FS], Protein: "p.(" + aa(i) + "Ter)"}
+		}
+		ter := "?"
+		if strings.HasSuffix(mutPrt, "*") {
+			ter = strconv.Itoa(len(mutPrt) - i)
+		}
+		return VariantEffect{Type: hgvsType[FS], Protein: "p.(" + aa(i) + threeLetterResidue(mutPrt[i]) + "fsTer" + ter + ")"}
+	}
+
+	if refPrt == mutPrt {
+		k := (pos - cdsFrom) / 3
+		if k >= len(refPrt) {
+			k = len(refPrt) - 1
+		}
+		return VariantEffect{Type: hgvsType[SYN], Protein: "p.(" + aa(k) + "=)"}
+	}
+
+	// loss of stop codon extends protein
+	if i < len(refPrt) && refPrt[i] == '*' {
+		return extension(i)
+	}
+
+	// premature stop codon
+	if i < len(mutPrt) && mutPrt[i] == '*' {
+		return VariantEffect{Type: hgvsType[TRM], Protein: "p.(" + aa(i) + "Ter)"}
+	}
+
+	// trim common suffix to isolate in-frame change
+	rgtRef := len(refPrt)
+	rgtMut := len(mutPrt)
+	for rgtRef > i && rgtMut > i && refPrt[rgtRef-1] == mutPrt[rgtMut-1] {
+		rgtRef--
+		rgtMut--
+	}
+	refSeg := refPrt[i:rgtRef]
+	mutSeg := mutPrt[i:rgtMut]
+
+	span := func(fr, to int) string {
+		if fr == to {
+			return aa(fr)
+		}
+		return aa(fr) + "_" + aa(to)
+	}
+
+	switch {
+	case len(refSeg) == 1 && len(mutSeg) == 1:
+		return VariantEffect{Type: hgvsType[MIS], Protein: "p.(" + aa(i) + threeLetterResidue(mutSeg[0]) + ")"}
+	case len(mutSeg) == 0:
+		return VariantEffect{Type: hgvsType[DEL], Protein: "p.(" + span(i, rgtRef-1) + "del)"}
+	case len(refSeg) == 0 && i >= len(mutSeg) && refPrt[i-len(mutSeg):i] == mutSeg:
+		// prefix scan already shifted the insertion 3', so a copy of the preceding residues is a duplication
+		return VariantEffect{Type: hgvsType[DUP], Protein: "p.(" + span(i-len(mutSeg), i-1) + "dup)"}
+	case len(refSeg) == 0 && i > 0:
+		return VariantEffect{Type: hgvsType[INS], Protein: "p.(" + span(i-1, i) + "ins" + threeLetterProtein(mutSeg) + ")"}
+	}
+
+	return VariantEffect{Type: hgvsType[INDEL], Protein: "p.(" + span(i, rgtRef-1) + "delins" + threeLetterProtein(mutSeg) + ")"}
+}
@@ -0,0 +1,188 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  facet.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// FACETED COUNTS OF QUERY RESULTS
+
+// Facet counts are computed entirely from the postings. Every term in the
+// facet field's vocabulary is intersected with the query result, and the
+// matching UIDs are combined under the term's bucket, so a record is only
+// counted once per bucket. Year fields produce a histogram in year order.
+// Hierarchical codes with facet="branch" in the schema, and the MeSH [TREE]
+// field by default, are grouped by the first component of each code, e.g.,
+// "c14 907 617 812" is counted under "C14".
+
+// facetBucket returns the value under which an indexed term is counted
+func facetBucket(fld *IndexField, field, term string) string {
+
+	facet := "terms"
+	if fld != nil && fld.Facet != "" {
+		facet = fld.Facet
+	} else if field == "TREE" {
+		facet = "branch"
+	}
+
+	if facet == "branch" {
+		if pos := strings.IndexAny(term, " ."); pos > 0 {
+			term = term[:pos]
+		}
+		return strings.ToUpper(term)
+	}
+
+	return term
+}
+
+// facetCounts returns the number of result UIDs in each bucket of a field
+func facetCounts(base, field string, fld *IndexField, result *PostingList) map[string]int {
+
+	var terms []string
+
+	if td := readTermDictionary(base, field); td != nil {
+		terms = td.terms
+	} else {
+		terms = readFieldTerms(base, field)
+	}
+
+	inp := make(chan string, len(terms))
+	for _, term := range terms {
+		inp <- term
+	}
+	close(inp)
+
+	var (
+		wg    sync.WaitGroup
+		mlock sync.Mutex
+	)
+
+	buckets := make(map[string]*PostingList)
+
+	// each server accumulates its own buckets, then merges them into the shared map
+	countServer := func() {
+
+		defer wg.Done()
+
+		local := make(map[string]*PostingList)
+
+		for term := range inp {
+
+			data, _ := getPostingList(base, term, field, true)
+			if data.Len() < 1 {
+				continue
+			}
+
+			hits := intersectIDs(result, data)
+			if hits.Len() < 1 {
+				continue
+			}
+
+			key := facetBucket(fld, field, term)
+			local[key] = combineIDs(local[key], hits)
+
+			runtime.Gosched()
+		}
+
+		mlock.Lock()
+		for key, hits := range local {
+			buckets[key] = combineIDs(buckets[key], hits)
+		}
+		mlock.Unlock()
+	}
+
+	numServers := NumServe()
+
+	for i := 0; i < numServers; i++ {
+		wg.Add(1)
+		go countServer()
+	}
+
+	wg.Wait()
+
+	counts := make(map[string]int)
+	for key, hits := range buckets {
+		counts[key] = hits.Len()
+	}
+
+	return counts
+}
+
+// ProcessFacets evaluates a query and prints, for each facet field, the number of matching records per value
+func ProcessFacets(base, phrase, fields string, xact, titl, rlxd, deStop bool) int {
+
+	if phrase == "" || fields == "" {
+		return 0
+	}
+
+	// field names, aliases, and tokenizer policies
+	schema := ReadIndexSchema(base)
+
+	root := buildQueryTree(phrase, xact, titl, rlxd, deStop, schema)
+
+	result, _ := resolveQuery(base, root, false, false)
+	if result.Len() < 1 {
+		return 0
+	}
+
+	count := 0
+
+	for _, item := range strings.FieldsFunc(fields, func(ch rune) bool { return ch == ',' || unicode.IsSpace(ch) }) {
+
+		name, fld := schema.resolveField(item)
+		if name == "PIPE" || (fld == nil && schema.strict) {
+			fmt.Fprintf(os.Stderr, "\nERROR: Facet field '%s' is not in the index schema\n", item)
+			os.Exit(1)
+		}
+
+		counts := facetCounts(base, name, fld, result)
+
+		var keys []string
+		for key := range counts {
+			keys = append(keys, key)
+		}
+
+		if (fld != nil && fld.Tokenizer == "year") || (fld == nil && name == "YEAR") {
+			// histogram in chronological order
+			sort.Strings(keys)
+		} else {
+			// most frequent values first
+			sort.Slice(keys, func(i, j int) bool {
+				if counts[keys[i]] != counts[keys[j]] {
+					return counts[keys[i]] > counts[keys[j]]
+				}
+				return keys[i] < keys[j]
+			})
+		}
+
+		for _, key := range keys {
+			fmt.Fprintf(os.Stdout, "%s\t%s\t%d\n", name, key, counts[key])
+		}
+
+		count += len(keys)
+	}
+
+	return count
+}
@@ -0,0 +1,237 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  faidx.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// The .fai index layout follows samtools faidx, at http://www.htslib.org/doc/faidx.html
+
+// FastaIndexEntry is one line of a .fai index
+type FastaIndexEntry struct {
+	Name      string
+	Length    int
+	Offset    int64
+	LineBases int
+	LineWidth int
+}
+
+// BuildFastaIndex scans a multi-FASTA file and records the position and line layout of each sequence
+func BuildFastaIndex(inp io.Reader) []FastaIndexEntry {
+
+	if inp == nil {
+		return nil
+	}
+
+	rdr := bufio.NewReaderSize(inp, 65536)
+
+	var entries []FastaIndexEntry
+
+	var curr *FastaIndexEntry
+	var offset int64
+	lastBases := 0
+	shortLine := false
+	ok := true
+
+	for {
+		line, err := rdr.ReadString('\n')
+		width := len(line)
+		if width == 0 && err != nil {
+			break
+		}
+
+		text := strings.TrimRight(line, "\r\n")
+
+		if strings.HasPrefix(text, ">") {
+			name := strings.TrimPrefix(text, ">")
+			if flds := strings.Fields(name); len(flds) > 0 {
+				name = flds[0]
+			}
+			entries = append(entries, FastaIndexEntry{Name: name, Offset: offset + int64(width)})
+			curr = &entries[len(entries)-1]
+			lastBases = 0
+			shortLine = false
+
+		} else if curr != nil && text != "" {
+			bases := len(text)
+			// final line may lack a newline at end of file
+			unterminated := err != nil && width == bases
+			if curr.LineBases == 0 {
+				curr.LineBases = bases
+				curr.LineWidth = width
+			} else if shortLine || bases > curr.LineBases || (bases == curr.LineBases && width != curr.LineWidth && !unterminated) {
+				// only the last line of a sequence may be shorter
+				fmt.Fprintf(os.Stderr, "%s ERROR: %s Inconsistent line length in sequence '%s'%s\n", INVT, LOUD, curr.Name, INIT)
+				ok = false
+			}
+			if bases < curr.LineBases {
+				shortLine = true
+			}
+			curr.Length += bases
+			lastBases = bases
+
+		} else if curr != nil && lastBases > 0 {
+			// blank line ends sequence data
+			shortLine = true
+		}
+
+		offset += int64(width)
+
+		if err != nil {
+			break
+		}
+	}
+
+	if !ok {
+		return nil
+	}
+
+	return entries
+}
+
+// WriteFastaIndex writes .fai lines with tab-delimited name, length, offset, bases per line, and bytes per line
+func WriteFastaIndex(out io.Writer, entries []FastaIndexEntry) {
+
+	for _, ent := range entries {
+		fmt.Fprintf(out, "%s\t%d\t%d\t%d\t%d\n", ent.Name, ent.Length, ent.Offset, ent.LineBases, ent.LineWidth)
+	}
+}
+
+// ReadFastaIndex loads a .fai file, keeping sequences in file order
+func ReadFastaIndex(inp io.Reader) []FastaIndexEntry {
+
+	if inp == nil {
+		return nil
+	}
+
+	var entries []FastaIndexEntry
+
+	scanr := bufio.NewScanner(inp)
+
+	for scanr.Scan() {
+
+		cols := strings.Split(scanr.Text(), "\t")
+		if len(cols) < 5 {
+			continue
+		}
+
+		length, err1 := strconv.Atoi(cols[1])
+		offset, err2 := strconv.ParseInt(cols[2], 10, 64)
+		lineBases, err3 := strconv.Atoi(cols[3])
+		lineWidth, err4 := strconv.Atoi(cols[4])
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			fmt.Fprintf(os.Stderr, "%s WARNING: %s Skipping malformed index line '%s'%s\n", INVT, LOUD, scanr.Text(), INIT)
+			continue
+		}
+
+		entries = append(entries, FastaIndexEntry{Name: cols[0], Length: length, Offset: offset, LineBases: lineBases, LineWidth: lineWidth})
+	}
+
+	return entries
+}
+
+// ParseRegion splits a samtools-style region, e.g., "chr1:1,000-2,000", "chr1:1000", or "chr1",
+// returning 1-based inclusive coordinates, with 0 for an open end
+func ParseRegion(str string) (string, int, int, bool) {
+
+	str = strings.TrimSpace(str)
+
+	idx := strings.LastIndex(str, ":")
+	if idx < 0 {
+		return str, 0, 0, str != ""
+	}
+
+	name := str[:idx]
+	rng := strings.Replace(str[idx+1:], ",", "", -1)
+
+	fr, to := SplitInTwoLeft(rng, "-")
+
+	from, err := strconv.Atoi(fr)
+	if err != nil {
+		// colon is part of sequence name
+		return str, 0, 0, true
+	}
+
+	if to == "" {
+		return name, from, 0, from > 0
+	}
+
+	max, err := strconv.Atoi(to)
+	if err != nil || max < from {
+		return name, 0, 0, false
+	}
+
+	return name, from, max, from > 0
+}
+
+// FetchFastaRegion reads bases from..to (1-based, inclusive) of an indexed sequence without loading the whole file,
+// reporting an error and returning an empty string if the region extends past the end of the sequence
+func FetchFastaRegion(inp io.ReaderAt, ent FastaIndexEntry, from, to int) string {
+
+	if inp == nil || ent.LineBases < 1 {
+		return ""
+	}
+
+	if from > ent.Length || to > ent.Length {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Region %d-%d is outside sequence '%s' of length %d%s\n", INVT, LOUD, from, to, ent.Name, ent.Length, INIT)
+		return ""
+	}
+
+	if from < 1 {
+		from = 1
+	}
+	if to < 1 {
+		to = ent.Length
+	}
+	if from > to {
+		return ""
+	}
+
+	// byte position of a 0-based base offset, skipping line terminators
+	bytePos := func(pos int) int64 {
+		return ent.Offset + int64(pos/ent.LineBases)*int64(ent.LineWidth) + int64(pos%ent.LineBases)
+	}
+
+	start := bytePos(from - 1)
+	stop := bytePos(to-1) + 1
+
+	data := make([]byte, stop-start)
+	n, err := inp.ReadAt(data, start)
+	if err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s %s%s\n", INVT, LOUD, err.Error(), INIT)
+		return ""
+	}
+	data = data[:n]
+
+	var buffer strings.Builder
+	buffer.Grow(to - from + 1)
+
+	for _, ch := range data {
+		if ch != '\n' && ch != '\r' {
+			buffer.WriteByte(ch)
+		}
+	}
+
+	return buffer.String()
+}
@@ -0,0 +1,404 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  fuzzy.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SPELLING SUGGESTIONS AND FUZZY TERM MATCHING
+
+// Candidate terms within a small edit distance of a query word are drawn
+// from the field's term dictionary, using the trigram lists to discard
+// terms that share too few grams to be close. Without a dictionary, only
+// the term list in the word's own posting directory is examined, so errors
+// in the first few characters cannot be corrected. Surviving candidates are
+// ranked by Damerau-Levenshtein distance, then by document frequency.
+//
+// In a query, "imatinb~fuzzy" (or "~fuzzy2" for an explicit distance)
+// searches each word of the preceding phrase as the union of its close
+// variants. The normalized clause carries the distance as "imatinb~1".
+
+const (
+	// closest variants used in place of a ~fuzzy word
+	fuzzyLimit = 50
+	// alternatives printed per word by rchive -suggest
+	suggestLimit = 10
+)
+
+// fuzzyEdits chooses the maximum edit distance for a word from its length
+func fuzzyEdits(word string) int {
+
+	num := utf8.RuneCountInString(word)
+
+	switch {
+	case num < 4:
+		return 0
+	case num < 8:
+		return 1
+	}
+
+	return 2
+}
+
+// editDistance returns the optimal string alignment distance between two words,
+// counting adjacent transpositions as one edit, or max+1 once the distance exceeds max
+func editDistance(a, b []rune, max int) int {
+
+	la, lb := len(a), len(b)
+
+	if la-lb > max || lb-la > max {
+		return max + 1
+	}
+
+	prev2 := make([]int, lb+1)
+	prev := make([]int, lb+1)
+	curr := make([]int, lb+1)
+
+	for j := 0; j <= lb; j++ {
+		prev[j] = j
+	}
+
+	for i := 1; i <= la; i++ {
+
+		curr[0] = i
+		low := i
+
+		for j := 1; j <= lb; j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			val := prev[j-1] + cost
+			if prev[j]+1 < val {
+				val = prev[j] + 1
+			}
+			if curr[j-1]+1 < val {
+				val = curr[j-1] + 1
+			}
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] && prev2[j-2]+1 < val {
+				val = prev2[j-2] + 1
+			}
+			curr[j] = val
+			if val < low {
+				low = val
+			}
+		}
+
+		// row minimum never decreases, so stop as soon as every alignment is too distant
+		if low > max {
+			return max + 1
+		}
+
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	if prev[lb] > max {
+		return max + 1
+	}
+
+	return prev[lb]
+}
+
+// parseFuzzyTerm splits a normalized "word~N" term into the word and its maximum edit distance
+func parseFuzzyTerm(term string) (string, int, bool) {
+
+	pos := strings.LastIndex(term, "~")
+	if pos < 1 || pos == len(term)-1 {
+		return term, 0, false
+	}
+
+	num, err := strconv.Atoi(term[pos+1:])
+	if err != nil || num < 1 {
+		return term, 0, false
+	}
+
+	return term[:pos], num, true
+}
+
+// fuzzyClause appends the edit distance marker to the searchable words of a normalized clause,
+// or to the last word when the entire clause is indexed as a single term
+func fuzzyClause(cls string, edits int, whole bool) string {
+
+	field := ""
+	if strings.HasSuffix(cls, "]") {
+		pos := strings.Index(cls, "[")
+		if pos >= 0 {
+			field = " " + cls[pos:]
+			cls = strings.TrimSpace(cls[:pos])
+		}
+	}
+
+	words := strings.Fields(cls)
+	if len(words) < 1 {
+		return cls + field
+	}
+
+	mark := func(word, txt string) string {
+		// stop words, wildcards, and words too short to correct are left alone
+		if strings.HasPrefix(txt, "+") || strings.ContainsAny(txt, "*$") || fuzzyEdits(txt) < 1 {
+			return word
+		}
+		num := edits
+		if num < 1 {
+			num = fuzzyEdits(txt)
+		}
+		if num < 1 {
+			return word
+		}
+		return word + "~" + strconv.Itoa(num)
+	}
+
+	if whole {
+		last := len(words) - 1
+		words[last] = mark(words[last], strings.Join(words, " "))
+	} else {
+		for i, word := range words {
+			words[i] = mark(word, word)
+		}
+	}
+
+	return strings.Join(words, " ") + field
+}
+
+// similar returns dictionary terms that share enough trigrams with the word to be within the edit distance
+func (td *termDictionary) similar(word string, edits int) []string {
+
+	acc := make(map[string]bool)
+	termGrams("$"+word+"$", acc)
+
+	// an edit alters at most three grams, or four for a transposition,
+	// grams are bytes so multi-byte characters need a full scan
+	need := len(acc) - 4*edits
+	if need < 1 || utf8.RuneCountInString(word) != len(word) {
+		return td.terms
+	}
+
+	tally := make(map[uint32]int)
+	for gm := range acc {
+		for _, ord := range td.gramList(gm) {
+			tally[ord]++
+		}
+	}
+
+	var res []string
+	for ord, num := range tally {
+		if num >= need {
+			res = append(res, td.terms[ord])
+		}
+	}
+
+	return res
+}
+
+// similarTerms returns indexed terms within the edit distance of a word, with their document counts,
+// closest and most frequent first
+func similarTerms(prom, word, field string, edits int) ([]string, []int) {
+
+	word = strings.Replace(word, "_", " ", -1)
+
+	var cands []string
+
+	if td := readTermDictionary(prom, field); td != nil {
+		cands = td.similar(word, edits)
+	} else {
+		cands, _ = readTermCounts(prom, word, field)
+	}
+
+	type termCounts struct {
+		strs   []string
+		sizeOf func(R int) int
+	}
+
+	// term lists are loaded once per posting directory
+	loaded := make(map[string]termCounts)
+
+	frequency := func(str string) int {
+
+		var arry [516]rune
+		dpath, key := PostingPath(prom, field, str, arry)
+		if dpath == "" {
+			return 0
+		}
+
+		tc, ok := loaded[path.Join(dpath, key)]
+		if !ok {
+			tc.strs, tc.sizeOf = readTermCounts(prom, str, field)
+			loaded[path.Join(dpath, key)] = tc
+		}
+
+		R := sort.SearchStrings(tc.strs, str)
+		if R < len(tc.strs) && tc.strs[R] == str {
+			return tc.sizeOf(R)
+		}
+
+		return 0
+	}
+
+	type fuzzyMatch struct {
+		term  string
+		count int
+		dist  int
+	}
+
+	var matches []fuzzyMatch
+
+	src := []rune(word)
+
+	for _, str := range cands {
+		dist := editDistance(src, []rune(str), edits)
+		if dist > edits {
+			continue
+		}
+		num := frequency(str)
+		if num < 1 {
+			continue
+		}
+		matches = append(matches, fuzzyMatch{str, num, dist})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].dist != matches[j].dist {
+			return matches[i].dist < matches[j].dist
+		}
+		if matches[i].count != matches[j].count {
+			return matches[i].count > matches[j].count
+		}
+		return matches[i].term < matches[j].term
+	})
+
+	strs := make([]string, len(matches))
+	sizes := make([]int, len(matches))
+
+	for i, mt := range matches {
+		strs[i] = mt.term
+		sizes[i] = mt.count
+	}
+
+	return strs, sizes
+}
+
+// getFuzzyPostingIDs combines the postings and positions of the closest variants of a "word~N" term
+func getFuzzyPostingIDs(prom, term, field string, simple bool) ([]int64, [][]int32) {
+
+	word, edits, _ := parseFuzzyTerm(term)
+
+	terms, _ := similarTerms(prom, word, field, edits)
+	if len(terms) > fuzzyLimit {
+		terms = terms[:fuzzyLimit]
+	}
+
+	return mergeTermPostingIDs(prom, terms, field, simple)
+}
+
+// ProcessSuggest prints more frequent indexed terms close in spelling to each query word
+func ProcessSuggest(base, phrase string, rlxd, deStop bool) int {
+
+	if phrase == "" {
+		return 0
+	}
+
+	// field names, aliases, and tokenizer policies
+	schema := ReadIndexSchema(base)
+
+	root := buildQueryTree(phrase, false, false, rlxd, deStop, schema)
+
+	count := 0
+
+	suggest := func(cls string) {
+
+		field := ""
+		if strings.HasSuffix(cls, "]") {
+			pos := strings.Index(cls, "[")
+			if pos >= 0 {
+				field = strings.TrimSuffix(cls[pos+1:], "]")
+				cls = strings.TrimSpace(cls[:pos])
+			}
+		}
+
+		name, fld := schema.resolveField(field)
+		if fld == nil || fld.Tokenizer == "year" {
+			return
+		}
+
+		words := strings.Fields(cls)
+		if fld.Tokenizer != "words" {
+			// multi-word terms are indexed as a unit
+			words = []string{strings.Join(words, " ")}
+		}
+
+		for _, word := range words {
+
+			word, edits, ok := parseFuzzyTerm(word)
+			if !ok {
+				edits = fuzzyEdits(word)
+			}
+			if edits < 1 || strings.HasPrefix(word, "+") || strings.ContainsAny(word, "*$") {
+				continue
+			}
+			word = strings.Replace(word, "_", " ", -1)
+
+			data, _ := getPostingIDs(base, word, name, true)
+			own := len(data)
+
+			fmt.Fprintf(os.Stdout, "%s\t%d\n", word, own)
+
+			strs, sizes := similarTerms(base, word, name, edits)
+
+			num := 0
+			for i, str := range strs {
+				// only offer alternatives that would find more documents
+				if str == word || sizes[i] <= own {
+					continue
+				}
+				fmt.Fprintf(os.Stdout, "  %d\t%s\n", sizes[i], str)
+				num++
+				if num >= suggestLimit {
+					break
+				}
+			}
+
+			count += num
+		}
+	}
+
+	var visit func(node *queryNode)
+
+	visit = func(node *queryNode) {
+		if node == nil {
+			return
+		}
+		if node.Kind == qryPhrase {
+			suggest(node.Clause)
+			return
+		}
+		for _, chld := range node.Children {
+			visit(chld)
+		}
+	}
+
+	visit(root)
+
+	return count
+}
@@ -742,6 +742,12 @@ func GenBankConverter(inp io.Reader) <-chan string {
 								qual = qual[:idx]
 							}
 
+							// word longer than the line was split at fixed width, rejoin without a space
+							prev := strings.TrimPrefix(line, twentyonespaces)
+							isSplit := func() bool {
+								return len(prev) == 58 && !strings.Contains(prev, " ")
+							}
+
 							for {
 								line = nextLine()
 								row++
@@ -754,11 +760,12 @@ func GenBankConverter(inp io.Reader) <-chan string {
 									break
 								}
 								// append subsequent line to value and continue with loop
-								if qual == "transcription" || qual == "translation" || qual == "peptide" || qual == "anticodon" {
+								if qual == "transcription" || qual == "translation" || qual == "peptide" || qual == "anticodon" || isSplit() {
 									val += strings.TrimSpace(txt)
 								} else {
 									val += " " + strings.TrimSpace(txt)
 								}
+								prev = txt
 							}
 
 							rec.WriteString("          <INSDQualifier>\n")
@@ -975,3 +982,377 @@ func GenBankConverter(inp io.Reader) <-chan string {
 
 	return out
 }
+
+// qualifiers whose values are not enclosed in quotation marks
+var unquotedQualifiers = map[string]bool{
+	"anticodon":        true,
+	"citation":         true,
+	"codon_start":      true,
+	"compare":          true,
+	"direction":        true,
+	"estimated_length": true,
+	"mod_base":         true,
+	"number":           true,
+	"rpt_type":         true,
+	"rpt_unit_range":   true,
+	"tag_peptide":      true,
+	"transl_except":    true,
+	"transl_table":     true,
+}
+
+// qualifiers whose values are wrapped at fixed width instead of at spaces
+var solidQualifiers = map[string]bool{
+	"anticodon":     true,
+	"peptide":       true,
+	"transcription": true,
+	"translation":   true,
+}
+
+// wrapFlatText breaks text into lines of at most 79 columns at spaces, or after commas if isLoc,
+// splitting words longer than the available width at fixed width, and indents continuation lines
+func wrapFlatText(buffer *strings.Builder, first, indent, text string, isLoc bool) {
+
+	const lineWidth = 79
+
+	width := lineWidth - len(indent)
+	if width < 10 {
+		width = 10
+	}
+
+	prefix := first
+	for {
+		if len(text) <= width {
+			buffer.WriteString(prefix)
+			buffer.WriteString(text)
+			buffer.WriteString("\n")
+			return
+		}
+
+		// find last break position that fits
+		brk := -1
+		if isLoc {
+			brk = strings.LastIndex(text[:width], ",")
+			if brk >= 0 {
+				brk++
+			}
+		} else {
+			brk = strings.LastIndex(text[:width+1], " ")
+		}
+		if brk <= 0 {
+			brk = width
+		}
+
+		line := strings.TrimRight(text[:brk], " ")
+		text = strings.TrimLeft(text[brk:], " ")
+
+		buffer.WriteString(prefix)
+		buffer.WriteString(line)
+		buffer.WriteString("\n")
+
+		if text == "" {
+			return
+		}
+
+		prefix = indent
+	}
+}
+
+// INSDSeqToGenBank formats an INSDSeq XML record as a GenBank or GenPept flatfile
+func INSDSeqToGenBank(text string) string {
+
+	const twelvespaces = "            "
+	const twentyonespaces = "                     "
+
+	pat := ParseRecord(text, "INSDSeq")
+	if pat == nil {
+		return ""
+	}
+
+	findChild := func(node *XMLNode, name string) *XMLNode {
+		if node == nil {
+			return nil
+		}
+		for chld := node.Children; chld != nil; chld = chld.Next {
+			if chld.Name == name {
+				return chld
+			}
+		}
+		return nil
+	}
+
+	contentOf := func(node *XMLNode, name string) string {
+		chld := findChild(node, name)
+		if chld == nil {
+			return ""
+		}
+		return html.UnescapeString(strings.TrimSpace(chld.Contents))
+	}
+
+	// contentsOf collects values of repeated elements within a wrapper
+	contentsOf := func(node *XMLNode, wrapper, name string) []string {
+		var arry []string
+		wrp := findChild(node, wrapper)
+		if wrp == nil {
+			return nil
+		}
+		for chld := wrp.Children; chld != nil; chld = chld.Next {
+			if chld.Name == name {
+				arry = append(arry, html.UnescapeString(strings.TrimSpace(chld.Contents)))
+			}
+		}
+		return arry
+	}
+
+	var buffer strings.Builder
+
+	writeField := func(label, value string) {
+		wrapFlatText(&buffer, fmt.Sprintf("%-12s", label), twelvespaces, value, false)
+	}
+
+	// LOCUS line
+
+	locus := contentOf(pat, "INSDSeq_locus")
+	length := contentOf(pat, "INSDSeq_length")
+	moltype := contentOf(pat, "INSDSeq_moltype")
+	topology := contentOf(pat, "INSDSeq_topology")
+	division := contentOf(pat, "INSDSeq_division")
+	update := contentOf(pat, "INSDSeq_update-date")
+
+	if topology == "" {
+		topology = "linear"
+	}
+
+	isProtein := (moltype == "AA")
+
+	nameLen := locus
+	pad := 28 - len(locus) - len(length)
+	if pad < 1 {
+		pad = 1
+	}
+	nameLen += strings.Repeat(" ", pad) + length
+
+	if isProtein {
+		buffer.WriteString(fmt.Sprintf("LOCUS       %s aa            %-8s %s %s\n", nameLen, topology, division, update))
+	} else {
+		// strandedness prefix is only needed when it differs from the molecule type default
+		strand := ""
+		switch contentOf(pat, "INSDSeq_strandedness") {
+		case "single":
+			if !strings.HasSuffix(moltype, "RNA") {
+				strand = "ss-"
+			}
+		case "double":
+			if !strings.HasSuffix(moltype, "DNA") {
+				strand = "ds-"
+			}
+		case "mixed":
+			strand = "ms-"
+		}
+		buffer.WriteString(fmt.Sprintf("LOCUS       %s bp %3s%-6s  %-8s %s %s\n", nameLen, strand, moltype, topology, division, update))
+	}
+
+	// DEFINITION through SOURCE
+
+	if def := contentOf(pat, "INSDSeq_definition"); def != "" {
+		if !strings.HasSuffix(def, ".") {
+			def += "."
+		}
+		writeField("DEFINITION", def)
+	}
+
+	accn := contentOf(pat, "INSDSeq_primary-accession")
+	if accn == "" {
+		accn = locus
+	}
+	secondaries := contentsOf(pat, "INSDSeq_secondary-accessions", "INSDSecondary-accn")
+	writeField("ACCESSION", strings.TrimSpace(accn+" "+strings.Join(secondaries, " ")))
+
+	if accnver := contentOf(pat, "INSDSeq_accession-version"); accnver != "" {
+		gi := ""
+		for _, sid := range contentsOf(pat, "INSDSeq_other-seqids", "INSDSeqid") {
+			if strings.HasPrefix(sid, "gi|") {
+				gi = strings.TrimPrefix(sid, "gi|")
+			}
+		}
+		if gi != "" {
+			accnver += "  GI:" + gi
+		}
+		writeField("VERSION", accnver)
+	}
+
+	if xrefs := findChild(pat, "INSDSeq_xrefs"); xrefs != nil {
+		label := "DBLINK"
+		for chld := xrefs.Children; chld != nil; chld = chld.Next {
+			if chld.Name != "INSDXref" {
+				continue
+			}
+			writeField(label, contentOf(chld, "INSDXref_dbname")+": "+contentOf(chld, "INSDXref_id"))
+			label = ""
+		}
+	}
+
+	if srcdb := contentOf(pat, "INSDSeq_source-db"); srcdb != "" {
+		writeField("DBSOURCE", srcdb)
+	}
+
+	keywords := contentsOf(pat, "INSDSeq_keywords", "INSDKeyword")
+	writeField("KEYWORDS", strings.Join(keywords, "; ")+".")
+
+	if src := contentOf(pat, "INSDSeq_source"); src != "" {
+		writeField("SOURCE", src)
+	}
+	if org := contentOf(pat, "INSDSeq_organism"); org != "" {
+		buffer.WriteString("  ORGANISM  " + org + "\n")
+		if tax := contentOf(pat, "INSDSeq_taxonomy"); tax != "" {
+			writeField("", tax+".")
+		}
+	}
+
+	// REFERENCE blocks
+
+	unit := "bases"
+	if isProtein {
+		unit = "residues"
+	}
+
+	if refs := findChild(pat, "INSDSeq_references"); refs != nil {
+		for ref := refs.Children; ref != nil; ref = ref.Next {
+			if ref.Name != "INSDReference" {
+				continue
+			}
+
+			str := contentOf(ref, "INSDReference_reference")
+			posn := contentOf(ref, "INSDReference_position")
+			if posn == "sites" {
+				str += "  (sites)"
+			} else if posn != "" {
+				var arry []string
+				for _, item := range strings.Split(posn, ",") {
+					fr, to := SplitInTwoLeft(item, "..")
+					if to == "" {
+						to = fr
+					}
+					arry = append(arry, fr+" to "+to)
+				}
+				str += "  (" + unit + " " + strings.Join(arry, "; ") + ")"
+			}
+			writeField("REFERENCE", str)
+
+			auths := contentsOf(ref, "INSDReference_authors", "INSDAuthor")
+			if len(auths) > 0 {
+				last := len(auths) - 1
+				str = auths[last]
+				if last > 0 {
+					str = strings.Join(auths[:last], ", ") + " and " + auths[last]
+				}
+				writeField("  AUTHORS", str)
+			}
+			if cons := contentOf(ref, "INSDReference_consortium"); cons != "" {
+				writeField("  CONSRTM", cons)
+			}
+			if titl := contentOf(ref, "INSDReference_title"); titl != "" {
+				writeField("  TITLE", titl)
+			}
+			if jour := contentOf(ref, "INSDReference_journal"); jour != "" {
+				writeField("  JOURNAL", jour)
+			}
+			if pmid := contentOf(ref, "INSDReference_pubmed"); pmid != "" {
+				writeField("   PUBMED", pmid)
+			}
+			if rem := contentOf(ref, "INSDReference_remark"); rem != "" {
+				writeField("  REMARK", rem)
+			}
+		}
+	}
+
+	if com := contentOf(pat, "INSDSeq_comment"); com != "" {
+		writeField("COMMENT", com)
+	}
+	if pmy := contentOf(pat, "INSDSeq_primary"); pmy != "" {
+		writeField("PRIMARY", pmy)
+	}
+
+	// FEATURES table
+
+	buffer.WriteString("FEATURES             Location/Qualifiers\n")
+
+	ExploreNodes(pat, "INSDSeq_feature-table", "INSDFeature", 0, 0, func(node *XMLNode, idx, lvl int) {
+
+		key := contentOf(node, "INSDFeature_key")
+		loc := contentOf(node, "INSDFeature_location")
+		wrapFlatText(&buffer, fmt.Sprintf("     %-16s", key), twentyonespaces, loc, true)
+
+		ExploreNodes(node, "INSDFeature_quals", "INSDQualifier", 0, 0, func(ql *XMLNode, idx, lvl int) {
+
+			name := contentOf(ql, "INSDQualifier_name")
+			if findChild(ql, "INSDQualifier_value") == nil {
+				buffer.WriteString(twentyonespaces + "/" + name + "\n")
+				return
+			}
+			value := contentOf(ql, "INSDQualifier_value")
+
+			str := "/" + name + "="
+			if unquotedQualifiers[name] {
+				str += value
+			} else {
+				// embedded quotes are already doubled in the INSDSeq value
+				str += "\"" + value + "\""
+			}
+
+			if solidQualifiers[name] {
+				// break sequence-like values at fixed width, they are rejoined without spaces
+				for len(str) > 58 {
+					buffer.WriteString(twentyonespaces + str[:58] + "\n")
+					str = str[58:]
+				}
+				buffer.WriteString(twentyonespaces + str + "\n")
+				return
+			}
+
+			wrapFlatText(&buffer, twentyonespaces, twentyonespaces, str, false)
+		})
+	})
+
+	// CONTIG, WGS, TSA, or TLS ranges
+
+	if alt := findChild(pat, "INSDSeq_alt-seq"); alt != nil {
+		ExploreNodes(alt, "", "INSDAltSeqData", 0, 0, func(data *XMLNode, idx, lvl int) {
+			name := strings.ToUpper(contentOf(data, "INSDAltSeqData_name"))
+			ExploreNodes(data, "INSDAltSeqData_items", "INSDAltSeqItem", 0, 0, func(item *XMLNode, idx, lvl int) {
+				fst := contentOf(item, "INSDAltSeqItem_first-accn")
+				lst := contentOf(item, "INSDAltSeqItem_last-accn")
+				if fst != "" && lst != "" {
+					writeField(name, fst+"-"+lst)
+				} else if val := contentOf(item, "INSDAltSeqItem_value"); val != "" {
+					writeField(name, val)
+				}
+			})
+		})
+	}
+
+	if contig := contentOf(pat, "INSDSeq_contig"); contig != "" {
+		wrapFlatText(&buffer, "CONTIG      ", twelvespaces, contig, true)
+	}
+
+	// ORIGIN and sequence in blocks of ten
+
+	if seq := strings.ToLower(contentOf(pat, "INSDSeq_sequence")); seq != "" {
+		buffer.WriteString("ORIGIN      \n")
+		for i := 0; i < len(seq); i += 60 {
+			buffer.WriteString(fmt.Sprintf("%9d", i+1))
+			for j := i; j < i+60 && j < len(seq); j += 10 {
+				k := j + 10
+				if k > len(seq) {
+					k = len(seq)
+				}
+				buffer.WriteString(" ")
+				buffer.WriteString(seq[j:k])
+			}
+			buffer.WriteString("\n")
+		}
+	}
+
+	buffer.WriteString("//\n")
+
+	return buffer.String()
+}
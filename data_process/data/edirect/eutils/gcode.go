@@ -31,16 +31,20 @@
 package eutils
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 )
 
 // When a new genetic code is added, update the raw genetic code tables below,
 // recompile, run 'transmute -degenerate > gdata.go' to generate new data tables,
 // replace the old gdata.go source code file with the new version, and then
-// recompile again to bring all executables up to date.
+// recompile again to bring all executables up to date. Codes needed only for
+// a particular analysis can instead be loaded with RegisterGeneticCode.
 
 // bases are ncbi4na bit flags: A 0, C 1, G 2, T 4
 
@@ -630,6 +634,287 @@ const gdataHeading = "" +
 //
 // ==========================================================================`
 
+// translation table structure
+type transTable struct {
+	aminoAcid [4096]int
+	orfStart  [4096]int
+	orfStop   [4096]int
+}
+
+// buildTransTable expands ncbieaa and sncbieaa strings to all ambiguous codon states
+func buildTransTable(ncbieaa, sncbieaa string) *transTable {
+
+	// check length of ncbieaa and sncbieaa strings
+	if len(ncbieaa) != 64 || len(sncbieaa) != 64 {
+		return nil
+	}
+
+	tbl := new(transTable)
+
+	// ambiguous codons map to unknown amino acid or not start
+	for i := 0; i < 4096; i++ {
+		tbl.aminoAcid[i] = int('X')
+		tbl.orfStart[i] = int('-')
+		tbl.orfStop[i] = int('-')
+	}
+
+	var expansions = [4]int{baseA, baseC, baseG, baseT}
+	// T = 0, C = 1, A = 2, G = 3
+	var codonIdx = [9]int{0, 2, 1, 0, 3, 0, 0, 0, 0}
+
+	// lookup amino acid for each codon in genetic code table
+	for i, st := baseGap, 0; i <= baseN; i++ {
+		for j := baseGap; j <= baseN; j++ {
+			for k := baseGap; k <= baseN; k++ {
+
+				aa := 0
+				orf := 0
+				goOn := true
+
+				// expand ambiguous IJK nucleotide symbols into component bases XYZ
+				for p := 0; p < 4 && goOn; p++ {
+					x := expansions[p]
+					if (x & i) != 0 {
+						for q := 0; q < 4 && goOn; q++ {
+							y := expansions[q]
+							if (y & j) != 0 {
+								for r := 0; r < 4 && goOn; r++ {
+									z := expansions[r]
+									if (z & k) != 0 {
+
+										// calculate offset in genetic code string
+
+										// the T = 0, C = 1, A = 2, G = 3 order is
+										// necessary because the genetic code
+										// strings are presented in TCAG order
+										cd := 16*codonIdx[x] + 4*codonIdx[y] + codonIdx[z]
+
+										// lookup amino acid for codon XYZ
+										ch := int(ncbieaa[cd])
+										if aa == 0 {
+											aa = ch
+										} else if aa != ch {
+											// allow Asx (Asp or Asn) and Glx (Glu or Gln)
+											if (aa == 'B' || aa == 'D' || aa == 'N') && (ch == 'D' || ch == 'N') {
+												aa = 'B'
+											} else if (aa == 'Z' || aa == 'E' || aa == 'Q') && (ch == 'E' || ch == 'Q') {
+												aa = 'Z'
+											} else if (aa == 'J' || aa == 'I' || aa == 'L') && (ch == 'I' || ch == 'L') {
+												aa = 'J'
+											} else {
+												aa = 'X'
+											}
+										}
+
+										// lookup translation start flag
+										ch = int(sncbieaa[cd])
+										if orf == 0 {
+											orf = ch
+										} else if orf != ch {
+											orf = 'X'
+										}
+
+										// drop out of loop as soon as answer is known
+										if aa == 'X' && orf == 'X' {
+											goOn = false
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+
+				// assign amino acid
+				if aa != 0 {
+					tbl.aminoAcid[st] = aa
+				}
+				// assign orf start/stop
+				if orf == '*' {
+					tbl.orfStop[st] = orf
+				} else if orf != 0 && orf != '-' && orf != 'X' {
+					tbl.orfStart[st] = orf
+				}
+
+				st++
+			}
+		}
+	}
+
+	return tbl
+}
+
+// runtime genetic code registration
+
+// GeneticCode holds the ncbieaa and sncbieaa strings of one code, in TCAG order
+type GeneticCode struct {
+	ID       int
+	Name     string
+	NCBIeaa  string
+	SNCBIeaa string
+}
+
+// IsGeneticCode reports whether a genetic code number is built in or was registered at runtime
+func IsGeneticCode(genCode int) bool {
+
+	if genCode < 1 {
+		return false
+	}
+
+	_, ok := ncbieaaCode[correctGenCode(genCode)]
+
+	return ok
+}
+
+// RegisterGeneticCode adds a genetic code under an unused ID, so that TranslateCdRegion,
+// IsOrfStart, and the other lookup functions can use it without regenerating gdata.go.
+// An entry identical to an existing code, e.g., from NCBI's gc.prt, is accepted unchanged.
+func RegisterGeneticCode(gc GeneticCode) bool {
+
+	if gc.ID < 1 {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Genetic code '%s' needs an ID number%s\n", INVT, LOUD, gc.Name, INIT)
+		return false
+	}
+	if gc.ID == 7 || gc.ID == 8 {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Genetic code ID %d is reserved%s\n", INVT, LOUD, gc.ID, INIT)
+		return false
+	}
+	if eaa, ok := ncbieaaCode[gc.ID]; ok {
+		// built-in tables and the stdGenCode fast path cannot be replaced
+		if eaa == gc.NCBIeaa && sncbieaaCode[gc.ID] == gc.SNCBIeaa {
+			return true
+		}
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Genetic code %d already exists%s\n", INVT, LOUD, gc.ID, INIT)
+		return false
+	}
+
+	tbl := buildTransTable(gc.NCBIeaa, gc.SNCBIeaa)
+	if tbl == nil {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Genetic code %d does not have 64 codons%s\n", INVT, LOUD, gc.ID, INIT)
+		return false
+	}
+
+	// residue map is fully populated, so lookups never fall back to the standard code
+	aminos := make(map[int]int)
+	starts := make(map[int]int)
+	stops := make(map[int]int)
+
+	for i := 0; i < 4096; i++ {
+		aminos[i] = tbl.aminoAcid[i]
+		aa := tbl.orfStart[i]
+		if aa != 0 && aa != '*' && aa != '-' && aa != 'X' {
+			starts[i] = aa
+		}
+		if tbl.orfStop[i] == '*' {
+			stops[i] = '*'
+		}
+	}
+
+	aminoAcidMaps[gc.ID] = aminos
+	orfStartMaps[gc.ID] = starts
+	orfStopMaps[gc.ID] = stops
+	ncbieaaCode[gc.ID] = gc.NCBIeaa
+	sncbieaaCode[gc.ID] = gc.SNCBIeaa
+
+	name := gc.Name
+	if name == "" {
+		name = "Custom " + strconv.Itoa(gc.ID)
+	}
+	genCodeNames[gc.ID] = name
+
+	return true
+}
+
+// ReadGeneticCodes loads NCBI gc.prt entries, or a 64-line codon table, e.g., "TGA W" or "ATG M start",
+// with optional "id" and "name" lines, where stop codons use '*' and starts have a third column
+func ReadGeneticCodes(inp io.Reader) []GeneticCode {
+
+	if inp == nil {
+		return nil
+	}
+
+	var codes []GeneticCode
+
+	var curr GeneticCode
+
+	// accumulate simple codon table in TCAG order
+	aminos := []byte(strings.Repeat("X", 64))
+	starts := []byte(strings.Repeat("-", 64))
+	numCodons := 0
+
+	quoted := func(str string) string {
+		fst := strings.Index(str, "\"")
+		lst := strings.LastIndex(str, "\"")
+		if fst < 0 || lst <= fst {
+			return ""
+		}
+		return str[fst+1 : lst]
+	}
+
+	flush := func() {
+		if curr.NCBIeaa != "" {
+			if curr.SNCBIeaa == "" {
+				curr.SNCBIeaa = strings.Repeat("-", 64)
+			}
+			codes = append(codes, curr)
+		}
+		curr = GeneticCode{}
+	}
+
+	scanr := bufio.NewScanner(inp)
+
+	for scanr.Scan() {
+
+		line := strings.TrimSpace(scanr.Text())
+		if line == "" || strings.HasPrefix(line, "--") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		flds := strings.Fields(strings.Replace(line, ",", " ", -1))
+
+		switch {
+		case flds[0] == "name":
+			// keep first name, gc.prt also has short names such as "SGC0"
+			if curr.Name == "" {
+				curr.Name = quoted(line)
+				if curr.Name == "" && len(flds) > 1 {
+					curr.Name = strings.Join(flds[1:], " ")
+				}
+			}
+		case flds[0] == "id" && len(flds) > 1:
+			curr.ID, _ = strconv.Atoi(flds[1])
+		case flds[0] == "ncbieaa":
+			curr.NCBIeaa = quoted(line)
+		case flds[0] == "sncbieaa":
+			curr.SNCBIeaa = quoted(line)
+		case strings.HasPrefix(line, "}"):
+			flush()
+		case len(flds) >= 2 && codonIndex(flds[0]) >= 0 && len(flds[1]) == 1:
+			idx := codonIndex(flds[0])
+			aminos[idx] = strings.ToUpper(flds[1])[0]
+			if aminos[idx] == '*' {
+				starts[idx] = '*'
+			} else if len(flds) > 2 {
+				starts[idx] = 'M'
+			}
+			numCodons++
+		}
+	}
+
+	if numCodons > 0 {
+		if numCodons != 64 {
+			fmt.Fprintf(os.Stderr, "%s ERROR: %s Codon table has %d codons instead of 64%s\n", INVT, LOUD, numCodons, INIT)
+			return nil
+		}
+		curr.NCBIeaa = string(aminos)
+		curr.SNCBIeaa = string(starts)
+	}
+
+	flush()
+
+	return codes
+}
+
 // GenerateGeneticCodeMaps regenerates static protein translation maps
 func GenerateGeneticCodeMaps() {
 
@@ -728,21 +1013,8 @@ func GenerateGeneticCodeMaps() {
 	}
 	fmt.Fprintf(os.Stdout, "}\n\n")
 
-	// translation table structure
-	type TransTable struct {
-		aminoAcid [4096]int
-		orfStart  [4096]int
-		orfStop   [4096]int
-	}
-
 	// create translation table for given genetic code
-	newTransTable := func(genCode int) *TransTable {
-
-		tbl := new(TransTable)
-		if tbl == nil {
-			fmt.Fprintf(os.Stderr, "\nERROR: Unable to allocate translation table for genetic code %d\n", genCode)
-			os.Exit(1)
-		}
+	newTransTable := func(genCode int) *transTable {
 
 		// genetic code number corrections
 		if genCode == 7 {
@@ -765,102 +1037,12 @@ func GenerateGeneticCodeMaps() {
 			os.Exit(1)
 		}
 
-		// also check length of ncbieaa and sncbieaa strings
-		if len(ncbieaa) != 64 || len(sncbieaa) != 64 {
+		tbl := buildTransTable(ncbieaa, sncbieaa)
+		if tbl == nil {
 			fmt.Fprintf(os.Stderr, "\nERROR: Genetic code %d length mismatch\n", genCode)
 			os.Exit(1)
 		}
 
-		// ambiguous codons map to unknown amino acid or not start
-		for i := 0; i < 4096; i++ {
-			tbl.aminoAcid[i] = int('X')
-			tbl.orfStart[i] = int('-')
-			tbl.orfStop[i] = int('-')
-		}
-
-		var expansions = [4]int{baseA, baseC, baseG, baseT}
-		// T = 0, C = 1, A = 2, G = 3
-		var codonIdx = [9]int{0, 2, 1, 0, 3, 0, 0, 0, 0}
-
-		// lookup amino acid for each codon in genetic code table
-		for i, st := baseGap, 0; i <= baseN; i++ {
-			for j := baseGap; j <= baseN; j++ {
-				for k := baseGap; k <= baseN; k++ {
-
-					aa := 0
-					orf := 0
-					goOn := true
-
-					// expand ambiguous IJK nucleotide symbols into component bases XYZ
-					for p := 0; p < 4 && goOn; p++ {
-						x := expansions[p]
-						if (x & i) != 0 {
-							for q := 0; q < 4 && goOn; q++ {
-								y := expansions[q]
-								if (y & j) != 0 {
-									for r := 0; r < 4 && goOn; r++ {
-										z := expansions[r]
-										if (z & k) != 0 {
-
-											// calculate offset in genetic code string
-
-											// the T = 0, C = 1, A = 2, G = 3 order is
-											// necessary because the genetic code
-											// strings are presented in TCAG order
-											cd := 16*codonIdx[x] + 4*codonIdx[y] + codonIdx[z]
-
-											// lookup amino acid for codon XYZ
-											ch := int(ncbieaa[cd])
-											if aa == 0 {
-												aa = ch
-											} else if aa != ch {
-												// allow Asx (Asp or Asn) and Glx (Glu or Gln)
-												if (aa == 'B' || aa == 'D' || aa == 'N') && (ch == 'D' || ch == 'N') {
-													aa = 'B'
-												} else if (aa == 'Z' || aa == 'E' || aa == 'Q') && (ch == 'E' || ch == 'Q') {
-													aa = 'Z'
-												} else if (aa == 'J' || aa == 'I' || aa == 'L') && (ch == 'I' || ch == 'L') {
-													aa = 'J'
-												} else {
-													aa = 'X'
-												}
-											}
-
-											// lookup translation start flag
-											ch = int(sncbieaa[cd])
-											if orf == 0 {
-												orf = ch
-											} else if orf != ch {
-												orf = 'X'
-											}
-
-											// drop out of loop as soon as answer is known
-											if aa == 'X' && orf == 'X' {
-												goOn = false
-											}
-										}
-									}
-								}
-							}
-						}
-					}
-
-					// assign amino acid
-					if aa != 0 {
-						tbl.aminoAcid[st] = aa
-					}
-					// assign orf start/stop
-					if orf == '*' {
-						tbl.orfStop[st] = orf
-					} else if orf != 0 && orf != '-' && orf != 'X' {
-						tbl.orfStart[st] = orf
-					}
-
-					st++
-				}
-			}
-		}
-
 		return tbl
 	}
 
@@ -0,0 +1,640 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  gff.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GFF3 follows https://github.com/The-Sequence-Ontology/Specifications/blob/master/gff3.md,
+// GTF follows GTF2.2 at https://mblab.wustl.edu/GTF22.html, and BED12 follows the UCSC
+// Genome Browser FAQ at https://genome.ucsc.edu/FAQ/FAQformat.html#format1
+
+// feature interval in 1-based coordinates, with from not greater than to
+type gffInterval struct {
+	from  int
+	to    int
+	minus bool
+}
+
+type gffQualifier struct {
+	name  string
+	value string
+}
+
+type gffFeature struct {
+	key      string
+	ivals    []gffInterval
+	minus    bool
+	partial5 bool
+	partial3 bool
+	quals    []gffQualifier
+	id       string
+	parent   *gffFeature
+	cds      *gffFeature
+}
+
+// RNA feature keys exported as transcripts with child exons
+var gffTranscriptKeys = map[string]bool{
+	"mRNA":          true,
+	"ncRNA":         true,
+	"rRNA":          true,
+	"tRNA":          true,
+	"misc_RNA":      true,
+	"precursor_RNA": true,
+	"tmRNA":         true,
+}
+
+// INSDC feature keys mapped to Sequence Ontology types
+var gffFeatureTypes = map[string]string{
+	"source":          "region",
+	"misc_feature":    "sequence_feature",
+	"rep_origin":      "origin_of_replication",
+	"repeat_region":   "repeat_region",
+	"mat_peptide":     "mature_protein_region",
+	"sig_peptide":     "signal_peptide",
+	"transit_peptide": "transit_peptide",
+	"5'UTR":           "five_prime_UTR",
+	"3'UTR":           "three_prime_UTR",
+	"misc_RNA":        "transcript",
+	"precursor_RNA":   "primary_transcript",
+	"regulatory":      "regulatory_region",
+	"mobile_element":  "mobile_genetic_element",
+}
+
+// qualifiers with long values that are not copied into attributes
+var gffSkipQuals = map[string]bool{
+	"translation":   true,
+	"transcription": true,
+}
+
+func (feat *gffFeature) qual(name string) string {
+
+	for _, ql := range feat.quals {
+		if ql.name == name {
+			return ql.value
+		}
+	}
+
+	return ""
+}
+
+// span returns the leftmost and rightmost positions of all intervals
+func (feat *gffFeature) span() (int, int) {
+
+	min := 0
+	max := 0
+	for i, iv := range feat.ivals {
+		if i == 0 || iv.from < min {
+			min = iv.from
+		}
+		if i == 0 || iv.to > max {
+			max = iv.to
+		}
+	}
+
+	return min, max
+}
+
+// geneKey links gene, RNA, and CDS features
+func (feat *gffFeature) geneKey() string {
+
+	if lt := feat.qual("locus_tag"); lt != "" {
+		return lt
+	}
+
+	return feat.qual("gene")
+}
+
+// sliceIntervals returns the genomic intervals covering a range of transcript positions,
+// with intervals in transcript order and offsets counted from the 5' end
+func sliceIntervals(ivals []gffInterval, offset, length int) []gffInterval {
+
+	var arry []gffInterval
+
+	for _, iv := range ivals {
+		if length < 1 {
+			break
+		}
+		ln := iv.to - iv.from + 1
+		if offset >= ln {
+			offset -= ln
+			continue
+		}
+		take := ln - offset
+		if take > length {
+			take = length
+		}
+		if iv.minus {
+			arry = append(arry, gffInterval{from: iv.to - offset - take + 1, to: iv.to - offset, minus: true})
+		} else {
+			arry = append(arry, gffInterval{from: iv.from + offset, to: iv.from + offset + take - 1})
+		}
+		length -= take
+		offset = 0
+	}
+
+	return arry
+}
+
+func intervalLength(ivals []gffInterval) int {
+
+	total := 0
+	for _, iv := range ivals {
+		total += iv.to - iv.from + 1
+	}
+
+	return total
+}
+
+// gffEscape percent-encodes reserved characters in GFF3 attribute values
+func gffEscape(str string) string {
+
+	var buffer strings.Builder
+
+	for i := 0; i < len(str); i++ {
+		ch := str[i]
+		switch ch {
+		case ';', '=', '&', ',', '%', '\t', '\n', '\r':
+			buffer.WriteString(fmt.Sprintf("%%%02X", ch))
+		default:
+			buffer.WriteByte(ch)
+		}
+	}
+
+	return buffer.String()
+}
+
+// parseINSDFeatures reads the accession, length, and feature table of an INSDSeq record
+func parseINSDFeatures(text string) (string, int, []*gffFeature) {
+
+	pat := ParseRecord(text, "INSDSeq")
+	if pat == nil {
+		return "", 0, nil
+	}
+
+	contentOf := func(node *XMLNode, name string) string {
+		for chld := node.Children; chld != nil; chld = chld.Next {
+			if chld.Name == name {
+				return html.UnescapeString(chld.Contents)
+			}
+		}
+		return ""
+	}
+
+	hasChild := func(node *XMLNode, name string) bool {
+		for chld := node.Children; chld != nil; chld = chld.Next {
+			if chld.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	accn := contentOf(pat, "INSDSeq_accession-version")
+	if accn == "" {
+		accn = contentOf(pat, "INSDSeq_primary-accession")
+	}
+	if accn == "" {
+		accn = contentOf(pat, "INSDSeq_locus")
+	}
+	seqlen, _ := strconv.Atoi(contentOf(pat, "INSDSeq_length"))
+
+	var feats []*gffFeature
+
+	ExploreNodes(pat, "INSDSeq_feature-table", "INSDFeature", 0, 0, func(node *XMLNode, idx, lvl int) {
+
+		feat := &gffFeature{key: contentOf(node, "INSDFeature_key")}
+
+		feat.partial5 = hasChild(node, "INSDFeature_partial5")
+		feat.partial3 = hasChild(node, "INSDFeature_partial3")
+
+		remote := false
+
+		ExploreNodes(node, "INSDFeature_intervals", "INSDInterval", 0, 0, func(ivl *XMLNode, idx, lvl int) {
+
+			acc := contentOf(ivl, "INSDInterval_accession")
+			if acc != "" && acc != accn {
+				remote = true
+				return
+			}
+
+			fr := contentOf(ivl, "INSDInterval_from")
+			to := contentOf(ivl, "INSDInterval_to")
+			if pt := contentOf(ivl, "INSDInterval_point"); pt != "" {
+				fr = pt
+				to = pt
+			}
+
+			min, err := strconv.Atoi(fr)
+			if err != nil {
+				return
+			}
+			max, err := strconv.Atoi(to)
+			if err != nil {
+				return
+			}
+
+			iv := gffInterval{from: min, to: max, minus: hasChild(ivl, "INSDInterval_iscomp")}
+			if min > max {
+				iv.from, iv.to = max, min
+				iv.minus = true
+			}
+			feat.ivals = append(feat.ivals, iv)
+		})
+
+		if remote {
+			fmt.Fprintf(os.Stderr, "%s WARNING: %s Skipping %s feature with interval on another sequence%s\n", INVT, LOUD, feat.key, INIT)
+			return
+		}
+		if len(feat.ivals) < 1 {
+			return
+		}
+
+		feat.minus = feat.ivals[0].minus
+
+		ExploreNodes(node, "INSDFeature_quals", "INSDQualifier", 0, 0, func(ql *XMLNode, idx, lvl int) {
+			name := contentOf(ql, "INSDQualifier_name")
+			value := contentOf(ql, "INSDQualifier_value")
+			feat.quals = append(feat.quals, gffQualifier{name: name, value: value})
+		})
+
+		feats = append(feats, feat)
+	})
+
+	return accn, seqlen, feats
+}
+
+// linkINSDFeatures assigns unique IDs and connects gene to RNA to CDS
+func linkINSDFeatures(feats []*gffFeature) {
+
+	used := make(map[string]int)
+
+	makeID := func(prefix, name string) string {
+		if name == "" {
+			name = "1"
+		}
+		id := prefix + "-" + name
+		used[id]++
+		if used[id] > 1 {
+			id += "-" + strconv.Itoa(used[id])
+		}
+		return id
+	}
+
+	genes := make(map[string]*gffFeature)
+
+	for _, feat := range feats {
+		switch {
+		case feat.key == "gene":
+			feat.id = makeID("gene", feat.geneKey())
+			if gk := feat.geneKey(); gk != "" {
+				genes[gk] = feat
+			}
+		case gffTranscriptKeys[feat.key]:
+			name := feat.qual("transcript_id")
+			if name == "" {
+				name = feat.geneKey()
+			}
+			feat.id = makeID("rna", name)
+		case feat.key == "CDS":
+			name := feat.qual("protein_id")
+			if name == "" {
+				name = feat.geneKey()
+			}
+			feat.id = makeID("cds", name)
+		default:
+			feat.id = makeID("id", feat.key)
+		}
+	}
+
+	// CDS intervals must fall within one exon of candidate mRNA, sharing internal splice sites
+	fitsWithin := func(cds, rna *gffFeature) bool {
+		if cds.minus != rna.minus {
+			return false
+		}
+		for _, civ := range cds.ivals {
+			found := false
+			for _, riv := range rna.ivals {
+				if civ.from >= riv.from && civ.to <= riv.to {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, feat := range feats {
+		gk := feat.geneKey()
+		if feat.key != "gene" && gk != "" {
+			feat.parent = genes[gk]
+		}
+		if feat.key != "CDS" {
+			continue
+		}
+		for _, rna := range feats {
+			if rna.key != "mRNA" || rna.cds != nil || rna.geneKey() != gk {
+				continue
+			}
+			if fitsWithin(feat, rna) {
+				feat.parent = rna
+				rna.cds = feat
+				break
+			}
+		}
+	}
+}
+
+// INSDSeqToGFF converts the features of one INSDSeq record to "gff3", "gtf", or "bed" lines
+func INSDSeqToGFF(text, format string) string {
+
+	accn, seqlen, feats := parseINSDFeatures(text)
+	if accn == "" {
+		return ""
+	}
+
+	linkINSDFeatures(feats)
+
+	var buffer strings.Builder
+
+	strandOf := func(feat *gffFeature) string {
+		if feat.minus {
+			return "-"
+		}
+		return "+"
+	}
+
+	// codon_start qualifier gives initial CDS offset
+	codonStart := func(feat *gffFeature) int {
+		cs, err := strconv.Atoi(feat.qual("codon_start"))
+		if err != nil || cs < 1 || cs > 3 {
+			return 0
+		}
+		return cs - 1
+	}
+
+	switch format {
+	case "gtf":
+		writeLine := func(feat *gffFeature, kind string, iv gffInterval, frame, attrs string) {
+			buffer.WriteString(fmt.Sprintf("%s\tINSDC\t%s\t%d\t%d\t.\t%s\t%s\t%s\n", accn, kind, iv.from, iv.to, strandOf(feat), frame, attrs))
+		}
+
+		quote := func(name, value string) string {
+			return name + " \"" + strings.Replace(value, "\"", "'", -1) + "\";"
+		}
+
+		for _, feat := range feats {
+
+			geneID := feat.geneKey()
+			if geneID == "" {
+				geneID = feat.id
+			}
+
+			if feat.key == "gene" {
+				min, max := feat.span()
+				attrs := quote("gene_id", geneID)
+				if nm := feat.qual("gene"); nm != "" {
+					attrs += " " + quote("gene_name", nm)
+				}
+				writeLine(feat, "gene", gffInterval{from: min, to: max}, ".", attrs)
+				continue
+			}
+
+			var cds *gffFeature
+			var exons []gffInterval
+			transcriptID := ""
+
+			if gffTranscriptKeys[feat.key] {
+				transcriptID = feat.qual("transcript_id")
+				if transcriptID == "" {
+					transcriptID = feat.id
+				}
+				exons = feat.ivals
+				cds = feat.cds
+			} else if feat.key == "CDS" && (feat.parent == nil || feat.parent.key != "mRNA") {
+				// CDS without mRNA parent, e.g., prokaryotic gene, is its own transcript
+				transcriptID = feat.qual("protein_id")
+				if transcriptID == "" {
+					transcriptID = feat.id
+				}
+				exons = feat.ivals
+				cds = feat
+			} else {
+				continue
+			}
+
+			attrs := quote("gene_id", geneID) + " " + quote("transcript_id", transcriptID)
+
+			min, max := feat.span()
+			writeLine(feat, "transcript", gffInterval{from: min, to: max}, ".", attrs)
+
+			for i, iv := range exons {
+				writeLine(feat, "exon", iv, ".", attrs+" "+quote("exon_number", strconv.Itoa(i+1)))
+			}
+
+			if cds == nil {
+				continue
+			}
+
+			// GTF2.2 CDS excludes stop codon, which is reported separately
+			offset := codonStart(cds)
+			total := intervalLength(cds.ivals)
+			cdsLen := total
+			if !cds.partial3 && total >= 6 {
+				cdsLen -= 3
+			}
+
+			if !cds.partial5 && offset == 0 {
+				for _, iv := range sliceIntervals(cds.ivals, 0, 3) {
+					writeLine(cds, "start_codon", iv, "0", attrs)
+				}
+			}
+
+			done := 0
+			for _, iv := range sliceIntervals(cds.ivals, 0, cdsLen) {
+				frame := ((offset-done)%3 + 3) % 3
+				writeLine(cds, "CDS", iv, strconv.Itoa(frame), attrs)
+				done += iv.to - iv.from + 1
+			}
+
+			if cdsLen < total {
+				for _, iv := range sliceIntervals(cds.ivals, cdsLen, 3) {
+					writeLine(cds, "stop_codon", iv, "0", attrs)
+				}
+			}
+		}
+
+	case "bed":
+		for _, feat := range feats {
+
+			var cds *gffFeature
+			if gffTranscriptKeys[feat.key] {
+				cds = feat.cds
+			} else if feat.key == "CDS" && (feat.parent == nil || feat.parent.key != "mRNA") {
+				cds = feat
+			} else {
+				continue
+			}
+
+			name := feat.qual("transcript_id")
+			if name == "" {
+				name = feat.qual("protein_id")
+			}
+			if name == "" {
+				name = feat.id
+			}
+
+			min, max := feat.span()
+			start := min - 1
+
+			thickStart := start
+			thickEnd := start
+			if cds != nil {
+				cmin, cmax := cds.span()
+				thickStart = cmin - 1
+				thickEnd = cmax
+			}
+
+			blocks := make([]gffInterval, len(feat.ivals))
+			copy(blocks, feat.ivals)
+			sort.Slice(blocks, func(i, j int) bool { return blocks[i].from < blocks[j].from })
+
+			var sizes []string
+			var starts []string
+			for _, iv := range blocks {
+				sizes = append(sizes, strconv.Itoa(iv.to-iv.from+1))
+				starts = append(starts, strconv.Itoa(iv.from-min))
+			}
+
+			buffer.WriteString(fmt.Sprintf("%s\t%d\t%d\t%s\t0\t%s\t%d\t%d\t0\t%d\t%s,\t%s,\n",
+				accn, start, max, name, strandOf(feat), thickStart, thickEnd, len(blocks),
+				strings.Join(sizes, ","), strings.Join(starts, ",")))
+		}
+
+	default:
+		buffer.WriteString(fmt.Sprintf("##sequence-region %s 1 %d\n", accn, seqlen))
+
+		writeLine := func(feat *gffFeature, kind string, iv gffInterval, phase, attrs string) {
+			buffer.WriteString(fmt.Sprintf("%s\tINSDC\t%s\t%d\t%d\t.\t%s\t%s\t%s\n", accn, kind, iv.from, iv.to, strandOf(feat), phase, attrs))
+		}
+
+		for _, feat := range feats {
+
+			kind := feat.key
+			if so, ok := gffFeatureTypes[kind]; ok {
+				kind = so
+			}
+
+			var attrs []string
+			attrs = append(attrs, "ID="+gffEscape(feat.id))
+			if feat.parent != nil {
+				attrs = append(attrs, "Parent="+gffEscape(feat.parent.id))
+			}
+
+			name := feat.qual("gene")
+			if feat.key != "gene" {
+				if prod := feat.qual("product"); prod != "" {
+					name = prod
+				}
+			}
+			if name != "" {
+				attrs = append(attrs, "Name="+gffEscape(name))
+			}
+
+			// combine repeated qualifiers into comma-separated list
+			var order []string
+			values := make(map[string][]string)
+			for _, ql := range feat.quals {
+				if gffSkipQuals[ql.name] {
+					continue
+				}
+				if _, ok := values[ql.name]; !ok {
+					order = append(order, ql.name)
+				}
+				val := ql.value
+				if val == "" {
+					val = "true"
+				}
+				values[ql.name] = append(values[ql.name], gffEscape(val))
+			}
+			for _, nm := range order {
+				tag := nm
+				if nm == "db_xref" {
+					tag = "Dbxref"
+				} else if nm == "note" {
+					tag = "Note"
+				}
+				attrs = append(attrs, gffEscape(tag)+"="+strings.Join(values[nm], ","))
+			}
+
+			min, max := feat.span()
+
+			// partial ends use the NCBI start_range and end_range conventions
+			if feat.partial5 || feat.partial3 {
+				attrs = append(attrs, "partial=true")
+				left, right := feat.partial5, feat.partial3
+				if feat.minus {
+					left, right = right, left
+				}
+				if left {
+					attrs = append(attrs, "start_range=.,"+strconv.Itoa(min))
+				}
+				if right {
+					attrs = append(attrs, "end_range="+strconv.Itoa(max)+",.")
+				}
+			}
+
+			attr := strings.Join(attrs, ";")
+
+			switch {
+			case feat.key == "CDS":
+				// discontinuous CDS shares ID over several lines, each with its own phase
+				offset := codonStart(feat)
+				done := 0
+				for _, iv := range feat.ivals {
+					phase := ((offset-done)%3 + 3) % 3
+					writeLine(feat, kind, iv, strconv.Itoa(phase), attr)
+					done += iv.to - iv.from + 1
+				}
+			case gffTranscriptKeys[feat.key]:
+				writeLine(feat, kind, gffInterval{from: min, to: max}, ".", attr)
+				for i, iv := range feat.ivals {
+					exon := "ID=" + gffEscape(feat.id+"-exon-"+strconv.Itoa(i+1)) + ";Parent=" + gffEscape(feat.id)
+					writeLine(feat, "exon", iv, ".", exon)
+				}
+			case feat.key == "gene" || len(feat.ivals) == 1:
+				writeLine(feat, kind, gffInterval{from: min, to: max}, ".", attr)
+			default:
+				for _, iv := range feat.ivals {
+					writeLine(feat, kind, iv, ".", attr)
+				}
+			}
+		}
+	}
+
+	return buffer.String()
+}
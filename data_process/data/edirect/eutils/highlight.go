@@ -0,0 +1,507 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  highlight.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HIT HIGHLIGHTING AND SNIPPET EXTRACTION
+
+// Phrase and proximity tests already compute the word positions at which
+// each phrase matches. With -hits, those positions are reported for every
+// result and query phrase. With -highlight, the records are also fetched
+// from the local archive, the text of each positional field is numbered
+// exactly as the indexer does, and a few words on either side of each match
+// are printed with the matching words marked. Words are located in the
+// original text so that case and punctuation are preserved, falling back
+// to the normalized words when the text cannot be aligned, e.g., after
+// accent or Greek letter conversion.
+
+const (
+	// words shown on each side of a match
+	snippetRadius = 8
+	// snippets printed per field of a record
+	snippetLimit = 3
+)
+
+// inline formatting tags are dropped from text snippets
+var inlineMarkup = strings.NewReplacer(
+	"<i>", "", "</i>", "",
+	"<b>", "", "</b>", "",
+	"<u>", "", "</u>", "",
+	"<sup>", "", "</sup>", "",
+	"<sub>", "", "</sub>", "",
+)
+
+// hlWord is one indexed word, with its byte range in the original text, or -1 if not found
+type hlWord struct {
+	pos   int32
+	item  string
+	start int
+	end   int
+}
+
+// hlParagraph is one text element of a field, e.g., an abstract section
+type hlParagraph struct {
+	text  string
+	words []hlWord
+}
+
+// alignWords locates each normalized word in the original text of a paragraph
+func alignWords(para *hlParagraph) {
+
+	words := para.words
+
+	// accent and Greek letter conversion change the spelling of words
+	if IsNotASCII(para.text) {
+		for i := range words {
+			words[i].start, words[i].end = -1, -1
+		}
+		return
+	}
+
+	low := strings.ToLower(para.text)
+	cur := 0
+
+	for i := range words {
+		idx := strings.Index(low[cur:], words[i].item)
+		// do not skip over a long stretch of text to find a common word
+		if idx < 0 || idx > 80 {
+			words[i].start, words[i].end = -1, -1
+			continue
+		}
+		words[i].start = cur + idx
+		words[i].end = words[i].start + len(words[i].item)
+		cur = words[i].end
+	}
+}
+
+// fieldParagraphs numbers the words of a positional field in a record, as in indexSchemaRecord
+func fieldParagraphs(pat *XMLNode, fld *IndexField) []hlParagraph {
+
+	var res []hlParagraph
+
+	cumulative := 0
+
+	for _, pth := range fld.Paths {
+		prnt, match, attrib := splitSchemaPath(pth)
+		ExploreElements(pat, "", prnt, match, attrib, false, false, 1, func(str string, lvl int) {
+			para := hlParagraph{text: str}
+			cumulative = indexWords(str, cumulative, func(item string, pos int) {
+				para.words = append(para.words, hlWord{pos: int32(pos), item: item})
+			})
+			if len(para.words) > 0 {
+				alignWords(&para)
+				res = append(res, para)
+			}
+		})
+	}
+
+	return res
+}
+
+// buildSnippets returns text around the matching words of a field, with matches marked by the open and close strings
+func buildSnippets(paras []hlParagraph, matched map[int32]bool, asXML bool) []string {
+
+	open, shut := "**", "**"
+	if asXML {
+		open, shut = "<b>", "</b>"
+	}
+
+	// text elements are still entity-encoded
+	clean := func(str string) string {
+		if asXML {
+			return str
+		}
+		return inlineMarkup.Replace(html.UnescapeString(str))
+	}
+
+	var res []string
+
+	for _, para := range paras {
+
+		words := para.words
+		last := len(words) - 1
+
+		// merge overlapping windows around each match
+		var windows [][2]int
+		for i, wrd := range words {
+			if !matched[wrd.pos] {
+				continue
+			}
+			from, to := i-snippetRadius, i+snippetRadius
+			if from < 0 {
+				from = 0
+			}
+			if to > last {
+				to = last
+			}
+			if n := len(windows); n > 0 && from <= windows[n-1][1]+1 {
+				windows[n-1][1] = to
+				continue
+			}
+			windows = append(windows, [2]int{from, to})
+		}
+
+		for _, win := range windows {
+
+			from, to := win[0], win[1]
+
+			aligned := true
+			for k := from; k <= to; k++ {
+				if words[k].start < 0 {
+					aligned = false
+					break
+				}
+			}
+
+			var buffer strings.Builder
+
+			if from > 0 {
+				buffer.WriteString("...")
+			}
+
+			for k := from; k <= to; k++ {
+				wrd := words[k]
+				hit := matched[wrd.pos]
+				prev := k > from && matched[words[k-1].pos]
+				next := k < to && matched[words[k+1].pos]
+				if k > from {
+					// keep a phrase within a single marked span
+					gap := " "
+					if aligned {
+						gap = clean(para.text[words[k-1].end:wrd.start])
+					}
+					if hit && !prev {
+						buffer.WriteString(gap)
+						buffer.WriteString(open)
+					} else {
+						buffer.WriteString(gap)
+					}
+				} else if hit {
+					buffer.WriteString(open)
+				}
+				if aligned {
+					buffer.WriteString(clean(para.text[wrd.start:wrd.end]))
+				} else if asXML {
+					buffer.WriteString(html.EscapeString(wrd.item))
+				} else {
+					buffer.WriteString(wrd.item)
+				}
+				if hit && !next {
+					buffer.WriteString(shut)
+				}
+			}
+
+			if to < last {
+				buffer.WriteString("...")
+			}
+
+			res = append(res, buffer.String())
+			if len(res) >= snippetLimit {
+				return res
+			}
+		}
+	}
+
+	return res
+}
+
+// trimProximityHits restricts the saved positions of each phrase in a proximity clause
+// to records in the result and to positions that are part of a complete chain of matches
+func trimProximityHits(node *queryNode, uids []int64) {
+
+	kids := node.Children
+	if len(kids) < 2 || len(node.Dist) < len(kids)-1 {
+		return
+	}
+	for _, chld := range kids {
+		if chld.Kind != qryPhrase || chld.hits == nil {
+			return
+		}
+	}
+
+	// next phrase must start after the previous one, within its length plus the allowed distance
+	dlts := make([]int32, len(kids)-1)
+	for i := range dlts {
+		dlts[i] = int32(kids[i].span + node.Dist[i])
+	}
+
+	// hasAfter reports whether any position in arry is in the range (pos, pos+dlt]
+	hasAfter := func(arry []int32, pos, dlt int32) bool {
+		idx := sort.Search(len(arry), func(i int) bool { return arry[i] > pos })
+		return idx < len(arry) && arry[idx] <= pos+dlt
+	}
+
+	// hasBefore reports whether any position in arry is in the range [pos-dlt, pos)
+	hasBefore := func(arry []int32, pos, dlt int32) bool {
+		idx := sort.Search(len(arry), func(i int) bool { return arry[i] >= pos-dlt })
+		return idx < len(arry) && arry[idx] < pos
+	}
+
+	filter := func(arry []int32, keep func(pos int32) bool) []int32 {
+		var res []int32
+		for _, pos := range arry {
+			if keep(pos) {
+				res = append(res, pos)
+			}
+		}
+		return res
+	}
+
+	hits := make([][]int64, len(kids))
+	psns := make([][][]int32, len(kids))
+
+	for _, uid := range uids {
+
+		arrs := make([][]int32, len(kids))
+		for i, chld := range kids {
+			idx := sort.Search(len(chld.hits), func(k int) bool { return chld.hits[k] >= uid })
+			if idx >= len(chld.hits) || chld.hits[idx] != uid || idx >= len(chld.psns) {
+				arrs = nil
+				break
+			}
+			arrs[i] = chld.psns[idx]
+		}
+		if arrs == nil {
+			continue
+		}
+
+		// backward pass keeps positions that can reach the last phrase
+		for i := len(kids) - 2; i >= 0; i-- {
+			next, dlt := arrs[i+1], dlts[i]
+			arrs[i] = filter(arrs[i], func(pos int32) bool { return hasAfter(next, pos, dlt) })
+		}
+		// forward pass keeps positions that can be reached from the first phrase
+		for i := 1; i < len(kids); i++ {
+			prev, dlt := arrs[i-1], dlts[i-1]
+			arrs[i] = filter(arrs[i], func(pos int32) bool { return hasBefore(prev, pos, dlt) })
+		}
+		if len(arrs[0]) < 1 {
+			continue
+		}
+
+		for i := range kids {
+			hits[i] = append(hits[i], uid)
+			psns[i] = append(psns[i], arrs[i])
+		}
+	}
+
+	for i, chld := range kids {
+		chld.hits, chld.psns = hits[i], psns[i]
+	}
+}
+
+// ProcessHighlight prints the matching word positions of each query phrase for every result,
+// plus highlighted snippets from archived records if a fetch path is given
+func ProcessHighlight(base, phrase, stash string, asXML, xact, titl, rlxd, deStop bool) int {
+
+	if phrase == "" {
+		return 0
+	}
+
+	// field names, aliases, and tokenizer policies
+	schema := ReadIndexSchema(base)
+
+	root := buildQueryTree(phrase, xact, titl, rlxd, deStop, schema)
+	if root == nil {
+		return 0
+	}
+
+	result, _ := resolveQuery(base, root, false, true)
+
+	uids := result.IDs()
+	if len(uids) < 1 {
+		return 0
+	}
+
+	// collect phrases with saved positions, excluding terms on the right side of NOT
+	var leaves []*queryNode
+
+	var visit func(node *queryNode)
+
+	visit = func(node *queryNode) {
+		if node.Kind == qryPhrase {
+			if node.hits != nil {
+				leaves = append(leaves, node)
+			}
+			return
+		}
+		for i, chld := range node.Children {
+			if node.Kind == qryNotNode && i > 0 {
+				break
+			}
+			visit(chld)
+		}
+	}
+
+	visit(root)
+
+	// matchedWords returns the positions of every word of each phrase in a single record
+	matchedWords := func(uid int64) [][]int32 {
+
+		res := make([][]int32, len(leaves))
+
+		for j, node := range leaves {
+			idx := sort.Search(len(node.hits), func(i int) bool { return node.hits[i] >= uid })
+			if idx >= len(node.hits) || node.hits[idx] != uid || idx >= len(node.psns) {
+				continue
+			}
+			for _, start := range node.psns[idx] {
+				for k := int32(0); k < int32(node.span); k++ {
+					res[j] = append(res[j], start+k)
+				}
+			}
+		}
+
+		return res
+	}
+
+	fieldOf := func(node *queryNode) string {
+		field := ""
+		if pos := strings.Index(node.Clause, "["); pos >= 0 && strings.HasSuffix(node.Clause, "]") {
+			field = strings.TrimSuffix(node.Clause[pos+1:], "]")
+		}
+		name, _ := schema.resolveField(field)
+		return name
+	}
+
+	joinPositions := func(arry []int32) string {
+		strs := make([]string, len(arry))
+		for i, val := range arry {
+			strs[i] = strconv.Itoa(int(val))
+		}
+		return strings.Join(strs, ",")
+	}
+
+	hdr := ReadPostingsHeader(base)
+
+	wrtr := bufio.NewWriter(os.Stdout)
+	defer wrtr.Flush()
+
+	if asXML {
+		wrtr.WriteString("<HitSet>\n")
+		defer wrtr.WriteString("</HitSet>\n")
+	}
+
+	// printHit writes the positions, and any snippets, for one record
+	printHit := func(uid int64, ident, text string) {
+
+		words := matchedWords(uid)
+
+		var pat *XMLNode
+		if text != "" {
+			pat = ParseRecord(text, schema.Pattern)
+		}
+
+		if asXML {
+			wrtr.WriteString("  <Hit>\n")
+			wrtr.WriteString("    <Id>" + html.EscapeString(ident) + "</Id>\n")
+		}
+
+		// snippets are produced once per field, highlighting the matches of all phrases in that field
+		matched := make(map[string]map[int32]bool)
+		var fields []string
+
+		for j, node := range leaves {
+			if len(words[j]) < 1 {
+				continue
+			}
+			label := formatQueryTree(node, schema, false)
+			if asXML {
+				wrtr.WriteString("    <Match term=\"" + html.EscapeString(label) + "\">" + joinPositions(words[j]) + "</Match>\n")
+			} else if pat == nil {
+				wrtr.WriteString(ident + "\t" + label + "\t" + joinPositions(words[j]) + "\n")
+			}
+			name := fieldOf(node)
+			if matched[name] == nil {
+				matched[name] = make(map[int32]bool)
+				fields = append(fields, name)
+			}
+			for _, pos := range words[j] {
+				matched[name][pos] = true
+			}
+		}
+
+		if pat != nil {
+			for _, name := range fields {
+				fld := schema.Field(name)
+				if fld == nil {
+					continue
+				}
+				for _, snip := range buildSnippets(fieldParagraphs(pat, fld), matched[name], asXML) {
+					if asXML {
+						wrtr.WriteString("    <Snippet field=\"" + name + "\">" + snip + "</Snippet>\n")
+					} else {
+						wrtr.WriteString(ident + "\t" + name + "\t" + snip + "\n")
+					}
+				}
+			}
+		}
+
+		if asXML {
+			wrtr.WriteString("  </Hit>\n")
+		}
+	}
+
+	// string identifiers are listed lexically, as in -query results
+	idents, order := postingUIDOrder(base, hdr, uids)
+
+	if stash == "" {
+		for _, i := range order {
+			printHit(uids[i], idents[i], "")
+		}
+		return len(uids)
+	}
+
+	// fetch archived records in result order
+	sorted := make([]string, len(order))
+	for k, i := range order {
+		sorted[k] = idents[i]
+	}
+
+	uidq := CreateUIDReader(strings.NewReader(strings.Join(sorted, "\n") + "\n"))
+	strq := CreateFetchers(stash, ".xml", false, uidq)
+	unsq := CreateXMLUnshuffler(strq)
+
+	if uidq == nil || strq == nil || unsq == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create archive reader\n")
+		os.Exit(1)
+	}
+
+	for curr := range unsq {
+		k := curr.Index - 1
+		if k < 0 || k >= len(order) {
+			continue
+		}
+		idx := order[k]
+		if curr.Text == "" {
+			fmt.Fprintf(os.Stderr, "\nWARNING: Record %s not found in archive\n", idents[idx])
+		}
+		printHit(uids[idx], idents[idx], curr.Text)
+	}
+
+	return len(uids)
+}
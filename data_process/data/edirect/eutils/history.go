@@ -0,0 +1,319 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  history.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PERSISTENT QUERY HISTORY
+
+// Each interactive -query result is saved as a numbered UID set, so later
+// queries can combine earlier results by reference, e.g., "#1 AND #3" or
+// "#2 NOT 2020:2023 [YEAR]", without re-running them or piping UID lists
+// through the [PIPE] pseudo-field. Sets are kept separately for each postings
+// directory, in a subdirectory of the history folder named by a checksum
+// of its absolute path:
+//
+//	postings.txt   path of the postings directory
+//	history.txt    number, count, and query text, one tab-delimited line per search
+//	1.uid, 2.uid   sorted UIDs in the compressed postings encoding
+//
+// String identifiers are saved as ordinals, and are only valid until the
+// index is rebuilt. Batch, -exact, -title, and [PIPE] filter searches are
+// not saved. Concurrent searches take turns through a lock file, and only
+// the most recent results are kept.
+
+const (
+	// saved searches per postings directory, older sets are removed
+	historyLimit = 100
+	// lock file left by an interrupted search is removed after this time
+	historyLockStale = 30 * time.Second
+)
+
+var (
+	historyDir    string
+	recordHistory bool
+)
+
+// SetQueryHistory sets the history folder, and whether new search results are saved
+func SetQueryHistory(dir string, record bool) {
+
+	historyDir = dir
+	recordHistory = record
+}
+
+// historyPath returns the history subdirectory for a postings directory
+func historyPath(base string) string {
+
+	if historyDir == "" {
+		return ""
+	}
+
+	abs, err := filepath.Abs(base)
+	if err != nil {
+		abs = base
+	}
+	abs = strings.TrimSuffix(abs, "/")
+
+	return path.Join(historyDir, fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(abs))))
+}
+
+// isHistoryRef recognizes a "#n" query history reference
+func isHistoryRef(str string) bool {
+
+	return len(str) > 1 && str[0] == '#' && IsAllDigits(str[1:])
+}
+
+// historyEntry is one line of the history index
+type historyEntry struct {
+	num   int
+	count int
+	query string
+}
+
+// readHistoryIndex returns the saved queries for a postings directory, in order
+func readHistoryIndex(base string) []historyEntry {
+
+	hpath := historyPath(base)
+	if hpath == "" {
+		return nil
+	}
+
+	inFile, err := os.Open(path.Join(hpath, "history.txt"))
+	if err != nil {
+		return nil
+	}
+
+	defer inFile.Close()
+
+	var res []historyEntry
+
+	scanr := bufio.NewScanner(inFile)
+	scanr.Buffer(make([]byte, 65536), 16*1024*1024)
+
+	for scanr.Scan() {
+		cols := strings.SplitN(scanr.Text(), "\t", 3)
+		if len(cols) < 3 {
+			continue
+		}
+		num, err := strconv.Atoi(cols[0])
+		if err != nil {
+			continue
+		}
+		cnt, err := strconv.Atoi(cols[1])
+		if err != nil {
+			continue
+		}
+		res = append(res, historyEntry{num, cnt, cols[2]})
+	}
+
+	return res
+}
+
+// lockHistory gives one search at a time exclusive use of the history folder, returning
+// a function that releases the lock, or nil if it could not be obtained
+func lockHistory(hpath string) func() {
+
+	lpath := path.Join(hpath, "lock")
+
+	// unique name for moving a stale lock aside
+	token := strconv.Itoa(os.Getpid()) + "." + strconv.FormatInt(time.Now().UnixNano(), 10)
+	aside := lpath + "." + token
+
+	for tries := 0; ; tries++ {
+		fl, err := os.OpenFile(lpath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fl.Close()
+			return func() { os.Remove(lpath) }
+		}
+		if !os.IsExist(err) {
+			fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			return nil
+		}
+		if fi, err := os.Stat(lpath); err == nil && time.Since(fi.ModTime()) > historyLockStale {
+			// rename is atomic, so only one waiting search can move a given lock file aside
+			if os.Rename(lpath, aside) == nil {
+				if fi, err := os.Stat(aside); err == nil && time.Since(fi.ModTime()) <= historyLockStale {
+					// lock was replaced by an active search after the check, put it back
+					os.Link(aside, lpath)
+				}
+				os.Remove(aside)
+			}
+			continue
+		}
+		if tries > 1000 {
+			fmt.Fprintf(os.Stderr, "\nERROR: Query history folder '%s' is locked\n", hpath)
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// pruneHistory keeps the most recent entries, rewriting the index and removing older UID sets
+func pruneHistory(hpath string, entries []historyEntry) {
+
+	if len(entries) <= historyLimit {
+		return
+	}
+
+	drop := entries[:len(entries)-historyLimit]
+	keep := entries[len(entries)-historyLimit:]
+
+	var buffer strings.Builder
+	for _, ent := range keep {
+		fmt.Fprintf(&buffer, "%d\t%d\t%s\n", ent.num, ent.count, ent.query)
+	}
+
+	// replace index in one step, so readers never see a partial file
+	tmp := path.Join(hpath, "history.tmp")
+	err := os.WriteFile(tmp, []byte(buffer.String()), 0644)
+	if err == nil {
+		err = os.Rename(tmp, path.Join(hpath, "history.txt"))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return
+	}
+
+	for _, ent := range drop {
+		os.Remove(path.Join(hpath, strconv.Itoa(ent.num)+".uid"))
+	}
+}
+
+// saveQueryHistory records a search result under the next history number, returning that number
+func saveQueryHistory(base, query string, result *PostingList) int {
+
+	if !recordHistory {
+		return 0
+	}
+
+	hpath := historyPath(base)
+	if hpath == "" {
+		return 0
+	}
+
+	err := os.MkdirAll(hpath, os.ModePerm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create query history folder '%s'\n", hpath)
+		return 0
+	}
+
+	unlock := lockHistory(hpath)
+	if unlock == nil {
+		return 0
+	}
+
+	defer unlock()
+
+	num := 1
+	entries := readHistoryIndex(base)
+	if len(entries) > 0 {
+		num = entries[len(entries)-1].num + 1
+	} else {
+		abs, _ := filepath.Abs(base)
+		os.WriteFile(path.Join(hpath, "postings.txt"), []byte(abs+"\n"), 0644)
+	}
+
+	var data []byte
+	if ids := result.IDs(); len(ids) > 0 {
+		data = encodePostingList(ids)
+	}
+
+	// never overwrite an existing set, even one missing from the index
+	var uidFile *os.File
+	for {
+		uidFile, err = os.OpenFile(path.Join(hpath, strconv.Itoa(num)+".uid"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil || !os.IsExist(err) {
+			break
+		}
+		num++
+	}
+	if err == nil {
+		_, err = uidFile.Write(data)
+		if cerr := uidFile.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to save query history #%d\n", num)
+		return 0
+	}
+
+	// tabs and line breaks would corrupt the index
+	query = strings.Join(strings.Fields(query), " ")
+
+	outFile, err := os.OpenFile(path.Join(hpath, "history.txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to save query history #%d\n", num)
+		return 0
+	}
+
+	fmt.Fprintf(outFile, "%d\t%d\t%s\n", num, result.Len(), query)
+
+	outFile.Close()
+
+	pruneHistory(hpath, append(entries, historyEntry{num, result.Len(), query}))
+
+	return num
+}
+
+// readHistorySet returns the UIDs saved for a "#n" reference
+func readHistorySet(base, ref string) *PostingList {
+
+	num := strings.TrimPrefix(ref, "#")
+
+	hpath := historyPath(base)
+	if hpath == "" {
+		fmt.Fprintf(os.Stderr, "\nERROR: Query history is not available for %s\n", ref)
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(path.Join(hpath, num+".uid"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Query history %s not found, use -history to list saved queries\n", ref)
+		os.Exit(1)
+	}
+
+	if len(data) < 1 {
+		// saved search had no results
+		return nil
+	}
+
+	return decodePostingList(data)
+}
+
+// ProcessHistory prints the number, result count, and text of each saved query
+func ProcessHistory(base string) int {
+
+	entries := readHistoryIndex(base)
+
+	for _, ent := range entries {
+		fmt.Fprintf(os.Stdout, "#%d\t%d\t%s\n", ent.num, ent.count, ent.query)
+	}
+
+	return len(entries)
+}
@@ -0,0 +1,384 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  idxhead.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// An optional postings.hdr file at the top of a postings directory records the identifier
+// and word position widths used by all fields in that index. Without it, postings hold 32-bit
+// UIDs and 16-bit positions, as in the original format.
+//
+// String identifiers (e.g., accessions) are stored as 64-bit ordinals, with the postings.uid
+// dictionary file holding one identifier per line in ordinal order. New identifiers are
+// appended as they are first seen, so ordinals are stable across incremental promotions.
+
+// PostingsHeader describes identifier type ("int32", "int64", or "string") and position bits (16 or 32)
+type PostingsHeader struct {
+	IDType  string
+	PosBits int
+}
+
+// DefaultPostingsHeader matches the original postings format
+var DefaultPostingsHeader = PostingsHeader{IDType: "int32", PosBits: 16}
+
+// idSize returns the number of bytes per UID in .pst files
+func (hdr PostingsHeader) idSize() int {
+
+	if hdr.IDType == "int32" {
+		return 4
+	}
+
+	return 8
+}
+
+// posSize returns the number of bytes per word position in .ofs files
+func (hdr PostingsHeader) posSize() int {
+
+	if hdr.PosBits == 32 {
+		return 4
+	}
+
+	return 2
+}
+
+// ParsePostingsHeader validates identifier type and position width arguments
+func ParsePostingsHeader(idType, posBits string) (PostingsHeader, bool) {
+
+	hdr := DefaultPostingsHeader
+
+	switch idType {
+	case "", "int32", "32":
+	case "int64", "64":
+		hdr.IDType = "int64"
+	case "string", "str":
+		hdr.IDType = "string"
+	default:
+		return hdr, false
+	}
+
+	switch posBits {
+	case "", "16", "int16":
+	case "32", "int32":
+		hdr.PosBits = 32
+	default:
+		return hdr, false
+	}
+
+	return hdr, true
+}
+
+var (
+	headerLock  sync.Mutex
+	headerCache = make(map[string]PostingsHeader)
+)
+
+// ReadPostingsHeader returns the header for a postings directory, or the default if there is none
+func ReadPostingsHeader(prom string) PostingsHeader {
+
+	headerLock.Lock()
+	defer headerLock.Unlock()
+
+	if hdr, ok := headerCache[prom]; ok {
+		return hdr
+	}
+
+	hdr := DefaultPostingsHeader
+
+	inFile, _ := commonOpenFile(prom, "postings.hdr")
+	if inFile != nil {
+
+		scanr := bufio.NewScanner(inFile)
+		for scanr.Scan() {
+			key, val := SplitInTwoLeft(strings.TrimSpace(scanr.Text()), "\t")
+			switch key {
+			case "IDType":
+				hdr.IDType = val
+			case "PosBits":
+				hdr.PosBits, _ = strconv.Atoi(val)
+			}
+		}
+
+		inFile.Close()
+
+		if _, ok := ParsePostingsHeader(hdr.IDType, strconv.Itoa(hdr.PosBits)); !ok {
+			fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized postings header in '%s'\n", path.Join(prom, "postings.hdr"))
+			os.Exit(1)
+		}
+	}
+
+	headerCache[prom] = hdr
+
+	return hdr
+}
+
+// WritePostingsHeader records identifier and position widths, refusing to change an existing index
+func WritePostingsHeader(prom string, hdr PostingsHeader) bool {
+
+	if _, err := os.Stat(path.Join(prom, "postings.hdr")); err == nil {
+		if ReadPostingsHeader(prom) != hdr {
+			fmt.Fprintf(os.Stderr, "\nERROR: Postings header in '%s' does not match requested identifier or position type\n", prom)
+			return false
+		}
+		return true
+	}
+
+	// legacy indices do not need a header file
+	if hdr == DefaultPostingsHeader {
+		return true
+	}
+
+	// fields already promoted without a header were written with default widths
+	if hasPromotedFields(prom) {
+		fmt.Fprintf(os.Stderr, "\nERROR: Postings in '%s' already use default identifier and position types\n", prom)
+		return false
+	}
+
+	err := os.MkdirAll(prom, os.ModePerm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return false
+	}
+
+	txt := "IDType\t" + hdr.IDType + "\n" + "PosBits\t" + strconv.Itoa(hdr.PosBits) + "\n"
+
+	err = os.WriteFile(path.Join(prom, "postings.hdr"), []byte(txt), 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return false
+	}
+
+	headerLock.Lock()
+	headerCache[prom] = hdr
+	headerLock.Unlock()
+
+	return true
+}
+
+// hasPromotedFields reports whether a postings directory already contains any master index files
+func hasPromotedFields(prom string) bool {
+
+	found := false
+
+	filepath.Walk(prom, func(fpath string, info os.FileInfo, err error) error {
+
+		if found || err != nil || info.IsDir() {
+			return nil
+		}
+
+		if strings.HasSuffix(fpath, ".mst") {
+			found = true
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+
+	return found
+}
+
+// uidDictionary maps string identifiers to ordinals for indices with IDType "string"
+type uidDictionary struct {
+	fpath   string
+	lock    sync.Mutex
+	ordinal map[string]int64
+	idents  []string
+	saved   int
+}
+
+var (
+	dictLock  sync.Mutex
+	dictCache = make(map[string]*uidDictionary)
+)
+
+// getUIDDictionary loads the postings.uid file once per postings directory
+func getUIDDictionary(prom string) *uidDictionary {
+
+	dictLock.Lock()
+	defer dictLock.Unlock()
+
+	if dict, ok := dictCache[prom]; ok {
+		return dict
+	}
+
+	dict := &uidDictionary{fpath: path.Join(prom, "postings.uid"), ordinal: make(map[string]int64)}
+
+	inFile, _ := commonOpenFile(prom, "postings.uid")
+	if inFile != nil {
+		scanr := bufio.NewScanner(inFile)
+		for scanr.Scan() {
+			str := scanr.Text()
+			dict.ordinal[str] = int64(len(dict.idents))
+			dict.idents = append(dict.idents, str)
+		}
+		inFile.Close()
+	}
+
+	dict.saved = len(dict.idents)
+
+	dictCache[prom] = dict
+
+	return dict
+}
+
+// lookup returns the ordinal of an identifier, optionally assigning a new one
+func (dict *uidDictionary) lookup(str string, assign bool) (int64, bool) {
+
+	dict.lock.Lock()
+	defer dict.lock.Unlock()
+
+	if val, ok := dict.ordinal[str]; ok {
+		return val, true
+	}
+	if !assign {
+		return 0, false
+	}
+
+	val := int64(len(dict.idents))
+	dict.ordinal[str] = val
+	dict.idents = append(dict.idents, str)
+
+	return val, true
+}
+
+// ident returns the string identifier for an ordinal
+func (dict *uidDictionary) ident(val int64) string {
+
+	dict.lock.Lock()
+	defer dict.lock.Unlock()
+
+	if val < 0 || val >= int64(len(dict.idents)) {
+		return ""
+	}
+
+	return dict.idents[val]
+}
+
+// save appends identifiers assigned since the dictionary was loaded
+func (dict *uidDictionary) save() {
+
+	dict.lock.Lock()
+	defer dict.lock.Unlock()
+
+	if dict.saved >= len(dict.idents) {
+		return
+	}
+
+	fl, err := os.OpenFile(dict.fpath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return
+	}
+
+	wrtr := bufio.NewWriter(fl)
+	for _, str := range dict.idents[dict.saved:] {
+		wrtr.WriteString(str)
+		wrtr.WriteString("\n")
+	}
+	wrtr.Flush()
+	fl.Close()
+
+	dict.saved = len(dict.idents)
+}
+
+// parsePostingUID converts a UID string to its stored integer form
+func parsePostingUID(prom string, hdr PostingsHeader, str string, assign bool) (int64, bool) {
+
+	switch hdr.IDType {
+	case "string":
+		return getUIDDictionary(prom).lookup(str, assign)
+	case "int64":
+		val, err := strconv.ParseInt(str, 10, 64)
+		return val, err == nil
+	}
+
+	val, err := strconv.ParseInt(str, 10, 32)
+
+	return val, err == nil
+}
+
+// formatPostingUID converts one stored UID back to its string form
+func formatPostingUID(prom string, hdr PostingsHeader, uid int64) string {
+
+	if hdr.IDType == "string" {
+		return getUIDDictionary(prom).ident(uid)
+	}
+
+	return strconv.FormatInt(uid, 10)
+}
+
+// formatPostingUIDs converts stored UIDs back to strings, in lexical order for string identifiers
+func formatPostingUIDs(prom string, hdr PostingsHeader, uids []int64) []string {
+
+	res := make([]string, len(uids))
+
+	for i, uid := range uids {
+		res[i] = formatPostingUID(prom, hdr, uid)
+	}
+
+	if hdr.IDType == "string" {
+		sort.Strings(res)
+	}
+
+	return res
+}
+
+// postingUIDOrder formats identifiers and returns the order in which to print them, matching
+// formatPostingUIDs, so that outputs with details for each record list them the same way
+func postingUIDOrder(prom string, hdr PostingsHeader, uids []int64) ([]string, []int) {
+
+	idents := make([]string, len(uids))
+	order := make([]int, len(uids))
+
+	for i, uid := range uids {
+		idents[i] = formatPostingUID(prom, hdr, uid)
+		order[i] = i
+	}
+
+	if hdr.IDType == "string" {
+		sort.SliceStable(order, func(i, j int) bool { return idents[order[i]] < idents[order[j]] })
+	}
+
+	return idents, order
+}
+
+// postingUIDLess orders numeric identifiers by value before all other identifiers, which sort
+// lexically, giving a total order for inverting and merging 64-bit and string identifiers
+func postingUIDLess(a, b string) bool {
+
+	da, db := IsAllDigits(a), IsAllDigits(b)
+	if da != db {
+		return da
+	}
+
+	if da && len(a) != len(b) {
+		// shorter string is numerically less, assuming no leading zeros
+		return len(a) < len(b)
+	}
+
+	return a < b
+}
@@ -41,8 +41,9 @@ import (
 	"unicode"
 )
 
-// CreateDispensers collects field, uid, positions, for each term
-func CreateDispensers(inp <-chan XMLRecord) <-chan []string {
+// CreateDispensers collects field, uid, positions, for each term, restricted to
+// schema fields and dropping positions for fields that do not keep them if a schema is supplied
+func CreateDispensers(schema *IndexSchema, inp <-chan XMLRecord) <-chan []string {
 
 	if inp == nil {
 		return nil
@@ -59,6 +60,10 @@ func CreateDispensers(inp <-chan XMLRecord) <-chan []string {
 	// map for inverted index
 	inverted := make(map[string][]string)
 
+	// fields not in schema are reported once
+	var slock sync.Mutex
+	skipped := make(map[string]bool)
+
 	// add single posting
 	addPost := func(fld, term, pos, uid string) {
 
@@ -94,6 +99,23 @@ func CreateDispensers(inp <-chan XMLRecord) <-chan []string {
 				currUID = content
 			} else {
 
+				if schema != nil {
+					fld := schema.Field(tag)
+					if fld == nil {
+						slock.Lock()
+						if !skipped[tag] {
+							skipped[tag] = true
+							fmt.Fprintf(os.Stderr, "\nWARNING: Skipping field '%s' not in index schema\n", tag)
+						}
+						slock.Unlock()
+						return
+					}
+					tag = fld.Name
+					if !fld.Positions {
+						attr = ""
+					}
+				}
+
 				// expand Greek letters, anglicize characters in other alphabets
 				if IsNotASCII(content) {
 
@@ -228,20 +250,8 @@ func CreateInverters(inp <-chan []string) <-chan XMLRecord {
 				}
 
 				if len(arry) > 1 {
-					sort.Slice(arry, func(i, j int) bool {
-						// numeric sort on strings checks lengths first
-						lni := len(arry[i])
-						lnj := len(arry[j])
-						// shorter string is numerically less, assuming no leading zeros
-						if lni < lnj {
-							return true
-						}
-						if lni > lnj {
-							return false
-						}
-						// same length, can now do string comparison on contents
-						return arry[i] < arry[j]
-					})
+					// numeric identifiers sort by value, 64-bit and string identifiers are also supported
+					sort.Slice(arry, func(i, j int) bool { return postingUIDLess(arry[i], arry[j]) })
 				}
 
 				// print list of UIDs, skipping duplicates
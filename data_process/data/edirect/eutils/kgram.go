@@ -0,0 +1,477 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  kgram.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TERM DICTIONARY WITH TRIGRAM INDEX FOR LEADING AND INTERNAL WILDCARDS
+
+// Term lists are distributed by prefix through PostingPath, so a wildcard
+// normally needs enough leading characters to select one directory. The
+// dictionary file for a field, e.g., Postings/TIAB/TIAB.kgm, holds the
+// entire sorted vocabulary and, for each three-character gram of every
+// term flanked by "$" boundary markers, the ordinals of the terms that
+// contain it. Patterns such as "*kinase" and "cyclo*ase" intersect the
+// lists for their grams, then confirm each candidate by glob matching.
+//
+// File layout, all values little-endian:
+//
+//	"EDKG", version, numTerms, numGrams, termBytes, listBytes (uint32)
+//	term offsets [numTerms+1]uint32, term text
+//	gram keys [numGrams][3]byte, gram offsets [numGrams+1]uint32
+//	lists of uvarint ordinal deltas
+
+const (
+	kgramMagic   = "EDKG"
+	kgramVersion = 1
+)
+
+// wildcardLimit caps the number of terms a single pattern can expand to
+var wildcardLimit = 1000
+
+// SetWildcardLimit changes the maximum number of terms searched for one wildcard pattern
+func SetWildcardLimit(num int) {
+
+	if num > 0 {
+		wildcardLimit = num
+	}
+}
+
+type termDictionary struct {
+	terms   []string
+	keys    []string
+	offsets []uint32
+	lists   []byte
+}
+
+// termGrams returns the distinct trigrams of a string, which may include boundary markers
+func termGrams(str string, acc map[string]bool) {
+
+	for i := 0; i+3 <= len(str); i++ {
+		acc[str[i:i+3]] = true
+	}
+}
+
+// needsDictionary reports wildcards that cannot be resolved within a single posting directory
+func needsDictionary(term string) bool {
+
+	pos := strings.Index(term, "*")
+	if pos < 0 || term == "*" {
+		return false
+	}
+
+	if pos < len(term)-1 {
+		// leading or internal asterisk
+		return true
+	}
+
+	// trailing asterisk within the characters that select the posting directory
+	return pos < len(PostingDir(term))
+}
+
+// globMatch tests a term against a pattern in which asterisk matches any run of characters
+func globMatch(pat, str string) bool {
+
+	parts := strings.Split(pat, "*")
+
+	if len(parts) == 1 {
+		return pat == str
+	}
+
+	if !strings.HasPrefix(str, parts[0]) {
+		return false
+	}
+	str = str[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		pos := strings.Index(str, part)
+		if pos < 0 {
+			return false
+		}
+		str = str[pos+len(part):]
+	}
+
+	return len(str) >= len(last) && strings.HasSuffix(str, last)
+}
+
+// readFieldTerms collects the vocabulary of a field from the term lists in all of its posting directories
+func readFieldTerms(prom, field string) []string {
+
+	sfx := "." + field + ".trm"
+
+	var terms []string
+
+	err := filepath.WalkDir(path.Join(prom, field), func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), sfx) {
+			return nil
+		}
+		key := strings.TrimSuffix(d.Name(), sfx)
+		trms := readTermList(path.Dir(fpath), key, field)
+		for _, str := range strings.Split(string(trms), "\n") {
+			if str != "" {
+				terms = append(terms, str)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return nil
+	}
+
+	sort.Strings(terms)
+
+	return terms
+}
+
+// WriteTermDictionary collects the vocabulary of one field from its term lists and saves the trigram index
+func WriteTermDictionary(prom, field string) bool {
+
+	terms := readFieldTerms(prom, field)
+	if len(terms) < 1 {
+		return false
+	}
+
+	// collect ordinals of terms containing each gram, in ascending order
+	grams := make(map[string][]uint32)
+	for i, str := range terms {
+		acc := make(map[string]bool)
+		termGrams("$"+str+"$", acc)
+		for gm := range acc {
+			grams[gm] = append(grams[gm], uint32(i))
+		}
+	}
+
+	var keys []string
+	for gm := range grams {
+		keys = append(keys, gm)
+	}
+	sort.Strings(keys)
+
+	var txt bytes.Buffer
+	termOffsets := make([]uint32, 0, len(terms)+1)
+	for _, str := range terms {
+		termOffsets = append(termOffsets, uint32(txt.Len()))
+		txt.WriteString(str)
+	}
+	termOffsets = append(termOffsets, uint32(txt.Len()))
+
+	var lists bytes.Buffer
+	var vbuf [binary.MaxVarintLen64]byte
+	var gramKeys bytes.Buffer
+	gramOffsets := make([]uint32, 0, len(keys)+1)
+	for _, gm := range keys {
+		gramKeys.WriteString(gm)
+		gramOffsets = append(gramOffsets, uint32(lists.Len()))
+		prev := uint32(0)
+		for _, ord := range grams[gm] {
+			n := binary.PutUvarint(vbuf[:], uint64(ord-prev))
+			lists.Write(vbuf[:n])
+			prev = ord
+		}
+	}
+	gramOffsets = append(gramOffsets, uint32(lists.Len()))
+
+	var buf bytes.Buffer
+	buf.WriteString(kgramMagic)
+	binary.Write(&buf, binary.LittleEndian, []uint32{kgramVersion, uint32(len(terms)), uint32(len(keys)), uint32(txt.Len()), uint32(lists.Len())})
+	binary.Write(&buf, binary.LittleEndian, termOffsets)
+	buf.Write(txt.Bytes())
+	buf.Write(gramKeys.Bytes())
+	binary.Write(&buf, binary.LittleEndian, gramOffsets)
+	buf.Write(lists.Bytes())
+
+	err := os.WriteFile(path.Join(prom, field, field+".kgm"), buf.Bytes(), 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return false
+	}
+
+	// discard any cached copy
+	termDictLock.Lock()
+	delete(termDictCache, path.Join(prom, field))
+	termDictLock.Unlock()
+
+	return true
+}
+
+var (
+	termDictLock  sync.Mutex
+	termDictCache = make(map[string]*termDictionary)
+
+	// expansions that exceeded the limit are reported once
+	wildcardWarned = make(map[string]bool)
+)
+
+// readTermDictionary loads and caches the dictionary for a field, returning nil if it was never built
+func readTermDictionary(prom, field string) *termDictionary {
+
+	dpath := path.Join(prom, field)
+
+	termDictLock.Lock()
+	defer termDictLock.Unlock()
+
+	if td, ok := termDictCache[dpath]; ok {
+		return td
+	}
+
+	data, err := os.ReadFile(path.Join(dpath, field+".kgm"))
+	if err != nil {
+		termDictCache[dpath] = nil
+		return nil
+	}
+
+	bad := func() *termDictionary {
+		fmt.Fprintf(os.Stderr, "\nERROR: Damaged term dictionary '%s'\n", path.Join(dpath, field+".kgm"))
+		termDictCache[dpath] = nil
+		return nil
+	}
+
+	if len(data) < 24 || string(data[:4]) != kgramMagic {
+		return bad()
+	}
+
+	u32 := func(pos int) uint32 {
+		return binary.LittleEndian.Uint32(data[pos:])
+	}
+
+	if u32(4) != kgramVersion {
+		return bad()
+	}
+
+	numTerms, numGrams := int(u32(8)), int(u32(12))
+	termBytes, listBytes := int(u32(16)), int(u32(20))
+
+	pos := 24
+	need := pos + (numTerms+1)*4 + termBytes + numGrams*3 + (numGrams+1)*4 + listBytes
+	if len(data) != need {
+		return bad()
+	}
+
+	td := &termDictionary{terms: make([]string, numTerms)}
+
+	txt := pos + (numTerms+1)*4
+	for i := 0; i < numTerms; i++ {
+		td.terms[i] = string(data[txt+int(u32(pos+i*4)) : txt+int(u32(pos+i*4+4))])
+	}
+	pos = txt + termBytes
+
+	td.keys = make([]string, numGrams)
+	for i := 0; i < numGrams; i++ {
+		td.keys[i] = string(data[pos+i*3 : pos+i*3+3])
+	}
+	pos += numGrams * 3
+
+	td.offsets = make([]uint32, numGrams+1)
+	for i := range td.offsets {
+		td.offsets[i] = u32(pos + i*4)
+	}
+	pos += (numGrams + 1) * 4
+
+	td.lists = data[pos:]
+
+	termDictCache[dpath] = td
+
+	return td
+}
+
+// gramList decodes the ordinals of terms containing one gram
+func (td *termDictionary) gramList(gm string) []uint32 {
+
+	idx := sort.SearchStrings(td.keys, gm)
+	if idx >= len(td.keys) || td.keys[idx] != gm {
+		return nil
+	}
+
+	var res []uint32
+
+	data := td.lists[td.offsets[idx]:td.offsets[idx+1]]
+	prev := uint32(0)
+	for len(data) > 0 {
+		val, n := binary.Uvarint(data)
+		if n <= 0 {
+			break
+		}
+		prev += uint32(val)
+		res = append(res, prev)
+		data = data[n:]
+	}
+
+	return res
+}
+
+// match returns all terms matching a wildcard pattern, in alphabetical order
+func (td *termDictionary) match(pat string) []string {
+
+	parts := strings.Split(pat, "*")
+	if !strings.HasPrefix(pat, "*") {
+		parts[0] = "$" + parts[0]
+	}
+	if !strings.HasSuffix(pat, "*") {
+		parts[len(parts)-1] += "$"
+	}
+
+	acc := make(map[string]bool)
+	for _, part := range parts {
+		termGrams(part, acc)
+	}
+
+	var res []string
+
+	if len(acc) < 1 {
+		// pattern too short for any gram, scan entire vocabulary
+		for _, str := range td.terms {
+			if globMatch(pat, str) {
+				res = append(res, str)
+			}
+		}
+		return res
+	}
+
+	var lists [][]uint32
+	for gm := range acc {
+		lst := td.gramList(gm)
+		if len(lst) < 1 {
+			return nil
+		}
+		lists = append(lists, lst)
+	}
+
+	// intersect starting with the shortest list
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	cands := lists[0]
+	for _, lst := range lists[1:] {
+		var next []uint32
+		i, j := 0, 0
+		for i < len(cands) && j < len(lst) {
+			if cands[i] < lst[j] {
+				i++
+			} else if cands[i] > lst[j] {
+				j++
+			} else {
+				next = append(next, cands[i])
+				i++
+				j++
+			}
+		}
+		cands = next
+		if len(cands) < 1 {
+			return nil
+		}
+	}
+
+	// grams can match out of order, so confirm each candidate
+	for _, ord := range cands {
+		str := td.terms[ord]
+		if globMatch(pat, str) {
+			res = append(res, str)
+		}
+	}
+
+	return res
+}
+
+// expandWildcard returns the terms matching a leading or internal wildcard, up to the expansion limit
+func expandWildcard(prom, pat, field string) []string {
+
+	td := readTermDictionary(prom, field)
+	if td == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Wildcard '%s' requires a term dictionary for [%s], create with rchive -kgrams\n", pat, field)
+		return nil
+	}
+
+	res := td.match(strings.Replace(pat, "_", " ", -1))
+
+	if len(res) > wildcardLimit {
+		termDictLock.Lock()
+		warned := wildcardWarned[field+"\t"+pat]
+		wildcardWarned[field+"\t"+pat] = true
+		termDictLock.Unlock()
+		if !warned {
+			fmt.Fprintf(os.Stderr, "\nWARNING: Wildcard '%s' matches %d terms in [%s], only the first %d are searched, use -wildlimit to raise\n", pat, len(res), field, wildcardLimit)
+		}
+		res = res[:wildcardLimit]
+	}
+
+	return res
+}
+
+// getDictionaryPostingIDs combines the postings and positions of all terms matching a wildcard
+func getDictionaryPostingIDs(prom, pat, field string, simple bool) ([]int64, [][]int32) {
+
+	terms := expandWildcard(prom, pat, field)
+
+	return mergeTermPostingIDs(prom, terms, field, simple)
+}
+
+// mergeTermPostingIDs combines the postings of several terms into a single list, as if they were one term
+func mergeTermPostingIDs(prom string, terms []string, field string, simple bool) ([]int64, [][]int32) {
+
+	if len(terms) < 1 {
+		return nil, nil
+	}
+
+	combo := make(map[int64][]int32)
+
+	for _, str := range terms {
+		data, ofst := getPostingIDs(prom, str, field, simple)
+		for i, uid := range data {
+			if simple || ofst == nil {
+				combo[uid] = nil
+				continue
+			}
+			combo[uid] = append(combo[uid], ofst[i]...)
+		}
+	}
+
+	fused := make([]int64, 0, len(combo))
+	for uid := range combo {
+		fused = append(fused, uid)
+	}
+	sort.Slice(fused, func(i, j int) bool { return fused[i] < fused[j] })
+
+	if simple {
+		return fused, nil
+	}
+
+	// positions from different terms are merged in ascending order
+	var psns [][]int32
+	for _, uid := range fused {
+		pos := combo[uid]
+		sort.Slice(pos, func(i, j int) bool { return pos[i] < pos[j] })
+		psns = append(psns, pos)
+	}
+
+	return fused, psns
+}
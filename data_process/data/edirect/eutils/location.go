@@ -0,0 +1,628 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  location.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// INSDC feature location syntax is described in section 3.4 of the Feature Table
+// Definition document at https://www.insdc.org/submitting-standards/feature-table/
+
+// LocInterval is one span of a feature location, with From not greater than To,
+// LeftFuzzy for "<" on From and RightFuzzy for ">" on To, and Between for "^" sites
+type LocInterval struct {
+	Accession  string
+	From       int
+	To         int
+	Minus      bool
+	LeftFuzzy  bool
+	RightFuzzy bool
+	Between    bool
+}
+
+// Location holds intervals in biological order, with Operator "join" or "order"
+type Location struct {
+	Operator  string
+	Intervals []LocInterval
+}
+
+// Length returns the number of bases covered by the location, counting remote intervals
+func (iv LocInterval) Length() int {
+
+	if iv.Between {
+		return 0
+	}
+
+	return iv.To - iv.From + 1
+}
+
+// Partial5 and Partial3 report fuzzy ends in the direction of transcription
+func (iv LocInterval) Partial5() bool {
+
+	if iv.Minus {
+		return iv.RightFuzzy
+	}
+
+	return iv.LeftFuzzy
+}
+
+func (iv LocInterval) Partial3() bool {
+
+	if iv.Minus {
+		return iv.LeftFuzzy
+	}
+
+	return iv.RightFuzzy
+}
+
+// String formats a single interval without the complement operator
+func (iv LocInterval) String() string {
+
+	var buffer strings.Builder
+
+	if iv.Accession != "" {
+		buffer.WriteString(iv.Accession)
+		buffer.WriteString(":")
+	}
+	if iv.LeftFuzzy {
+		buffer.WriteString("<")
+	} else if iv.RightFuzzy && iv.From == iv.To && !iv.Between {
+		// single base with fuzzy 3' end, e.g., ">100"
+		buffer.WriteString(">")
+	}
+	buffer.WriteString(strconv.Itoa(iv.From))
+	if iv.Between {
+		buffer.WriteString("^")
+		buffer.WriteString(strconv.Itoa(iv.To))
+	} else if iv.From != iv.To {
+		buffer.WriteString("..")
+		if iv.RightFuzzy {
+			buffer.WriteString(">")
+		}
+		buffer.WriteString(strconv.Itoa(iv.To))
+	}
+
+	return buffer.String()
+}
+
+// ParseLocation reads an INSDC location, e.g., "complement(join(<1..120,J00194.1:100..202,300^301))",
+// also accepting "from..to" with from greater than to as a minus strand interval
+func ParseLocation(str string) (*Location, bool) {
+
+	loc := &Location{}
+
+	// closing returns the index of the parenthesis matching the one at position idx
+	closing := func(str string, idx int) int {
+		depth := 0
+		for i := idx; i < len(str); i++ {
+			switch str[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+				if depth == 0 {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+
+	// splitTopLevel separates comma-delimited items not enclosed in parentheses
+	splitTopLevel := func(str string) []string {
+		var arry []string
+		depth := 0
+		last := 0
+		for i := 0; i < len(str); i++ {
+			switch str[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			case ',':
+				if depth == 0 {
+					arry = append(arry, str[last:i])
+					last = i + 1
+				}
+			}
+		}
+		arry = append(arry, str[last:])
+		return arry
+	}
+
+	parseNumber := func(str string) (int, bool, bool, bool) {
+		left := false
+		right := false
+		if strings.HasPrefix(str, "<") {
+			left = true
+			str = str[1:]
+		} else if strings.HasPrefix(str, ">") {
+			right = true
+			str = str[1:]
+		}
+		num, err := strconv.Atoi(str)
+		if err != nil || num < 0 {
+			return 0, false, false, false
+		}
+		return num, left, right, true
+	}
+
+	var parse func(str, accn string) ([]LocInterval, bool)
+
+	parse = func(str, accn string) ([]LocInterval, bool) {
+
+		str = strings.TrimSpace(str)
+		if str == "" {
+			return nil, false
+		}
+
+		// bare comma-separated list is treated as an implicit join
+		if items := splitTopLevel(str); len(items) > 1 {
+			var acc []LocInterval
+			for _, item := range items {
+				sub, ok := parse(item, accn)
+				if !ok {
+					return nil, false
+				}
+				acc = append(acc, sub...)
+			}
+			return acc, true
+		}
+
+		if idx := strings.Index(str, "("); idx > 0 && str[len(str)-1] == ')' && closing(str, idx) == len(str)-1 {
+
+			op := str[:idx]
+			inner := str[idx+1 : len(str)-1]
+
+			switch op {
+			case "complement":
+				items, ok := parse(inner, accn)
+				if !ok {
+					return nil, false
+				}
+				// reverse order and flip strand
+				for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+					items[i], items[j] = items[j], items[i]
+				}
+				for i := range items {
+					items[i].Minus = !items[i].Minus
+				}
+				return items, true
+			case "join", "order":
+				loc.Operator = op
+				var acc []LocInterval
+				for _, item := range splitTopLevel(inner) {
+					sub, ok := parse(item, accn)
+					if !ok {
+						return nil, false
+					}
+					acc = append(acc, sub...)
+				}
+				return acc, true
+			default:
+				return nil, false
+			}
+		}
+
+		// remote accession prefix, e.g., "J00194.1:100..202"
+		if idx := strings.Index(str, ":"); idx > 0 && !strings.Contains(str[:idx], "(") {
+			return parse(str[idx+1:], str[:idx])
+		}
+
+		iv := LocInterval{Accession: accn}
+
+		if strings.Contains(str, "^") {
+			fr, to := SplitInTwoLeft(str, "^")
+			min, _, _, ok1 := parseNumber(fr)
+			max, _, _, ok2 := parseNumber(to)
+			if !ok1 || !ok2 {
+				return nil, false
+			}
+			iv.From = min
+			iv.To = max
+			iv.Between = true
+			return []LocInterval{iv}, true
+		}
+
+		if strings.Contains(str, "..") {
+			fr, to := SplitInTwoLeft(str, "..")
+			min, lf, _, ok1 := parseNumber(fr)
+			max, _, rt, ok2 := parseNumber(to)
+			if !ok1 || !ok2 {
+				return nil, false
+			}
+			iv.From = min
+			iv.To = max
+			iv.LeftFuzzy = lf
+			iv.RightFuzzy = rt
+			if min > max {
+				// allow reversed coordinates to indicate minus strand
+				iv.From, iv.To = max, min
+				iv.LeftFuzzy, iv.RightFuzzy = rt, lf
+				iv.Minus = true
+			}
+			return []LocInterval{iv}, true
+		}
+
+		pt, lf, rt, ok := parseNumber(str)
+		if !ok {
+			return nil, false
+		}
+		iv.From = pt
+		iv.To = pt
+		iv.LeftFuzzy = lf
+		iv.RightFuzzy = rt
+
+		return []LocInterval{iv}, true
+	}
+
+	items, ok := parse(strings.Replace(str, " ", "", -1), "")
+	if !ok || len(items) < 1 {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Unable to parse location '%s'%s\n", INVT, LOUD, str, INIT)
+		return nil, false
+	}
+
+	loc.Intervals = items
+	if len(items) > 1 && loc.Operator == "" {
+		loc.Operator = "join"
+	}
+	if len(items) < 2 {
+		loc.Operator = ""
+	}
+
+	return loc, true
+}
+
+// String formats the location in INSDC syntax
+func (loc *Location) String() string {
+
+	if loc == nil || len(loc.Intervals) < 1 {
+		return ""
+	}
+
+	ivals := loc.Intervals
+
+	allMinus := true
+	for _, iv := range ivals {
+		if !iv.Minus {
+			allMinus = false
+		}
+	}
+
+	var arry []string
+
+	if allMinus {
+		// complement(join(...)) lists intervals in ascending order
+		for i := len(ivals) - 1; i >= 0; i-- {
+			arry = append(arry, ivals[i].String())
+		}
+	} else {
+		for _, iv := range ivals {
+			if iv.Minus {
+				arry = append(arry, "complement("+iv.String()+")")
+			} else {
+				arry = append(arry, iv.String())
+			}
+		}
+	}
+
+	str := arry[0]
+	if len(arry) > 1 {
+		op := loc.Operator
+		if op == "" {
+			op = "join"
+		}
+		str = op + "(" + strings.Join(arry, ",") + ")"
+	}
+
+	if allMinus {
+		str = "complement(" + str + ")"
+	}
+
+	return str
+}
+
+// Length returns the total number of bases in all intervals
+func (loc *Location) Length() int {
+
+	total := 0
+	for _, iv := range loc.Intervals {
+		total += iv.Length()
+	}
+
+	return total
+}
+
+// IsPartial5 and IsPartial3 report incomplete ends of the feature
+func (loc *Location) IsPartial5() bool {
+
+	if len(loc.Intervals) < 1 {
+		return false
+	}
+
+	return loc.Intervals[0].Partial5()
+}
+
+func (loc *Location) IsPartial3() bool {
+
+	if len(loc.Intervals) < 1 {
+		return false
+	}
+
+	return loc.Intervals[len(loc.Intervals)-1].Partial3()
+}
+
+// Extract returns the sequence under the location, reverse complementing minus strand intervals
+func (loc *Location) Extract(seq string) (string, bool) {
+
+	ln := len(seq)
+
+	var buffer strings.Builder
+
+	for _, iv := range loc.Intervals {
+		if iv.Accession != "" {
+			fmt.Fprintf(os.Stderr, "%s ERROR: %s Unable to extract remote interval '%s'%s\n", INVT, LOUD, iv.String(), INIT)
+			return "", false
+		}
+		if iv.From < 1 || iv.To > ln {
+			fmt.Fprintf(os.Stderr, "%s ERROR: %s Interval '%s' out of range%s\n", INVT, LOUD, iv.String(), INIT)
+			return "", false
+		}
+		if iv.Between {
+			continue
+		}
+		sub := seq[iv.From-1 : iv.To]
+		if iv.Minus {
+			sub = ReverseComplement(sub)
+		}
+		buffer.WriteString(sub)
+	}
+
+	return buffer.String(), true
+}
+
+// Complement returns the location on the opposite strand at the same coordinates
+func (loc *Location) Complement() *Location {
+
+	res := &Location{Operator: loc.Operator}
+
+	for i := len(loc.Intervals) - 1; i >= 0; i-- {
+		iv := loc.Intervals[i]
+		iv.Minus = !iv.Minus
+		res.Intervals = append(res.Intervals, iv)
+	}
+
+	return res
+}
+
+// ReverseComplement converts the location to coordinates on the reverse complement of a sequence
+func (loc *Location) ReverseComplement(seqLen int) *Location {
+
+	res := &Location{Operator: loc.Operator}
+
+	for _, iv := range loc.Intervals {
+		if iv.Accession == "" {
+			iv.From, iv.To = seqLen-iv.To+1, seqLen-iv.From+1
+			iv.LeftFuzzy, iv.RightFuzzy = iv.RightFuzzy, iv.LeftFuzzy
+			iv.Minus = !iv.Minus
+		}
+		res.Intervals = append(res.Intervals, iv)
+	}
+
+	return res
+}
+
+// normalizeIntervals sorts intervals by accession, strand, and position, and joins overlapping
+// or abutting intervals, returning them in biological order within each strand
+func normalizeIntervals(ivals []LocInterval) []LocInterval {
+
+	var arry []LocInterval
+	for _, iv := range ivals {
+		if !iv.Between {
+			arry = append(arry, iv)
+		}
+	}
+
+	sort.SliceStable(arry, func(i, j int) bool {
+		a := arry[i]
+		b := arry[j]
+		if a.Accession != b.Accession {
+			return a.Accession < b.Accession
+		}
+		if a.Minus != b.Minus {
+			return !a.Minus
+		}
+		return a.From < b.From
+	})
+
+	var merged []LocInterval
+	for _, iv := range arry {
+		last := len(merged) - 1
+		if last >= 0 {
+			prev := &merged[last]
+			if prev.Accession == iv.Accession && prev.Minus == iv.Minus && iv.From <= prev.To+1 {
+				if iv.To > prev.To {
+					prev.To = iv.To
+					prev.RightFuzzy = iv.RightFuzzy
+				} else if iv.To == prev.To {
+					prev.RightFuzzy = prev.RightFuzzy || iv.RightFuzzy
+				}
+				continue
+			}
+		}
+		merged = append(merged, iv)
+	}
+
+	// minus strand runs are listed from highest to lowest coordinate
+	for i := 0; i < len(merged); {
+		j := i
+		for j < len(merged) && merged[j].Accession == merged[i].Accession && merged[j].Minus == merged[i].Minus {
+			j++
+		}
+		if merged[i].Minus {
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				merged[a], merged[b] = merged[b], merged[a]
+			}
+		}
+		i = j
+	}
+
+	return merged
+}
+
+// Merge combines overlapping and adjacent intervals on the same strand
+func (loc *Location) Merge() *Location {
+
+	res := &Location{Intervals: normalizeIntervals(loc.Intervals)}
+	if len(res.Intervals) > 1 {
+		res.Operator = "join"
+	}
+
+	return res
+}
+
+// Intersect returns the regions shared by two locations on the same strand, or nil if none
+func (loc *Location) Intersect(other *Location) *Location {
+
+	var arry []LocInterval
+
+	for _, a := range loc.Intervals {
+		for _, b := range other.Intervals {
+			if a.Between || b.Between || a.Accession != b.Accession || a.Minus != b.Minus {
+				continue
+			}
+			iv := LocInterval{Accession: a.Accession, From: a.From, To: a.To, Minus: a.Minus}
+			if b.From > iv.From {
+				iv.From = b.From
+			}
+			if b.To < iv.To {
+				iv.To = b.To
+			}
+			if iv.From > iv.To {
+				continue
+			}
+			iv.LeftFuzzy = (iv.From == a.From && a.LeftFuzzy) || (iv.From == b.From && b.LeftFuzzy)
+			iv.RightFuzzy = (iv.To == a.To && a.RightFuzzy) || (iv.To == b.To && b.RightFuzzy)
+			arry = append(arry, iv)
+		}
+	}
+
+	if len(arry) < 1 {
+		return nil
+	}
+
+	res := &Location{Intervals: normalizeIntervals(arry)}
+	if len(res.Intervals) > 1 {
+		res.Operator = "join"
+	}
+
+	return res
+}
+
+// MapToFeature converts a 1-based local sequence position to a 1-based offset in the feature, or 0 if outside
+func (loc *Location) MapToFeature(pos int) int {
+
+	offset := 0
+
+	for _, iv := range loc.Intervals {
+		if iv.Accession == "" && !iv.Between && pos >= iv.From && pos <= iv.To {
+			if iv.Minus {
+				return offset + iv.To - pos + 1
+			}
+			return offset + pos - iv.From + 1
+		}
+		offset += iv.Length()
+	}
+
+	return 0
+}
+
+// MapToSequence converts a 1-based feature offset to a local sequence position, or 0 if remote or outside
+func (loc *Location) MapToSequence(offset int) int {
+
+	if offset < 1 {
+		return 0
+	}
+
+	for _, iv := range loc.Intervals {
+		ln := iv.Length()
+		if offset > ln {
+			offset -= ln
+			continue
+		}
+		if iv.Accession != "" {
+			return 0
+		}
+		if iv.Minus {
+			return iv.To - offset + 1
+		}
+		return iv.From + offset - 1
+	}
+
+	return 0
+}
+
+// MapToProtein converts a sequence position within a coding region to a 1-based residue number
+// and the position of the base within its codon, using the codon_start qualifier value
+func (loc *Location) MapToProtein(pos, codonStart int) (int, int) {
+
+	offset := loc.MapToFeature(pos)
+	if offset < 1 {
+		return 0, 0
+	}
+
+	if codonStart < 1 || codonStart > 3 {
+		codonStart = 1
+	}
+
+	offset -= codonStart - 1
+	if offset < 1 {
+		return 0, 0
+	}
+
+	return (offset-1)/3 + 1, (offset-1)%3 + 1
+}
+
+// MapFromProtein returns the sequence positions of the three bases of the codon encoding a residue
+func (loc *Location) MapFromProtein(residue, codonStart int) []int {
+
+	if residue < 1 {
+		return nil
+	}
+
+	if codonStart < 1 || codonStart > 3 {
+		codonStart = 1
+	}
+
+	first := (residue-1)*3 + codonStart
+
+	var arry []int
+	for i := first; i < first+3; i++ {
+		pos := loc.MapToSequence(i)
+		if pos > 0 {
+			arry = append(arry, pos)
+		}
+	}
+
+	return arry
+}
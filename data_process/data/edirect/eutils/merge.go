@@ -634,20 +634,8 @@ func CreateMergers(inp <-chan Plex) <-chan XMLRecord {
 				}
 
 				if len(arry) > 1 {
-					sort.Slice(arry, func(i, j int) bool {
-						// numeric sort on strings checks lengths first
-						lni := len(arry[i])
-						lnj := len(arry[j])
-						// shorter string is numerically less, assuming no leading zeros
-						if lni < lnj {
-							return true
-						}
-						if lni > lnj {
-							return false
-						}
-						// same length, can now do string comparison on contents
-						return arry[i] < arry[j]
-					})
+					// numeric identifiers sort by value, 64-bit and string identifiers are also supported
+					sort.Slice(arry, func(i, j int) bool { return postingUIDLess(arry[i], arry[j]) })
 				}
 
 				// print list of UIDs, skipping duplicates
@@ -0,0 +1,392 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  msa.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// Clustal, Stockholm (https://sonnhammer.sbc.su.se/Stockholm.html), and aligned FASTA
+// multiple sequence alignments are read into a common row-oriented structure
+
+// AlignmentAnnot is a per-column Stockholm #=GC line, e.g., SS_cons or RF
+type AlignmentAnnot struct {
+	Tag   string
+	Value string
+}
+
+// Alignment holds equal-length gapped rows, with '-' or '.' as gap characters
+type Alignment struct {
+	Format    string
+	SeqIDs    []string
+	Sequences []string
+	Annots    []AlignmentAnnot
+}
+
+// ColumnStat summarizes one alignment column
+type ColumnStat struct {
+	Column   int
+	Residue  byte
+	Identity float64
+	Entropy  float64
+	Gaps     float64
+}
+
+func isGapChar(ch byte) bool {
+
+	return ch == '-' || ch == '.' || ch == '~'
+}
+
+// ReadAlignment detects Clustal, Stockholm, or aligned FASTA format and reads the first alignment
+func ReadAlignment(inp io.Reader) *Alignment {
+
+	if inp == nil {
+		return nil
+	}
+
+	aln := &Alignment{}
+
+	rows := make(map[string]*strings.Builder)
+	annots := make(map[string]*strings.Builder)
+	var annotOrder []string
+
+	addRow := func(name, seq string) {
+		bldr, ok := rows[name]
+		if !ok {
+			bldr = &strings.Builder{}
+			rows[name] = bldr
+			aln.SeqIDs = append(aln.SeqIDs, name)
+		}
+		bldr.WriteString(seq)
+	}
+
+	scanr := bufio.NewScanner(inp)
+	scanr.Buffer(make([]byte, 65536), 16*1024*1024)
+
+	fastaID := ""
+
+	for scanr.Scan() {
+
+		line := strings.TrimRight(scanr.Text(), "\r")
+
+		if aln.Format == "stockholm" && strings.HasPrefix(line, "//") {
+			// only read first alignment in file
+			break
+		}
+
+		if aln.Format == "" {
+			switch {
+			case strings.TrimSpace(line) == "":
+				continue
+			case strings.HasPrefix(line, "# STOCKHOLM"):
+				aln.Format = "stockholm"
+				continue
+			case strings.HasPrefix(line, "CLUSTAL") || strings.HasPrefix(line, "MUSCLE") || strings.HasPrefix(line, "PROBCONS"):
+				aln.Format = "clustal"
+				continue
+			case strings.HasPrefix(line, ">"):
+				aln.Format = "fasta"
+			default:
+				fmt.Fprintf(os.Stderr, "%s ERROR: %s Unrecognized alignment format%s\n", INVT, LOUD, INIT)
+				return nil
+			}
+		}
+
+		switch aln.Format {
+		case "fasta":
+			if strings.HasPrefix(line, ">") {
+				fastaID, _ = SplitInTwoLeft(strings.TrimSpace(line[1:]), " ")
+				addRow(fastaID, "")
+				continue
+			}
+			if fastaID != "" {
+				addRow(fastaID, strings.Join(strings.Fields(line), ""))
+			}
+
+		case "clustal":
+			// conservation lines start with spaces
+			if line == "" || line[0] == ' ' || line[0] == '\t' {
+				continue
+			}
+			flds := strings.Fields(line)
+			if len(flds) >= 2 {
+				addRow(flds[0], flds[1])
+			}
+
+		case "stockholm":
+			if strings.HasPrefix(line, "#=GC") {
+				flds := strings.Fields(line)
+				if len(flds) >= 3 {
+					bldr, ok := annots[flds[1]]
+					if !ok {
+						bldr = &strings.Builder{}
+						annots[flds[1]] = bldr
+						annotOrder = append(annotOrder, flds[1])
+					}
+					bldr.WriteString(flds[2])
+				}
+				continue
+			}
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			flds := strings.Fields(line)
+			if len(flds) >= 2 {
+				addRow(flds[0], flds[1])
+			}
+		}
+
+	}
+
+	if len(aln.SeqIDs) < 1 {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s No aligned sequences found%s\n", INVT, LOUD, INIT)
+		return nil
+	}
+
+	for _, name := range aln.SeqIDs {
+		aln.Sequences = append(aln.Sequences, rows[name].String())
+	}
+	for _, tag := range annotOrder {
+		aln.Annots = append(aln.Annots, AlignmentAnnot{Tag: tag, Value: annots[tag].String()})
+	}
+
+	width := len(aln.Sequences[0])
+	for i, seq := range aln.Sequences {
+		if len(seq) != width {
+			fmt.Fprintf(os.Stderr, "%s ERROR: %s Aligned sequence '%s' length %d differs from %d%s\n", INVT, LOUD, aln.SeqIDs[i], len(seq), width, INIT)
+			return nil
+		}
+	}
+
+	return aln
+}
+
+// Length returns the number of alignment columns
+func (aln *Alignment) Length() int {
+
+	if len(aln.Sequences) < 1 {
+		return 0
+	}
+
+	return len(aln.Sequences[0])
+}
+
+// selectColumns builds a new alignment from the columns where keep returns true
+func (aln *Alignment) selectColumns(keep func(col int) bool) *Alignment {
+
+	res := &Alignment{Format: aln.Format, SeqIDs: aln.SeqIDs}
+
+	filter := func(str string) string {
+		var buffer strings.Builder
+		for col := 0; col < len(str); col++ {
+			if keep(col) {
+				buffer.WriteByte(str[col])
+			}
+		}
+		return buffer.String()
+	}
+
+	for _, seq := range aln.Sequences {
+		res.Sequences = append(res.Sequences, filter(seq))
+	}
+	for _, ant := range aln.Annots {
+		if len(ant.Value) == aln.Length() {
+			res.Annots = append(res.Annots, AlignmentAnnot{Tag: ant.Tag, Value: filter(ant.Value)})
+		}
+	}
+
+	return res
+}
+
+// Columns returns the sub-alignment for 1-based inclusive columns from..to
+func (aln *Alignment) Columns(from, to int) *Alignment {
+
+	return aln.selectColumns(func(col int) bool {
+		return col+1 >= from && col+1 <= to
+	})
+}
+
+// StripGaps removes columns whose fraction of gap characters exceeds maxGaps,
+// so 0 removes every column containing a gap and 0.999 removes only all-gap columns
+func (aln *Alignment) StripGaps(maxGaps float64) *Alignment {
+
+	num := float64(len(aln.Sequences))
+
+	return aln.selectColumns(func(col int) bool {
+		gaps := 0
+		for _, seq := range aln.Sequences {
+			if isGapChar(seq[col]) {
+				gaps++
+			}
+		}
+		return float64(gaps)/num <= maxGaps
+	})
+}
+
+// ColumnStats reports the most common residue, its fraction among ungapped rows,
+// Shannon entropy in bits over ungapped rows, and fraction of gaps, for each column
+func (aln *Alignment) ColumnStats() []ColumnStat {
+
+	var stats []ColumnStat
+
+	num := float64(len(aln.Sequences))
+
+	for col := 0; col < aln.Length(); col++ {
+
+		var counts [256]int
+		gaps := 0
+
+		for _, seq := range aln.Sequences {
+			ch := seq[col]
+			if isGapChar(ch) {
+				gaps++
+				continue
+			}
+			if ch >= 'a' && ch <= 'z' {
+				ch -= 'a' - 'A'
+			}
+			counts[ch]++
+		}
+
+		st := ColumnStat{Column: col + 1, Residue: '-', Gaps: float64(gaps) / num}
+
+		ungapped := float64(len(aln.Sequences) - gaps)
+		if ungapped > 0 {
+			best := 0
+			for ch, cnt := range counts {
+				if cnt == 0 {
+					continue
+				}
+				if cnt > best {
+					best = cnt
+					st.Residue = byte(ch)
+				}
+				frac := float64(cnt) / ungapped
+				st.Entropy -= frac * math.Log2(frac)
+			}
+			st.Identity = float64(best) / ungapped
+			if st.Entropy == 0 {
+				// avoid printing negative zero
+				st.Entropy = 0
+			}
+		}
+
+		stats = append(stats, st)
+	}
+
+	return stats
+}
+
+// Consensus returns the most common residue in each column where it reaches the threshold fraction of
+// ungapped rows, otherwise N for nucleotides or X for proteins, skipping columns that are mostly gaps
+func (aln *Alignment) Consensus(threshold float64) string {
+
+	isNuc := true
+	for _, seq := range aln.Sequences {
+		if strings.Trim(strings.ToUpper(seq), "ACGTUN-.~") != "" {
+			isNuc = false
+			break
+		}
+	}
+
+	unknown := byte('X')
+	if isNuc {
+		unknown = 'N'
+	}
+
+	var buffer strings.Builder
+
+	for _, st := range aln.ColumnStats() {
+		if st.Gaps > 0.5 {
+			continue
+		}
+		if st.Identity >= threshold {
+			buffer.WriteByte(st.Residue)
+		} else {
+			buffer.WriteByte(unknown)
+		}
+	}
+
+	return buffer.String()
+}
+
+// FASTA formats the alignment as aligned FASTA with 60 columns per line
+func (aln *Alignment) FASTA() string {
+
+	var buffer strings.Builder
+
+	for i, seq := range aln.Sequences {
+		buffer.WriteString(">" + aln.SeqIDs[i] + "\n")
+		for j := 0; j < len(seq); j += 60 {
+			k := j + 60
+			if k > len(seq) {
+				k = len(seq)
+			}
+			buffer.WriteString(seq[j:k] + "\n")
+		}
+	}
+
+	return buffer.String()
+}
+
+// XML formats the alignment for xtract, with gapped and ungapped residues for each row
+func (aln *Alignment) XML() string {
+
+	var buffer strings.Builder
+
+	writeOneElement := func(spaces, tag, value string) {
+		buffer.WriteString(spaces + "<" + tag + ">" + html.EscapeString(value) + "</" + tag + ">\n")
+	}
+
+	buffer.WriteString("<Alignment>\n")
+	writeOneElement("  ", "Format", aln.Format)
+	writeOneElement("  ", "Length", fmt.Sprintf("%d", aln.Length()))
+	writeOneElement("  ", "Count", fmt.Sprintf("%d", len(aln.Sequences)))
+
+	for i, seq := range aln.Sequences {
+		ungapped := strings.Map(func(c rune) rune {
+			if c < 256 && isGapChar(byte(c)) {
+				return -1
+			}
+			return c
+		}, seq)
+		buffer.WriteString("  <Row>\n")
+		writeOneElement("    ", "SeqID", aln.SeqIDs[i])
+		writeOneElement("    ", "Aligned", seq)
+		writeOneElement("    ", "Residues", ungapped)
+		writeOneElement("    ", "Length", fmt.Sprintf("%d", len(ungapped)))
+		buffer.WriteString("  </Row>\n")
+	}
+
+	for _, ant := range aln.Annots {
+		buffer.WriteString("  <Annotation>\n")
+		writeOneElement("    ", "Tag", ant.Tag)
+		writeOneElement("    ", "Value", ant.Value)
+		buffer.WriteString("  </Annotation>\n")
+	}
+
+	buffer.WriteString("</Alignment>\n")
+
+	return buffer.String()
+}
@@ -0,0 +1,388 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  pcsdf.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// element symbols indexed by atomic number
+var elementSymbols = []string{
+	"", "H", "He", "Li", "Be", "B", "C", "N", "O", "F", "Ne",
+	"Na", "Mg", "Al", "Si", "P", "S", "Cl", "Ar", "K", "Ca",
+	"Sc", "Ti", "V", "Cr", "Mn", "Fe", "Co", "Ni", "Cu", "Zn",
+	"Ga", "Ge", "As", "Se", "Br", "Kr", "Rb", "Sr", "Y", "Zr",
+	"Nb", "Mo", "Tc", "Ru", "Rh", "Pd", "Ag", "Cd", "In", "Sn",
+	"Sb", "Te", "I", "Xe", "Cs", "Ba", "La", "Ce", "Pr", "Nd",
+	"Pm", "Sm", "Eu", "Gd", "Tb", "Dy", "Ho", "Er", "Tm", "Yb",
+	"Lu", "Hf", "Ta", "W", "Re", "Os", "Ir", "Pt", "Au", "Hg",
+	"Tl", "Pb", "Bi", "Po", "At", "Rn", "Fr", "Ra", "Ac", "Th",
+	"Pa", "U", "Np", "Pu", "Am", "Cm", "Bk", "Cf", "Es", "Fm",
+	"Md", "No", "Lr", "Rf", "Db", "Sg", "Bh", "Hs", "Mt", "Ds",
+	"Rg", "Cn", "Nh", "Fl", "Mc", "Lv", "Ts", "Og",
+}
+
+// pcProperty is a labeled value from the PC-Compound_props block
+type pcProperty struct {
+	label string
+	name  string
+	value string
+}
+
+// pcCompound holds the connection table, first conformer, and properties of a PC-Compound record,
+// with explicit hydrogens kept as atoms in their original order
+type pcCompound struct {
+	cid   string
+	mol   Molecule
+	x     []float64
+	y     []float64
+	z     []float64
+	is3D  bool
+	props []pcProperty
+}
+
+// parsePCCompound reads atoms, bonds, coordinates, and properties from PC-Compound XML
+func parsePCCompound(text string) *pcCompound {
+
+	pat := ParseRecord(text, "PC-Compound")
+	if pat == nil {
+		return nil
+	}
+
+	pc := &pcCompound{}
+
+	// collect contents of all matching elements under a given parent
+	listOf := func(node *XMLNode, prnt, match string) []string {
+		var vals []string
+		ExploreNodes(node, prnt, match, 0, 0, func(nd *XMLNode, idx, lvl int) {
+			vals = append(vals, nd.Contents)
+		})
+		return vals
+	}
+
+	contentOf := func(node *XMLNode, name string) string {
+		for chld := node.Children; chld != nil; chld = chld.Next {
+			if chld.Name == name {
+				return html.UnescapeString(chld.Contents)
+			}
+		}
+		return ""
+	}
+
+	cids := listOf(pat, "PC-CompoundType_id", "PC-CompoundType_id_cid")
+	if len(cids) > 0 {
+		pc.cid = cids[0]
+	}
+
+	// atom identifiers map to positions in the atom table
+	aidToIndex := make(map[string]int)
+	aids := listOf(pat, "PC-Atoms_aid", "PC-Atoms_aid_E")
+	elems := listOf(pat, "PC-Atoms_element", "PC-Element")
+
+	for i, aid := range aids {
+		atm := MolAtom{Element: "*", Bracket: true}
+		if i < len(elems) {
+			num, err := strconv.Atoi(elems[i])
+			if err == nil && num > 0 && num < len(elementSymbols) {
+				atm.Element = elementSymbols[num]
+			}
+		}
+		aidToIndex[aid] = len(pc.mol.Atoms)
+		pc.mol.Atoms = append(pc.mol.Atoms, atm)
+	}
+
+	// charges and isotopes are sparse lists of atom identifier and value pairs
+	ExploreNodes(pat, "PC-Atoms_charge", "PC-AtomInt", 0, 0, func(node *XMLNode, idx, lvl int) {
+		if k, ok := aidToIndex[contentOf(node, "PC-AtomInt_aid")]; ok {
+			pc.mol.Atoms[k].Charge, _ = strconv.Atoi(contentOf(node, "PC-AtomInt_value"))
+		}
+	})
+	ExploreNodes(pat, "PC-Atoms_isotope", "PC-AtomInt", 0, 0, func(node *XMLNode, idx, lvl int) {
+		if k, ok := aidToIndex[contentOf(node, "PC-AtomInt_aid")]; ok {
+			pc.mol.Atoms[k].Isotope, _ = strconv.Atoi(contentOf(node, "PC-AtomInt_value"))
+		}
+	})
+
+	aid1 := listOf(pat, "PC-Bonds_aid1", "PC-Bonds_aid1_E")
+	aid2 := listOf(pat, "PC-Bonds_aid2", "PC-Bonds_aid2_E")
+	ords := listOf(pat, "PC-Bonds_order", "PC-BondType")
+
+	for i := range aid1 {
+		if i >= len(aid2) {
+			break
+		}
+		from, ok1 := aidToIndex[aid1[i]]
+		to, ok2 := aidToIndex[aid2[i]]
+		if !ok1 || !ok2 {
+			continue
+		}
+		ord := 1
+		if i < len(ords) {
+			ord, _ = strconv.Atoi(ords[i])
+		}
+		pc.mol.Bonds = append(pc.mol.Bonds, MolBond{From: from, To: to, Order: ord})
+	}
+
+	// use the first coordinate set and its first conformer
+	first := true
+	ExploreNodes(pat, "PC-Compound_coords", "PC-Coordinates", 0, 0, func(node *XMLNode, idx, lvl int) {
+
+		if !first {
+			return
+		}
+		first = false
+
+		for _, typ := range listOf(node, "PC-Coordinates_type", "PC-CoordinateType") {
+			// threed is value 2 in the PC-CoordinateType enumeration
+			if typ == "2" {
+				pc.is3D = true
+			}
+		}
+
+		cids := listOf(node, "PC-Coordinates_aid", "PC-Coordinates_aid_E")
+
+		pc.x = make([]float64, len(pc.mol.Atoms))
+		pc.y = make([]float64, len(pc.mol.Atoms))
+		pc.z = make([]float64, len(pc.mol.Atoms))
+
+		conf := true
+		ExploreNodes(node, "PC-Coordinates_conformers", "PC-Conformer", 0, 0, func(cnf *XMLNode, idx, lvl int) {
+
+			if !conf {
+				return
+			}
+			conf = false
+
+			fill := func(dest []float64, vals []string) {
+				for i, val := range vals {
+					if i >= len(cids) {
+						break
+					}
+					if k, ok := aidToIndex[cids[i]]; ok {
+						dest[k], _ = strconv.ParseFloat(val, 64)
+					}
+				}
+			}
+
+			fill(pc.x, listOf(cnf, "PC-Conformer_x", "PC-Conformer_x_E"))
+			fill(pc.y, listOf(cnf, "PC-Conformer_y", "PC-Conformer_y_E"))
+			fill(pc.z, listOf(cnf, "PC-Conformer_z", "PC-Conformer_z_E"))
+		})
+	})
+
+	ExploreNodes(pat, "PC-Compound_props", "PC-InfoData", 0, 0, func(node *XMLNode, idx, lvl int) {
+
+		prop := pcProperty{}
+
+		ExploreNodes(node, "PC-InfoData_urn", "PC-Urn", 0, 0, func(urn *XMLNode, idx, lvl int) {
+			prop.label = contentOf(urn, "PC-Urn_label")
+			prop.name = contentOf(urn, "PC-Urn_name")
+		})
+
+		ExploreNodes(node, "PC-InfoData", "PC-InfoData_value", 0, 0, func(val *XMLNode, idx, lvl int) {
+			for _, fld := range []string{"PC-InfoData_value_sval", "PC-InfoData_value_fval", "PC-InfoData_value_ival", "PC-InfoData_value_bval"} {
+				if str := contentOf(val, fld); str != "" {
+					prop.value = str
+					break
+				}
+			}
+		})
+
+		if prop.label != "" && prop.value != "" {
+			pc.props = append(pc.props, prop)
+		}
+	})
+
+	return pc
+}
+
+// property returns the first value with the given label, preferring the listed names in order
+func (pc *pcCompound) property(label string, names ...string) string {
+
+	for _, name := range names {
+		for _, prop := range pc.props {
+			if prop.label == label && prop.name == name {
+				return prop.value
+			}
+		}
+	}
+
+	for _, prop := range pc.props {
+		if prop.label == label {
+			return prop.value
+		}
+	}
+
+	return ""
+}
+
+// molBlock writes an MDL V2000 connection table, with charges and isotopes repeated in M lines
+func (pc *pcCompound) molBlock() string {
+
+	var buffer strings.Builder
+
+	dim := "2D"
+	if pc.is3D {
+		dim = "3D"
+	}
+
+	buffer.WriteString(pc.cid + "\n")
+	// date and time columns are left blank so output is reproducible
+	buffer.WriteString("  -EUTILS-          " + dim + "\n")
+	buffer.WriteString("\n")
+
+	atoms := pc.mol.Atoms
+	bonds := pc.mol.Bonds
+
+	fmt.Fprintf(&buffer, "%3d%3d  0  0  0  0  0  0  0  0999 V2000\n", len(atoms), len(bonds))
+
+	// atom block charge codes, 4 is reserved for doublet radical
+	chgCode := map[int]int{3: 1, 2: 2, 1: 3, -1: 5, -2: 6, -3: 7}
+
+	var charged []int
+	var labeled []int
+
+	for i, atm := range atoms {
+		x, y, z := 0.0, 0.0, 0.0
+		if i < len(pc.x) {
+			x, y, z = pc.x[i], pc.y[i], pc.z[i]
+		}
+		sym := atm.Element
+		if sym == "*" {
+			sym = "A"
+		}
+		fmt.Fprintf(&buffer, "%10.4f%10.4f%10.4f %-3s 0%3d  0  0  0  0  0  0  0  0  0  0\n", x, y, z, sym, chgCode[atm.Charge])
+		if atm.Charge != 0 {
+			charged = append(charged, i)
+		}
+		if atm.Isotope != 0 {
+			labeled = append(labeled, i)
+		}
+	}
+
+	for _, bnd := range bonds {
+		// PC-BondType quadruple, dative, complex, ionic, and unknown become any
+		ord := bnd.Order
+		if ord < 1 || ord > 3 {
+			ord = 8
+		}
+		fmt.Fprintf(&buffer, "%3d%3d%3d  0  0  0  0\n", bnd.From+1, bnd.To+1, ord)
+	}
+
+	// property lines hold at most eight entries each
+	writeProps := func(tag string, idxs []int, value func(int) int) {
+		for len(idxs) > 0 {
+			num := len(idxs)
+			if num > 8 {
+				num = 8
+			}
+			fmt.Fprintf(&buffer, "M  %s%3d", tag, num)
+			for _, k := range idxs[:num] {
+				fmt.Fprintf(&buffer, " %3d %3d", k+1, value(k))
+			}
+			buffer.WriteString("\n")
+			idxs = idxs[num:]
+		}
+	}
+
+	writeProps("CHG", charged, func(k int) int { return atoms[k].Charge })
+	writeProps("ISO", labeled, func(k int) int { return atoms[k].Isotope })
+
+	buffer.WriteString("M  END\n")
+
+	return buffer.String()
+}
+
+// PCCompoundToMOL converts a PC-Compound record to an MDL molfile
+func PCCompoundToMOL(text string) string {
+
+	pc := parsePCCompound(text)
+	if pc == nil || len(pc.mol.Atoms) < 1 {
+		return ""
+	}
+
+	return pc.molBlock()
+}
+
+// PCCompoundToSDF converts a PC-Compound record to an SD file entry, with properties as data items
+// named from their PC-Urn label and name, e.g., PUBCHEM_SMILES_CANONICAL
+func PCCompoundToSDF(text string) string {
+
+	pc := parsePCCompound(text)
+	if pc == nil || len(pc.mol.Atoms) < 1 {
+		return ""
+	}
+
+	var buffer strings.Builder
+
+	buffer.WriteString(pc.molBlock())
+
+	writeItem := func(tag, value string) {
+		buffer.WriteString("> <" + tag + ">\n")
+		buffer.WriteString(value + "\n")
+		buffer.WriteString("\n")
+	}
+
+	if pc.cid != "" {
+		writeItem("PUBCHEM_COMPOUND_CID", pc.cid)
+	}
+
+	seen := make(map[string]bool)
+
+	for _, prop := range pc.props {
+		tag := prop.label
+		if prop.name != "" {
+			tag += " " + prop.name
+		}
+		tag = "PUBCHEM_" + strings.ToUpper(strings.Join(strings.FieldsFunc(tag, func(ch rune) bool {
+			return !(ch >= 'A' && ch <= 'Z') && !(ch >= 'a' && ch <= 'z') && !(ch >= '0' && ch <= '9')
+		}), "_"))
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		writeItem(tag, prop.value)
+	}
+
+	buffer.WriteString("$$$$\n")
+
+	return buffer.String()
+}
+
+// PCCompoundToTable returns CID, canonical SMILES, InChIKey, and molecular formula, computing
+// the formula from the atom table when the record does not supply it
+func PCCompoundToTable(text string) string {
+
+	pc := parsePCCompound(text)
+	if pc == nil {
+		return ""
+	}
+
+	smiles := pc.property("SMILES", "Canonical", "Connectivity", "Absolute", "Isomeric")
+	inchikey := pc.property("InChIKey", "Standard")
+	formula := pc.property("Molecular Formula")
+
+	if formula == "" && len(pc.mol.Atoms) > 0 {
+		formula = pc.mol.Formula()
+	}
+
+	return pc.cid + "\t" + smiles + "\t" + inchikey + "\t" + formula + "\n"
+}
@@ -54,8 +54,8 @@ type Master struct {
 
 // Arrays contains postings lists and word offsets
 type Arrays struct {
-	Data []int32
-	Ofst [][]int16
+	Data []int64
+	Ofst [][]int32
 	Dist int
 }
 
@@ -132,7 +132,7 @@ func readTermList(dpath, key, field string) []byte {
 	return data
 }
 
-func readPostingData(dpath, key, field string, offset int32, size int32) []int32 {
+func readPostingData(dpath, key, field string, offset int32, size int32, hdr PostingsHeader) []int64 {
 
 	inFile, _ := commonOpenFile(dpath, key+"."+field+".pst")
 	if inFile == nil {
@@ -141,8 +141,8 @@ func readPostingData(dpath, key, field string, offset int32, size int32) []int32
 
 	defer inFile.Close()
 
-	data := make([]int32, size/4)
-	if data == nil || len(data) < 1 {
+	num := int(size) / hdr.idSize()
+	if num < 1 {
 		return nil
 	}
 
@@ -152,7 +152,18 @@ func readPostingData(dpath, key, field string, offset int32, size int32) []int32
 		return nil
 	}
 
-	err = binary.Read(inFile, binary.LittleEndian, data)
+	data := make([]int64, num)
+
+	// original format stores 32-bit UIDs, widened here to 64 bits
+	if hdr.idSize() == 4 {
+		narrow := make([]int32, num)
+		err = binary.Read(inFile, binary.LittleEndian, narrow)
+		for i, uid := range narrow {
+			data[i] = int64(uid)
+		}
+	} else {
+		err = binary.Read(inFile, binary.LittleEndian, data)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 		return nil
@@ -190,7 +201,7 @@ func readPositionIndex(dpath, key, field string, offset int32, size int32) []int
 	return data
 }
 
-func readOffsetData(dpath, key, field string, offset int32, size int32) []int16 {
+func readOffsetData(dpath, key, field string, offset int32, size int32, hdr PostingsHeader) []int32 {
 
 	inFile, _ := commonOpenFile(dpath, key+"."+field+".ofs")
 	if inFile == nil {
@@ -199,8 +210,8 @@ func readOffsetData(dpath, key, field string, offset int32, size int32) []int16
 
 	defer inFile.Close()
 
-	data := make([]int16, size/2)
-	if data == nil || len(data) < 1 {
+	num := int(size) / hdr.posSize()
+	if num < 1 {
 		return nil
 	}
 
@@ -210,7 +221,18 @@ func readOffsetData(dpath, key, field string, offset int32, size int32) []int16
 		return nil
 	}
 
-	err = binary.Read(inFile, binary.LittleEndian, data)
+	data := make([]int32, num)
+
+	// original format stores 16-bit word positions, widened here to 32 bits
+	if hdr.posSize() == 2 {
+		narrow := make([]int16, num)
+		err = binary.Read(inFile, binary.LittleEndian, narrow)
+		for i, pos := range narrow {
+			data[i] = int32(pos)
+		}
+	} else {
+		err = binary.Read(inFile, binary.LittleEndian, data)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 		return nil
@@ -267,17 +289,75 @@ func readTermListFuture(dpath, key, field string) <-chan []byte {
 	return out
 }
 
-func getPostingIDs(prom, term, field string, simple bool) ([]int32, [][]int16) {
+// normalizePostingTerm restores spaces, expands trailing dollar sign stemming, and detects trailing wildcards
+func normalizePostingTerm(term string) (string, bool, bool) {
+
+	// change protecting underscore to space
+	term = strings.Replace(term, "_", " ", -1)
+
+	// if term ends with dollar sign, use porter2 stemming, then add asterisk
+	if strings.HasSuffix(term, "$") && term != "$" {
+		term = strings.TrimSuffix(term, "$")
+		term = porter2.Stem(term)
+		term += "*"
+	}
+
+	isWildCard := false
+	if strings.HasSuffix(term, "*") && term != "*" {
+		tlen := len(term)
+		isWildCard = true
+		term = strings.TrimSuffix(term, "*")
+		pdlen := len(PostingDir(term))
+		if tlen < pdlen {
+			fmt.Fprintf(os.Stderr, "Wildcard term '%s' must be at least %d characters long - ignoring this word\n", term, pdlen)
+			return "", false, false
+		}
+	}
+
+	return term, isWildCard, true
+}
+
+func getPostingIDs(prom, term, field string, simple bool) ([]int64, [][]int32) {
 
 	var (
 		arry [516]rune
 	)
 
+	// leading, internal, or short wildcards are expanded through the field's term dictionary
+	if needsDictionary(term) {
+		return getDictionaryPostingIDs(prom, term, field, simple)
+	}
+
+	// ~fuzzy words are expanded to their closest indexed variants
+	if _, _, ok := parseFuzzyTerm(term); ok {
+		return getFuzzyPostingIDs(prom, term, field, simple)
+	}
+
 	dpath, key := PostingPath(prom, field, term, arry)
 	if dpath == "" {
 		return nil, nil
 	}
 
+	hdr := ReadPostingsHeader(prom)
+
+	// .uqi entries are 32-bit offsets parallel to .pst entries of idSize bytes
+	idSize := int32(hdr.idSize())
+	posSize := int32(hdr.posSize())
+
+	uqiRange := func(offset, size int32) (int32, int32) {
+		return offset / idSize * 4, size/idSize*4 + 4
+	}
+
+	// packed postings take precedence over original format files
+	if pi := readPackedIndex(dpath, key, field); pi != nil {
+		term, isWildCard, ok := normalizePostingTerm(term)
+		if !ok {
+			return nil, nil
+		}
+		data, ofst := pi.postings(term, isWildCard, simple)
+		return data.IDs(), ofst
+	}
+
 	// schedule asynchronous fetching
 	mi := readMasterIndexFuture(dpath, key, field)
 
@@ -315,26 +395,9 @@ func getPostingIDs(prom, term, field string, simple bool) ([]int32, [][]int16) {
 		strs[i] = txt
 	}
 
-	// change protecting underscore to space
-	term = strings.Replace(term, "_", " ", -1)
-
-	// if term ends with dollar sign, use porter2 stemming, then add asterisk
-	if strings.HasSuffix(term, "$") && term != "$" {
-		term = strings.TrimSuffix(term, "$")
-		term = porter2.Stem(term)
-		term += "*"
-	}
-
-	isWildCard := false
-	if strings.HasSuffix(term, "*") && term != "*" {
-		tlen := len(term)
-		isWildCard = true
-		term = strings.TrimSuffix(term, "*")
-		pdlen := len(PostingDir(term))
-		if tlen < pdlen {
-			fmt.Fprintf(os.Stderr, "Wildcard term '%s' must be at least %d characters long - ignoring this word\n", term, pdlen)
-			return nil, nil
-		}
+	term, isWildCard, ok := normalizePostingTerm(term)
+	if !ok {
+		return nil, nil
 	}
 
 	// binary search in term list
@@ -358,21 +421,21 @@ func getPostingIDs(prom, term, field string, simple bool) ([]int32, [][]int16) {
 			size := indx[R].PostOffset - offset
 
 			// read relevant postings list section
-			data := readPostingData(dpath, key, field, offset, size)
+			data := readPostingData(dpath, key, field, offset, size, hdr)
 			if data == nil || len(data) < 1 {
 				return nil, nil
 			}
 
 			if simple {
 
-				merged := make(map[int32]bool)
+				merged := make(map[int64]bool)
 
 				// combine all postings in term range
 				for _, val := range data {
 					merged[val] = true
 				}
 
-				fused := make([]int32, len(merged))
+				fused := make([]int64, len(merged))
 
 				// convert map to slice
 				i := 0
@@ -387,7 +450,8 @@ func getPostingIDs(prom, term, field string, simple bool) ([]int32, [][]int16) {
 			}
 
 			// read relevant word position section, includes phantom offset at end
-			uqis := readPositionIndex(dpath, key, field, offset, size+4)
+			uqiOffset, uqiSize := uqiRange(offset, size)
+			uqis := readPositionIndex(dpath, key, field, uqiOffset, uqiSize)
 			if uqis == nil {
 				return nil, nil
 			}
@@ -400,18 +464,18 @@ func getPostingIDs(prom, term, field string, simple bool) ([]int32, [][]int16) {
 			to := uqis[ulen-1]
 
 			// read offset section
-			ofst := readOffsetData(dpath, key, field, from, to-from)
+			ofst := readOffsetData(dpath, key, field, from, to-from, hdr)
 			if ofst == nil {
 				return nil, nil
 			}
 
-			combo := make(map[int32][]int16)
+			combo := make(map[int64][]int32)
 
-			addPositions := func(uid int32, pos int16) {
+			addPositions := func(uid int64, pos int32) {
 
 				arrs, ok := combo[uid]
 				if !ok {
-					arrs = make([]int16, 0, 1)
+					arrs = make([]int32, 0, 1)
 				}
 				arrs = append(arrs, pos)
 				combo[uid] = arrs
@@ -420,7 +484,7 @@ func getPostingIDs(prom, term, field string, simple bool) ([]int32, [][]int16) {
 			// populate array of positions per UID
 			for i, j, k := 0, 1, int32(0); i < ulen-1; i++ {
 				uid := data[i]
-				num := (uqis[j] - uqis[i]) / 2
+				num := (uqis[j] - uqis[i]) / posSize
 				j++
 				for q := k; q < k+num; q++ {
 					addPositions(uid, ofst[q])
@@ -428,7 +492,7 @@ func getPostingIDs(prom, term, field string, simple bool) ([]int32, [][]int16) {
 				k += num
 			}
 
-			fused := make([]int32, len(combo))
+			fused := make([]int64, len(combo))
 
 			// convert map to slice
 			i := 0
@@ -439,8 +503,8 @@ func getPostingIDs(prom, term, field string, simple bool) ([]int32, [][]int16) {
 
 			sort.Slice(fused, func(i, j int) bool { return fused[i] < fused[j] })
 
-			// make array of int16 arrays, populate for each UID
-			arrs := make([][]int16, ulen-1)
+			// make array of int32 arrays, populate for each UID
+			arrs := make([][]int32, ulen-1)
 			if arrs == nil {
 				return nil, nil
 			}
@@ -468,7 +532,7 @@ func getPostingIDs(prom, term, field string, simple bool) ([]int32, [][]int16) {
 		size := indx[R+1].PostOffset - offset
 
 		// read relevant postings list section
-		data := readPostingData(dpath, key, field, offset, size)
+		data := readPostingData(dpath, key, field, offset, size, hdr)
 		if data == nil || len(data) < 1 {
 			return nil, nil
 		}
@@ -478,7 +542,8 @@ func getPostingIDs(prom, term, field string, simple bool) ([]int32, [][]int16) {
 		}
 
 		// read relevant word position section, includes phantom offset at end
-		uqis := readPositionIndex(dpath, key, field, offset, size+4)
+		uqiOffset, uqiSize := uqiRange(offset, size)
+		uqis := readPositionIndex(dpath, key, field, uqiOffset, uqiSize)
 		if uqis == nil {
 			return nil, nil
 		}
@@ -491,20 +556,20 @@ func getPostingIDs(prom, term, field string, simple bool) ([]int32, [][]int16) {
 		to := uqis[ulen-1]
 
 		// read offset section
-		ofst := readOffsetData(dpath, key, field, from, to-from)
+		ofst := readOffsetData(dpath, key, field, from, to-from, hdr)
 		if ofst == nil {
 			return nil, nil
 		}
 
-		// make array of int16 arrays, populate for each UID
-		arrs := make([][]int16, ulen)
+		// make array of int32 arrays, populate for each UID
+		arrs := make([][]int32, ulen)
 		if arrs == nil || len(arrs) < 1 {
 			return nil, nil
 		}
 
 		// populate array of positions per UID
 		for i, j, k := 0, 1, int32(0); i < ulen-1; i++ {
-			num := (uqis[j] - uqis[i]) / 2
+			num := (uqis[j] - uqis[i]) / posSize
 			j++
 			arrs[i] = ofst[k : k+num]
 			k += num
@@ -529,57 +594,109 @@ func printTermCounts(base, term, field string) int {
 
 	pdlen := len(PostingDir(term))
 
-	if len(term) < pdlen {
+	if len(term) < pdlen && !needsDictionary(term) {
 		fmt.Fprintf(os.Stderr, "\nERROR: Term count argument must be at least %d characters\n", pdlen)
 		os.Exit(1)
 	}
 
-	if strings.Contains(term[:pdlen], "*") {
+	if len(term) >= pdlen && strings.Contains(term[:pdlen], "*") && !needsDictionary(term) {
 		fmt.Fprintf(os.Stderr, "\nERROR: Wildcard asterisk must not be in first %d characters\n", pdlen)
 		os.Exit(1)
 	}
 
+	strs, sizes := expandTermCounts(base, term, field)
+
+	for i, str := range strs {
+		fmt.Fprintf(os.Stdout, "%d\t%s\n", sizes[i], str)
+	}
+
+	return len(strs)
+}
+
+// readTermCounts returns the terms in the posting directory for a given term,
+// plus a function giving the document frequency of the term at each index
+func readTermCounts(base, term, field string) ([]string, func(R int) int) {
+
 	var arry [516]rune
 	dpath, key := PostingPath(base, field, term, arry)
 	if dpath == "" {
-		return 0
+		return nil, nil
 	}
 
-	// schedule asynchronous fetching
-	mi := readMasterIndexFuture(dpath, key, field)
+	var strs []string
+	var sizeOf func(R int) int
 
-	tl := readTermListFuture(dpath, key, field)
+	hdr := ReadPostingsHeader(base)
 
-	// fetch master index and term list
-	indx := <-mi
+	if pi := readPackedIndex(dpath, key, field); pi != nil {
 
-	trms := <-tl
+		// packed postings record document frequency at the start of each UID section
+		strs = pi.terms
+		sizeOf = pi.count
 
-	if indx == nil || len(indx) < 1 {
-		return 0
-	}
+	} else {
 
-	if trms == nil || len(trms) < 1 {
-		return 0
-	}
+		// schedule asynchronous fetching
+		mi := readMasterIndexFuture(dpath, key, field)
 
-	// master index is padded with phantom term and postings position
-	numTerms := len(indx) - 1
+		tl := readTermListFuture(dpath, key, field)
 
-	strs := make([]string, numTerms)
-	if strs == nil || len(strs) < 1 {
-		return 0
+		// fetch master index and term list
+		indx := <-mi
+
+		trms := <-tl
+
+		if indx == nil || len(indx) < 1 {
+			return nil, nil
+		}
+
+		if trms == nil || len(trms) < 1 {
+			return nil, nil
+		}
+
+		// master index is padded with phantom term and postings position
+		numTerms := len(indx) - 1
+
+		strs = make([]string, numTerms)
+		if strs == nil || len(strs) < 1 {
+			return nil, nil
+		}
+
+		retlength := int32(len("\n"))
+
+		// populate array of strings from term list
+		for i, j := 0, 1; i < numTerms; i++ {
+			from := indx[i].TermOffset
+			to := indx[j].TermOffset - retlength
+			j++
+			txt := string(trms[from:to])
+			strs[i] = txt
+		}
+
+		sizeOf = func(R int) int {
+			return int(indx[R+1].PostOffset-indx[R].PostOffset) / hdr.idSize()
+		}
 	}
 
-	retlength := int32(len("\n"))
+	return strs, sizeOf
+}
 
-	// populate array of strings from term list
-	for i, j := 0, 1; i < numTerms; i++ {
-		from := indx[i].TermOffset
-		to := indx[j].TermOffset - retlength
-		j++
-		txt := string(trms[from:to])
-		strs[i] = txt
+// expandTermCounts returns the terms matching a wildcard pattern, with their document counts
+func expandTermCounts(base, term, field string) ([]string, []int) {
+
+	if needsDictionary(term) {
+		strs := expandWildcard(base, term, field)
+		sizes := make([]int, len(strs))
+		for i, str := range strs {
+			data, _ := getPostingIDs(base, str, field, true)
+			sizes[i] = len(data)
+		}
+		return strs, sizes
+	}
+
+	strs, sizeOf := readTermCounts(base, term, field)
+	if strs == nil {
+		return nil, nil
 	}
 
 	// change protecting underscore to space
@@ -595,21 +712,20 @@ func printTermCounts(base, term, field string) int {
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
-		return 0
+		return nil, nil
 	}
 
-	count := 0
+	var matches []string
+	var sizes []int
 
 	for R, str := range strs {
 		if re.MatchString(str) {
-			offset := indx[R].PostOffset
-			size := indx[R+1].PostOffset - offset
-			fmt.Fprintf(os.Stdout, "%d\t%s\n", size/4, str)
-			count++
+			matches = append(matches, str)
+			sizes = append(sizes, sizeOf(R))
 		}
 	}
 
-	return count
+	return matches, sizes
 }
 
 func printTermPositions(base, term, field string) int {
@@ -618,8 +734,12 @@ func printTermPositions(base, term, field string) int {
 	size := len(data)
 	fmt.Fprintf(os.Stdout, "\n%d\t%s\n\n", size, term)
 
-	for i := 0; i < len(data); i++ {
-		fmt.Fprintf(os.Stdout, "%12d\t", data[i])
+	hdr := ReadPostingsHeader(base)
+
+	idents, order := postingUIDOrder(base, hdr, data)
+
+	for _, i := range order {
+		fmt.Fprintf(os.Stdout, "%12s\t", idents[i])
 		pos := ofst[i]
 		sep := ""
 		for j := 0; j < len(pos); j++ {
@@ -634,7 +754,7 @@ func printTermPositions(base, term, field string) int {
 
 // BOOLEAN OPERATIONS FOR POSTINGS LISTS
 
-func extendPositionalIDs(N []int32, np [][]int16, M []int32, mp [][]int16, delta int, proc func(pn, pm []int16, dlt int16) []int16) ([]int32, [][]int16) {
+func extendPositionalIDs(N []int64, np [][]int32, M []int64, mp [][]int32, delta int, proc func(pn, pm []int32, dlt int32) []int32) ([]int64, [][]int32) {
 
 	if proc == nil {
 		return nil, nil
@@ -660,8 +780,8 @@ func extendPositionalIDs(N []int32, np [][]int16, M []int32, mp [][]int16, delta
 		return N, np
 	}
 
-	res := make([]int32, sz)
-	ofs := make([][]int16, sz)
+	res := make([]int64, sz)
+	ofs := make([][]int32, sz)
 
 	if res == nil || len(res) < 1 || ofs == nil || len(ofs) < 1 {
 		return nil, nil
@@ -688,7 +808,7 @@ func extendPositionalIDs(N []int32, np [][]int16, M []int32, mp [][]int16, delta
 			em = M[j]
 		} else {
 			// specific callbacks test position arrays to match terms by adjacency or phrases by proximity
-			adj := proc(np[i], mp[j], int16(delta))
+			adj := proc(np[i], mp[j], int32(delta))
 			if adj != nil && len(adj) > 0 {
 				res[k] = en
 				ofs[k] = adj
@@ -711,7 +831,8 @@ func extendPositionalIDs(N []int32, np [][]int16, M []int32, mp [][]int16, delta
 	return res, ofs
 }
 
-func intersectIDs(N, M []int32) []int32 {
+// intersectIDs walks the smaller list, seeking in the larger one so packed blocks can be skipped without decoding
+func intersectIDs(N, M *PostingList) *PostingList {
 
 	if N == nil {
 		return M
@@ -720,63 +841,37 @@ func intersectIDs(N, M []int32) []int32 {
 		return N
 	}
 
-	n, m := len(N), len(M)
+	n, m := N.Len(), M.Len()
 
 	// swap to make M the smaller list
 	if n < m {
 		N, M = M, N
-		n, m = m, n
 	}
 
-	if m < 1 {
+	if M.Len() < 1 {
 		return N
 	}
 
-	res := make([]int32, m)
-
-	i, j, k := 0, 0, 0
+	res := make([]int64, 0, M.Len())
 
-	// use local variables for speed
-	en, em := N[i], M[j]
+	cn, cm := N.cursor(), M.cursor()
 
-	for {
-		// do inequality tests first
-		if en < em {
-			// index to larger list most likely to be advanced
-			i++
-			if i == n {
-				break
-			}
-			en = N[i]
-		} else if en > em {
-			j++
-			if j == m {
-				break
-			}
-			em = M[j]
-		} else {
-			// equality (intersection match) least likely
-			res[k] = en
-			k++
-			i++
-			j++
-			if i == n || j == m {
-				break
-			}
-			en = N[i]
-			em = M[j]
+	for cm.next() {
+		if !cn.seek(cm.val) {
+			break
+		}
+		// equality (intersection match) least likely
+		if cn.val == cm.val {
+			res = append(res, cm.val)
 		}
 	}
 
-	// truncate output array to actual size of intersection
-	res = res[:k]
-
-	return res
+	return newPostingList(res)
 }
 
 // if m * log(n) < m + n, binary search has fewer comparisons, but processor memory caches make linear algorithm faster
 /*
-func intersectBinary(N, M []int32) []int32 {
+func intersectBinary(N, M []int64) []int64 {
 
 	if N == nil {
 		return M
@@ -799,7 +894,7 @@ func intersectBinary(N, M []int32) []int32 {
 
 	k := 0
 
-	res := make([]int32, m)
+	res := make([]int64, m)
 
 	for _, uid := range M {
 		// inline binary search is faster than sort.Search
@@ -828,7 +923,7 @@ func intersectBinary(N, M []int32) []int32 {
 }
 */
 
-func combineIDs(N, M []int32) []int32 {
+func combineIDs(N, M *PostingList) *PostingList {
 
 	if N == nil {
 		return M
@@ -837,55 +932,45 @@ func combineIDs(N, M []int32) []int32 {
 		return N
 	}
 
-	n, m := len(N), len(M)
-
-	// swap to make M the smaller list
-	if n < m {
-		N, M = M, N
-		n, m = m, n
+	if N.Len() < 1 {
+		return M
 	}
-
-	if m < 1 {
+	if M.Len() < 1 {
 		return N
 	}
 
-	i, j, k := 0, 0, 0
+	res := make([]int64, 0, N.Len()+M.Len())
 
-	res := make([]int32, n+m)
+	cn, cm := N.cursor(), M.cursor()
+	hn, hm := cn.next(), cm.next()
 
-	for i < n && j < m {
-		if N[i] < M[j] {
-			res[k] = N[i]
-			k++
-			i++
-		} else if N[i] > M[j] {
-			res[k] = M[j]
-			k++
-			j++
+	for hn && hm {
+		if cn.val < cm.val {
+			res = append(res, cn.val)
+			hn = cn.next()
+		} else if cn.val > cm.val {
+			res = append(res, cm.val)
+			hm = cm.next()
 		} else {
-			res[k] = N[i]
-			k++
-			i++
-			j++
+			res = append(res, cn.val)
+			hn = cn.next()
+			hm = cm.next()
 		}
 	}
-	for i < n {
-		res[k] = N[i]
-		k++
-		i++
+	for hn {
+		res = append(res, cn.val)
+		hn = cn.next()
 	}
-	for j < m {
-		res[k] = M[j]
-		k++
-		j++
+	for hm {
+		res = append(res, cm.val)
+		hm = cm.next()
 	}
 
-	res = res[:k]
-
-	return res
+	return newPostingList(res)
 }
 
-func excludeIDs(N, M []int32) []int32 {
+// excludeIDs seeks in the excluded list for each candidate UID
+func excludeIDs(N, M *PostingList) *PostingList {
 
 	if N == nil {
 		return nil
@@ -894,53 +979,24 @@ func excludeIDs(N, M []int32) []int32 {
 		return N
 	}
 
-	n, m := len(N), len(M)
-
-	if m < 1 {
+	if M.Len() < 1 {
 		return N
 	}
 
-	res := make([]int32, n)
+	res := make([]int64, 0, N.Len())
 
-	i, j, k := 0, 0, 0
-
-	// use local variables for speed
-	en, em := N[i], M[j]
+	cn, cm := N.cursor(), M.cursor()
 
-	for {
-		// do inequality tests first
-		if en < em {
-			// item is not excluded
-			res[k] = en
-			k++
-			i++
-			if i == n {
-				break
-			}
-			en = N[i]
-		} else if en > em {
-			// advance second list pointer
-			j++
-			if j == m {
-				break
-			}
-			em = M[j]
-		} else {
+	for cn.next() {
+		if cm.seek(cn.val) && cm.val == cn.val {
 			// exclude
-			i++
-			j++
-			if i == n || j == m {
-				break
-			}
-			en = N[i]
-			em = M[j]
+			continue
 		}
+		// item is not excluded
+		res = append(res, cn.val)
 	}
 
-	// truncate output array to actual size of result
-	res = res[:k]
-
-	return res
+	return newPostingList(res)
 }
 
 // QUERY EVALUATION FUNCTION
@@ -969,25 +1025,22 @@ func postingIDsFuture(base, term, field string, dist int) <-chan Arrays {
 	return out
 }
 
-func evaluateQuery(base string, clauses []string) int {
+// resolveQuery evaluates the query tree, returning the matching UIDs and the number of terms fetched,
+// optionally saving the word positions of each phrase on positional fields
+func resolveQuery(base string, root *queryNode, explain, keepPos bool) (*PostingList, int) {
 
-	if clauses == nil || clauses[0] == "" {
-		return 0
+	if root == nil {
+		return nil, 0
 	}
 
-	count := 0
+	// field names, aliases, and tokenizer policies
+	schema := ReadIndexSchema(base)
 
-	// flag set if no tildes, indicates no proximity tests in query
-	noProx := true
-	for _, tkn := range clauses {
-		if strings.HasPrefix(tkn, "~") {
-			noProx = false
-		}
-	}
+	count := 0
 
-	phrasePositions := func(pn, pm []int16, dlt int16) []int16 {
+	phrasePositions := func(pn, pm []int32, dlt int32) []int32 {
 
-		var arry []int16
+		var arry []int32
 
 		ln, lm := len(pn), len(pm)
 
@@ -1027,9 +1080,9 @@ func evaluateQuery(base string, clauses []string) int {
 		return arry
 	}
 
-	proximityPositions := func(pn, pm []int16, dlt int16) []int16 {
+	proximityPositions := func(pn, pm []int32, dlt int32) []int32 {
 
-		var arry []int16
+		var arry []int32
 
 		ln, lm := len(pn), len(pm)
 
@@ -1069,10 +1122,10 @@ func evaluateQuery(base string, clauses []string) int {
 		return arry
 	}
 
-	eval := func(str string) ([]int32, [][]int16, int) {
+	eval := func(str string, needPos bool) (*PostingList, [][]int32, int) {
 
 		// extract optional [FIELD] qualifier
-		field := "TIAB"
+		field := ""
 
 		if strings.HasSuffix(str, "]") {
 			pos := strings.Index(str, "[")
@@ -1083,49 +1136,58 @@ func evaluateQuery(base string, clauses []string) int {
 				str = str[:pos]
 				str = strings.TrimSpace(str)
 			}
-			switch field {
-			case "NORM":
-				field = "TIAB"
-			case "STEM", "TIAB", "TITL":
-			case "PIPE":
+			name, fld := schema.resolveField(field)
+			field = name
+			switch {
+			case fld != nil && fld.Tokenizer == "words":
+			case field == "PIPE":
 				// esearch -db pubmed -query "complement system proteins [MESH]" -pub clinical |
 				// efetch -format uid | phrase-search -query "[PIPE] AND L [THME]"
-				var data []int32
+				var data []int64
+				hdr := ReadPostingsHeader(base)
 				// read UIDs from stdin
 				uidq := CreateUIDReader(os.Stdin)
 				for ext := range uidq {
 
-					val, err := strconv.Atoi(ext.Text)
-					if err != nil {
+					val, ok := parsePostingUID(base, hdr, ext.Text, false)
+					if !ok && hdr.IDType == "string" {
+						// identifier not present in any postings list
+						continue
+					}
+					if !ok {
 						fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized UID %s\n", ext.Text)
 						os.Exit(1)
 					}
 
-					data = append(data, int32(val))
+					data = append(data, val)
 				}
 				// sort UIDs before returning
 				sort.Slice(data, func(i, j int) bool { return data[i] < data[j] })
-				return data, nil, 0
+				return newPostingList(data), nil, 0
 			default:
 				str = strings.Replace(str, " ", "_", -1)
 			}
 		}
 
+		// unqualified terms search the default field
+		field, _ = schema.resolveField(field)
+
 		words := strings.Fields(str)
 
 		if words == nil || len(words) < 1 {
 			return nil, nil, 0
 		}
 
-		// if no tilde proximity tests, and not building up phrase from multiple words,
+		// if not part of a proximity test, and not building up phrase from multiple words,
 		// no need to use more expensive position tests when calculating intersection
-		if noProx && len(words) == 1 {
+		if !needPos && len(words) == 1 {
 			term := words[0]
 			if strings.HasPrefix(term, "+") {
 				return nil, nil, 0
 			}
 			term = strings.Replace(term, "_", " ", -1)
-			data, _ := getPostingIDs(base, term, field, true)
+			// keep postings in packed form for direct set operations
+			data, _ := getPostingList(base, term, field, true)
 			count++
 			return data, nil, 1
 		}
@@ -1180,7 +1242,7 @@ func evaluateQuery(base string, clauses []string) int {
 		data, ofst, dist := intersect[0].Data, intersect[0].Ofst, intersect[0].Dist+1
 
 		if len(intersect) == 1 {
-			return data, ofst, dist
+			return newPostingList(data), ofst, dist
 		}
 
 		for i := 1; i < len(intersect); i++ {
@@ -1197,168 +1259,179 @@ func evaluateQuery(base string, clauses []string) int {
 		count += len(intersect)
 
 		// return UIDs and all positions of current phrase
-		return data, ofst, dist
+		return newPostingList(data), ofst, dist
 	}
 
-	prevTkn := ""
-
-	nextToken := func() string {
-
-		if len(clauses) < 1 {
-			return ""
-		}
-
-		// remove next token from slice
-		tkn := clauses[0]
-		clauses = clauses[1:]
-
-		if tkn == "(" && prevTkn != "" && prevTkn != "&" && prevTkn != "|" && prevTkn != "!" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Tokens '%s' and '%s' should be separated by AND, OR, or NOT\n", prevTkn, tkn)
-			os.Exit(1)
-		}
+	// isPositional checks whether the field of a clause records word positions
+	isPositional := func(str string) bool {
 
-		if prevTkn == ")" && tkn != "" && tkn != "&" && tkn != "|" && tkn != "!" && tkn != ")" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Tokens '%s' and '%s' should be separated by AND, OR, or NOT\n", prevTkn, tkn)
-			os.Exit(1)
+		field := ""
+		if strings.HasSuffix(str, "]") {
+			pos := strings.Index(str, "[")
+			if pos >= 0 {
+				field = strings.TrimSuffix(str[pos+1:], "]")
+			}
 		}
 
-		prevTkn = tkn
+		_, fld := schema.resolveField(field)
 
-		return tkn
+		return fld != nil && fld.Tokenizer == "words" && fld.Positions
 	}
 
-	// recursive definitions
-	var fact func() ([]int32, [][]int16, int, string)
-	var prox func() ([]int32, string)
-	var excl func() ([]int32, string)
-	var term func() ([]int32, string)
-	var expr func() ([]int32, string)
-
-	fact = func() ([]int32, [][]int16, int, string) {
-
-		var (
-			data  []int32
-			ofst  [][]int16
-			delta int
-			tkn   string
-		)
+	// expand records terms matched by each wildcard or ~fuzzy word in a phrase, for -explain
+	expand := func(node *queryNode) {
 
-		tkn = nextToken()
+		str := node.Clause
+		field := schema.Default
 
-		if tkn == "(" {
-			// recursively process expression in parentheses
-			data, tkn = expr()
-			if tkn == ")" {
-				tkn = nextToken()
-			} else {
-				fmt.Fprintf(os.Stderr, "\nERROR: Expected ')' but received '%s'\n", tkn)
-				os.Exit(1)
+		if strings.HasSuffix(str, "]") {
+			pos := strings.Index(str, "[")
+			if pos >= 0 {
+				field = strings.TrimSuffix(str[pos+1:], "]")
+				str = strings.TrimSpace(str[:pos])
 			}
-		} else if tkn == ")" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Unexpected ')' token\n")
-			os.Exit(1)
-		} else if tkn == "&" || tkn == "|" || tkn == "!" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Unexpected operator '%s' in expression\n", tkn)
-			os.Exit(1)
-		} else if tkn == "" {
-			fmt.Fprintf(os.Stderr, "\nERROR: Unexpected end of expression\n")
-			os.Exit(1)
-		} else {
-			// evaluate current phrase
-			data, ofst, delta = eval(tkn)
-			tkn = nextToken()
 		}
 
-		return data, ofst, delta, tkn
-	}
-
-	prox = func() ([]int32, string) {
-
-		var (
-			next []int32
-			noff [][]int16
-			ndlt int
-		)
-
-		data, ofst, delta, tkn := fact()
-		if len(data) < 1 {
-			return nil, ""
+		words := strings.Fields(str)
+		if _, fld := schema.resolveField(field); fld != nil && fld.Tokenizer != "words" {
+			// multi-word terms are searched as a unit
+			words = []string{strings.Join(words, "_")}
 		}
 
-		for strings.HasPrefix(tkn, "~") {
-			dist := strings.Count(tkn, "~")
-			next, noff, ndlt, tkn = fact()
-			if len(next) < 1 {
-				return nil, ""
+		for _, word := range words {
+			if txt, edits, ok := parseFuzzyTerm(word); ok {
+				strs, sizes := similarTerms(base, txt, field, edits)
+				if len(strs) > fuzzyLimit {
+					strs = strs[:fuzzyLimit]
+				}
+				for i, txt := range strs {
+					node.Expanded = append(node.Expanded, strconv.Itoa(sizes[i])+"\t"+txt)
+				}
+				continue
 			}
-			// next phrase must be within specified distance after the previous phrase
-			data, ofst = extendPositionalIDs(data, ofst, next, noff, delta+dist, proximityPositions)
-			if len(data) < 1 {
-				return nil, ""
+			if needsDictionary(word) {
+				strs, sizes := expandTermCounts(base, word, field)
+				for i, txt := range strs {
+					node.Expanded = append(node.Expanded, strconv.Itoa(sizes[i])+"\t"+txt)
+				}
+				continue
+			}
+			if !strings.HasSuffix(word, "*") && !strings.HasSuffix(word, "$") {
+				continue
+			}
+			term, isWildCard, ok := normalizePostingTerm(word)
+			if !ok || !isWildCard {
+				continue
+			}
+			strs, sizes := expandTermCounts(base, term+"*", field)
+			for i, txt := range strs {
+				node.Expanded = append(node.Expanded, strconv.Itoa(sizes[i])+"\t"+txt)
 			}
-			delta = ndlt
 		}
-
-		return data, tkn
 	}
 
-	excl = func() ([]int32, string) {
-
-		var next []int32
-
-		data, tkn := prox()
-		for tkn == "!" {
-			next, tkn = prox()
-			data = excludeIDs(data, next)
-		}
-
-		return data, tkn
-	}
+	// evalNode recursively evaluates the query tree, recording the document count at each node
+	var evalNode func(node *queryNode, needPos bool) (*PostingList, [][]int32, int)
 
-	term = func() ([]int32, string) {
+	evalNode = func(node *queryNode, needPos bool) (*PostingList, [][]int32, int) {
 
-		var next []int32
+		var (
+			data  *PostingList
+			ofst  [][]int32
+			delta int
+		)
 
-		data, tkn := excl()
-		for tkn == "&" {
-			next, tkn = excl()
-			data = intersectIDs(data, next)
+		switch node.Kind {
+		case qryPhrase:
+			if node.Clause != "" && keepPos && isPositional(node.Clause) {
+				data, ofst, delta = eval(node.Clause, true)
+				node.hits, node.psns, node.span = data.IDs(), ofst, delta
+			} else if node.Clause != "" {
+				data, ofst, delta = eval(node.Clause, needPos)
+			}
+			if explain {
+				expand(node)
+			}
+		case qryHistory:
+			data = readHistorySet(base, node.Text)
+		case qryRange, qryOrNode:
+			for _, chld := range node.Children {
+				next, _, _ := evalNode(chld, false)
+				data = combineIDs(data, next)
+			}
+		case qryAndNode:
+			for i, chld := range node.Children {
+				if i > 0 && data.Len() < 1 && !explain {
+					break
+				}
+				next, _, _ := evalNode(chld, false)
+				switch {
+				case i == 0:
+					data = next
+				case data.Len() < 1 || next.Len() < 1:
+					// empty operand empties the intersection, but keep evaluating to report counts
+					data = nil
+				default:
+					data = intersectIDs(data, next)
+				}
+			}
+		case qryNotNode:
+			for i, chld := range node.Children {
+				if i > 0 && data.Len() < 1 && !explain {
+					break
+				}
+				next, _, _ := evalNode(chld, false)
+				if i == 0 {
+					data = next
+				} else {
+					data = excludeIDs(data, next)
+				}
+			}
+		case qryProxNode:
+			data, ofst, delta = evalNode(node.Children[0], true)
+			for i, chld := range node.Children[1:] {
+				next, noff, ndlt := evalNode(chld, true)
+				if data.Len() < 1 || next.Len() < 1 {
+					data, ofst = nil, nil
+					if !explain {
+						break
+					}
+					continue
+				}
+				// next phrase must be within specified distance after the previous phrase
+				var ids []int64
+				ids, ofst = extendPositionalIDs(data.IDs(), ofst, next.IDs(), noff, delta+node.Dist[i], proximityPositions)
+				data = newPostingList(ids)
+				delta = ndlt
+			}
+			if keepPos {
+				// phrase positions were saved before the proximity test, keep only those within range
+				trimProximityHits(node, data.IDs())
+			}
 		}
 
-		return data, tkn
-	}
-
-	expr = func() ([]int32, string) {
-
-		var next []int32
+		node.Count = data.Len()
 
-		data, tkn := term()
-		for tkn == "|" {
-			next, tkn = term()
-			data = combineIDs(data, next)
-		}
-
-		return data, tkn
+		return data, ofst, delta
 	}
 
-	// enter recursive descent parser
-	result, tkn := expr()
+	result, _, _ := evalNode(root, false)
 
-	if tkn != "" {
-		fmt.Fprintf(os.Stderr, "\nERROR: Unexpected token '%s' at end of expression\n", tkn)
-		os.Exit(1)
-	}
+	return result, count
+}
+
+// printQueryResult prints the UIDs of a search result, one per line
+func printQueryResult(base string, result *PostingList) {
 
-	// sort final result
-	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	// set operations preserve ascending order, so final result is already sorted
 
 	// use buffers to speed up uid printing
 	var buffer strings.Builder
 
 	wrtr := bufio.NewWriter(os.Stdout)
 
-	for _, pmid := range result {
-		val := strconv.Itoa(int(pmid))
+	// string identifiers are mapped back from ordinals and printed in lexical order
+	for _, val := range formatPostingUIDs(base, ReadPostingsHeader(base), result.IDs()) {
 		buffer.WriteString(val[:])
 		buffer.WriteString("\n")
 	}
@@ -1372,8 +1445,6 @@ func evaluateQuery(base string, clauses []string) int {
 	wrtr.Flush()
 
 	runtime.Gosched()
-
-	return count
 }
 
 // QUERY PARSING FUNCTIONS
@@ -1600,7 +1671,7 @@ func prepareExact(str, sfx string, deStop bool) string {
 	return tmp
 }
 
-func processStopWords(str string, deStop bool) string {
+func processStopWords(str string, deStop bool, schema *IndexSchema) string {
 
 	if str == "" {
 		return ""
@@ -1629,20 +1700,17 @@ func processStopWords(str string, deStop bool) string {
 
 		fld, j := nextField(terms)
 
-		// with addition of TITL field, switch from TIAB to NORM
-		if fld == "[NORM]" {
-			fld = "[TIAB]"
+		// resolve aliases, e.g., NORM for TIAB, and get tokenizer policy from index schema
+		name, field := schema.resolveField(strings.Trim(fld, "[]"))
+		if fld != "" {
+			fld = "[" + name + "]"
 		}
 
 		stps := false
 		rlxd := false
-		if fld == "[TITL]" || fld == "[TIAB]" {
-			stps = true
-		} else if fld == "[STEM]" {
-			stps = true
-			rlxd = true
-		} else if fld == "" {
+		if field != nil && field.Tokenizer == "words" {
 			stps = true
+			rlxd = field.Stem
 		}
 
 		addOneTerm := func(itm string) {
@@ -1651,7 +1719,7 @@ func processStopWords(str string, deStop bool) string {
 				if IsAllDigitsOrPeriod(itm) {
 					// skip terms that are all digits
 					chain = append(chain, "+")
-				} else if deStop && IsStopWord(itm) {
+				} else if deStop && field.Stop && IsStopWord(itm) {
 					// skip if stop word, breaking phrase chain
 					chain = append(chain, "+")
 				} else if rlxd {
@@ -1773,7 +1841,7 @@ func partitionQuery(str string) []string {
 	return tmp
 }
 
-func setFieldQualifiers(clauses []string, rlxd bool) []string {
+func setFieldQualifiers(clauses []string, rlxd bool, schema *IndexSchema) []string {
 
 	var res []string
 
@@ -1795,14 +1863,23 @@ func setFieldQualifiers(clauses []string, rlxd bool) []string {
 			continue
 		}
 
-		if strings.HasSuffix(str, " [YEAR]") {
+		// year fields support ranges
+		year := ""
+		if strings.HasSuffix(str, "]") {
+			pos := strings.LastIndex(str, " [")
+			if pos >= 0 {
+				if fld := schema.Field(str[pos+2 : len(str)-1]); fld != nil && fld.Tokenizer == "year" {
+					year = fld.Name
+					str = str[:pos]
+				}
+			}
+		}
 
-			slen := len(str)
-			str = str[:slen-7]
+		if year != "" {
 
 			// regular 4-digit year
 			if len(str) == 4 && IsAllDigitsOrPeriod(str) {
-				res = append(res, str+" [YEAR]")
+				res = append(res, str+" ["+year+"]")
 				continue
 			}
 
@@ -1835,7 +1912,7 @@ func setFieldQualifiers(clauses []string, rlxd bool) []string {
 					res = append(res, pfx)
 					pfx = "|"
 					yr := strconv.Itoa(start)
-					res = append(res, yr+" [year]")
+					res = append(res, yr+" ["+year+"]")
 					start++
 				}
 				res = append(res, sfx)
@@ -1896,65 +1973,141 @@ func ProcessSearch(base, phrase string, xact, titl, rlxd, deStop bool) int {
 		return 0
 	}
 
-	if titl {
-		phrase = prepareExact(phrase, "[titl]", deStop)
-	} else if xact {
-		phrase = prepareExact(phrase, "[tiab]", deStop)
-	} else {
-		phrase = prepareQuery(phrase)
+	// field names, aliases, and tokenizer policies
+	schema := ReadIndexSchema(base)
+
+	root := buildQueryTree(phrase, xact, titl, rlxd, deStop, schema)
+
+	if root == nil {
+		return 0
 	}
 
-	phrase = processStopWords(phrase, deStop)
+	result, count := resolveQuery(base, root, false, false)
 
-	clauses := partitionQuery(phrase)
+	// save result for later reference as "#n"
+	saveQueryHistory(base, phrase, result)
 
-	clauses = setFieldQualifiers(clauses, rlxd)
+	printQueryResult(base, result)
 
-	return evaluateQuery(base, clauses)
+	return count
+}
+
+// ProcessExplain prints the normalized query, document counts for each node of the query tree,
+// and the terms matched by each wildcard
+func ProcessExplain(base, phrase string, xact, titl, rlxd, deStop bool) int {
+
+	if phrase == "" {
+		return 0
+	}
+
+	// field names, aliases, and tokenizer policies
+	schema := ReadIndexSchema(base)
+
+	root := buildQueryTree(phrase, xact, titl, rlxd, deStop, schema)
+
+	fmt.Fprintf(os.Stdout, "Query\n\n  %s\n\n", phrase)
+
+	if root == nil {
+		return 0
+	}
+
+	_, count := resolveQuery(base, root, true, false)
+
+	fmt.Fprintf(os.Stdout, "Normalized Query\n\n  %s\n\n", formatQueryTree(root, schema, false))
+	fmt.Fprintf(os.Stdout, "Evaluation\n\n")
+	printQueryTree(root, schema, "  ")
+	fmt.Fprintf(os.Stdout, "\n")
+
+	return count
 }
 
-// ProcessMock shows individual steps in processing query for evaluation
+// ProcessMock shows individual steps in processing query for evaluation, applied to each
+// phrase of the parsed query tree as in buildQueryTree
 func ProcessMock(base, phrase string, xact, titl, rlxd, deStop bool) int {
 
 	if phrase == "" {
 		return 0
 	}
 
+	// field names, aliases, and tokenizer policies
+	schema := ReadIndexSchema(base)
+
 	fmt.Fprintf(os.Stdout, "processSearch:\n\n%s\n\n", phrase)
 
-	if titl {
-		phrase = prepareExact(phrase, "[titl]", deStop)
+	printClauses := func(label string, clauses []string) {
+		fmt.Fprintf(os.Stdout, "%s:\n\n", label)
+		for _, tkn := range clauses {
+			fmt.Fprintf(os.Stdout, "%s\n", tkn)
+		}
+		fmt.Fprintf(os.Stdout, "\n")
+	}
 
-		fmt.Fprintf(os.Stdout, "prepareExact:\n\n%s\n\n", phrase)
-	} else if xact {
-		phrase = prepareExact(phrase, "[tiab]", deStop)
+	// normalization of one phrase, or of the whole string for exact and title searches
+	mockPhrase := func(str string) {
 
-		fmt.Fprintf(os.Stdout, "prepareExact:\n\n%s\n\n", phrase)
-	} else {
-		phrase = prepareQuery(phrase)
+		if xact || titl {
+			sfx := "[tiab]"
+			if titl {
+				sfx = "[titl]"
+			}
+			str = prepareExact(str, sfx, deStop)
 
-		fmt.Fprintf(os.Stdout, "prepareQuery:\n\n%s\n\n", phrase)
-	}
+			fmt.Fprintf(os.Stdout, "prepareExact:\n\n%s\n\n", str)
+		} else {
+			str = prepareQuery(str)
+
+			fmt.Fprintf(os.Stdout, "prepareQuery:\n\n%s\n\n", str)
+		}
 
-	phrase = processStopWords(phrase, deStop)
+		str = processStopWords(str, deStop, schema)
 
-	fmt.Fprintf(os.Stdout, "processStopWords:\n\n%s\n\n", phrase)
+		fmt.Fprintf(os.Stdout, "processStopWords:\n\n%s\n\n", str)
 
-	clauses := partitionQuery(phrase)
+		clauses := partitionQuery(str)
+
+		printClauses("partitionQuery", clauses)
 
-	fmt.Fprintf(os.Stdout, "partitionQuery:\n\n")
-	for _, tkn := range clauses {
-		fmt.Fprintf(os.Stdout, "%s\n", tkn)
+		clauses = setFieldQualifiers(clauses, rlxd, schema)
+
+		printClauses("setFieldQualifiers", clauses)
 	}
-	fmt.Fprintf(os.Stdout, "\n")
 
-	clauses = setFieldQualifiers(clauses, rlxd)
+	if xact || titl {
+		// exact and title searches are a single phrase
+		mockPhrase(phrase)
+	} else {
+		root, qerr := parseQuery(strings.TrimSpace(phrase))
+		if qerr != nil {
+			qerr.report()
+			os.Exit(1)
+		}
+
+		var visit func(node *queryNode)
 
-	fmt.Fprintf(os.Stdout, "setFieldQualifiers:\n\n")
-	for _, tkn := range clauses {
-		fmt.Fprintf(os.Stdout, "%s\n", tkn)
+		visit = func(node *queryNode) {
+			if node == nil {
+				return
+			}
+			if node.Kind == qryPhrase {
+				fmt.Fprintf(os.Stdout, "parseQuery:\n\n%s\n\n", node.Text)
+				if node.Field == "EXPN" {
+					// thesaurus expansion is shown in the normalized query
+					return
+				}
+				mockPhrase(node.Text)
+				return
+			}
+			for _, chld := range node.Children {
+				visit(chld)
+			}
+		}
+
+		visit(root)
 	}
-	fmt.Fprintf(os.Stdout, "\n")
+
+	root := buildQueryTree(phrase, xact, titl, rlxd, deStop, schema)
+
+	fmt.Fprintf(os.Stdout, "buildQueryTree:\n\n%s\n\n", formatQueryTree(root, schema, false))
 
 	return 0
 }
@@ -1966,13 +2119,16 @@ func ProcessCount(base, phrase string, plrl, psns, rlxd, deStop bool) int {
 		return 0
 	}
 
+	// field names, aliases, and tokenizer policies
+	schema := ReadIndexSchema(base)
+
 	phrase = prepareQuery(phrase)
 
-	phrase = processStopWords(phrase, deStop)
+	phrase = processStopWords(phrase, deStop, schema)
 
 	clauses := partitionQuery(phrase)
 
-	clauses = setFieldQualifiers(clauses, rlxd)
+	clauses = setFieldQualifiers(clauses, rlxd, schema)
 
 	if clauses == nil {
 		return 0
@@ -2013,7 +2169,7 @@ func ProcessCount(base, phrase string, plrl, psns, rlxd, deStop bool) int {
 
 	parseField := func(str string) (string, string) {
 
-		field := "TIAB"
+		field := ""
 
 		if strings.HasSuffix(str, "]") {
 			pos := strings.Index(str, "[")
@@ -2024,16 +2180,18 @@ func ProcessCount(base, phrase string, plrl, psns, rlxd, deStop bool) int {
 				str = str[:pos]
 				str = strings.TrimSpace(str)
 			}
-			switch field {
-			case "NORM":
-				field = "TIAB"
-			case "STEM", "TIAB", "TITL":
-			case "PIPE":
+			name, fld := schema.resolveField(field)
+			field = name
+			switch {
+			case fld != nil && fld.Tokenizer == "words":
+			case field == "PIPE":
 			default:
 				str = strings.Replace(str, " ", "_", -1)
 			}
 		}
 
+		field, _ = schema.resolveField(field)
+
 		return field, str
 	}
 
@@ -40,6 +40,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -226,6 +227,10 @@ func PostingPath(prom, field, term string, arry [516]rune) (string, string) {
 // is the total number of live PubMed documents, which could easily be saved
 // during indexing.
 //
+// A postings.hdr file in the promoted directory selects 64-bit or string
+// identifiers, saved as 64-bit values or dictionary ordinals, and 32-bit
+// word positions, for corpora that exceed the original limits.
+//
 func CreatePromoters(prom, fields string, files []string) <-chan string {
 
 	if files == nil {
@@ -240,6 +245,9 @@ func CreatePromoters(prom, fields string, files []string) <-chan string {
 
 	flds := strings.Split(fields, " ")
 
+	// identifier and position widths are selected per index by an optional header file
+	hdr := ReadPostingsHeader(prom)
+
 	// xmlPromoter saves records in a single set of term/posting files
 	xmlPromoter := func(wg *sync.WaitGroup, fileName string, out chan<- string) {
 
@@ -290,9 +298,9 @@ func CreatePromoters(prom, fields string, files []string) <-chan string {
 			os.Exit(1)
 		}
 
-		getOnePosting := func(field, text string) (string, []int32, []string) {
+		getOnePosting := func(field, text string) (string, []int64, []string) {
 
-			var data []int32
+			var data []int64
 			var atts []string
 
 			term := ""
@@ -308,17 +316,17 @@ func CreatePromoters(prom, fields string, files []string) <-chan string {
 
 				} else if tag == field {
 
-					// convert UID string to integer
+					// convert UID string to integer, or to ordinal for string identifiers
 					if content == "" {
 						fmt.Fprintf(os.Stderr, "\nERROR: Empty UID for term '%s'\n", term)
 						return
 					}
-					value, err := strconv.ParseInt(content, 10, 32)
-					if err != nil {
-						fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+					value, ok := parsePostingUID(prom, hdr, content, true)
+					if !ok {
+						fmt.Fprintf(os.Stderr, "\nERROR: UID '%s' is not a valid %s identifier\n", content, hdr.IDType)
 						return
 					}
-					data = append(data, int32(value))
+					data = append(data, value)
 
 					if strings.HasPrefix(attr, "pos=\"") {
 						attr = attr[5:]
@@ -339,6 +347,24 @@ func CreatePromoters(prom, fields string, files []string) <-chan string {
 				return "", nil, nil
 			}
 
+			// ordinals of string identifiers are in order of first appearance, not lexical order
+			if hdr.IDType == "string" && len(atts) == len(data) {
+				idx := make([]int, len(data))
+				for i := range idx {
+					idx[i] = i
+				}
+				sort.Slice(idx, func(i, j int) bool { return data[idx[i]] < data[idx[j]] })
+				srtd := make([]int64, len(data))
+				stts := make([]string, len(atts))
+				for i, k := range idx {
+					srtd[i] = data[k]
+					stts[i] = atts[k]
+				}
+				data, atts = srtd, stts
+			} else if hdr.IDType == "string" {
+				sort.Slice(data, func(i, j int) bool { return data[i] < data[j] })
+			}
+
 			return term, data, atts
 		}
 
@@ -356,7 +382,10 @@ func CreatePromoters(prom, fields string, files []string) <-chan string {
 
 		retlength := len("\n")
 
-		addOnePosting := func(term string, data []int32, atts []string) {
+		idSize := int32(hdr.idSize())
+		posSize := int32(hdr.posSize())
+
+		addOnePosting := func(term string, data []int64, atts []string) {
 
 			tlength := len(term)
 			dlength := len(data)
@@ -366,14 +395,22 @@ func CreatePromoters(prom, fields string, files []string) <-chan string {
 			termList.WriteString(term[:])
 			termList.WriteString("\n")
 
-			// write to postings buffer
-			binary.Write(&postList, binary.LittleEndian, data)
+			// write to postings buffer, with 32-bit UIDs for original format
+			if idSize == 4 {
+				narrow := make([]int32, dlength)
+				for i, uid := range data {
+					narrow[i] = int32(uid)
+				}
+				binary.Write(&postList, binary.LittleEndian, narrow)
+			} else {
+				binary.Write(&postList, binary.LittleEndian, data)
+			}
 
 			// write to master index buffer
 			binary.Write(&indxList, binary.LittleEndian, termPos)
 			binary.Write(&indxList, binary.LittleEndian, postPos)
 
-			postPos += int32(dlength * 4)
+			postPos += int32(dlength) * idSize
 			termPos += int32(tlength + retlength)
 
 			// return if no position attributes
@@ -401,10 +438,14 @@ func CreatePromoters(prom, fields string, files []string) <-chan string {
 						fmt.Fprintf(os.Stderr, "%s\n", err.Error())
 						return
 					}
-					binary.Write(&ofstList, binary.LittleEndian, int16(value))
+					if posSize == 2 {
+						binary.Write(&ofstList, binary.LittleEndian, int16(value))
+					} else {
+						binary.Write(&ofstList, binary.LittleEndian, int32(value))
+					}
 				}
 
-				ofstPos += int32(atln * 2)
+				ofstPos += int32(atln) * posSize
 			}
 		}
 
@@ -461,6 +502,11 @@ func CreatePromoters(prom, fields string, files []string) <-chan string {
 				return
 			}
 
+			// packed postings from an earlier -repack would otherwise shadow the new files
+			if err := os.Remove(path.Join(dpath, key+"."+field+".cps")); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+			}
+
 			writeFile(dpath, key+"."+field+".trm", termList)
 
 			writeFile(dpath, key+"."+field+".pst", postList)
@@ -576,6 +622,10 @@ func CreatePromoters(prom, fields string, files []string) <-chan string {
 	// launch separate anonymous goroutine to wait until all promoters are done
 	go func() {
 		wg.Wait()
+		// record newly assigned string identifier ordinals
+		if hdr.IDType == "string" {
+			getUIDDictionary(prom).save()
+		}
 		close(out)
 	}()
 
@@ -0,0 +1,1017 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  postings.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Packed postings (.cps files) replace the .mst, .pst, .uqi, and .ofs files for a given
+// term list. The file starts with the "EDPZ" magic number and a format version, followed
+// by the number of terms, a flag word, and a table of 64-bit offsets, one pair per term
+// plus a phantom entry, pointing to the UID and position sections of each term.
+//
+// UID sections start with an encoding byte and the document frequency. Most terms use
+// blocks of 128 delta-encoded varints, preceded by a skip table with the last UID and byte
+// length of each block, so that intersections can jump over blocks without decoding them.
+// Very frequent terms use roaring bitmap containers, keyed by the upper 16 bits of the UID,
+// holding either a sorted array of lower 16 bits or a 65536-bit bitmap.
+//
+// Position sections hold, for each UID, the number of word positions followed by the
+// delta-encoded positions.
+//
+// The .trm term list is shared with the original format. When a .cps file is present it
+// takes precedence, so converted and unconverted postings directories can coexist. Promoting
+// a field again removes its .cps files, and a .cps file older than its .mst file is ignored.
+
+const (
+	packedMagic     = "EDPZ"
+	packedVersion   = 2
+	packedBlockSize = 128
+	// terms with at least this many UIDs are considered for roaring bitmap encoding
+	packedBitmapMin = 4096
+	// array containers hold at most this many values before switching to a bitmap
+	packedArrayMax = 4096
+)
+
+// posting list encodings
+const (
+	plainPostings = iota
+	blockPostings
+	bitmapPostings
+)
+
+// PostingList is a sorted UID list held as a plain array, as delta-encoded blocks with
+// skip pointers, or as roaring bitmap containers
+type PostingList struct {
+	kind  int
+	count int
+
+	// plain array
+	ids []int64
+
+	// block-packed representation, starts has a phantom entry at the end
+	lasts  []int64
+	starts []int
+	packed []byte
+
+	// roaring representation, each container is either an array or a bitmap
+	keys    []uint16
+	arrays  [][]uint16
+	bitmaps [][]uint64
+}
+
+// newPostingList wraps a sorted UID array, preserving nil for absent terms
+func newPostingList(ids []int64) *PostingList {
+
+	if ids == nil {
+		return nil
+	}
+
+	return &PostingList{kind: plainPostings, count: len(ids), ids: ids}
+}
+
+// Len returns the number of UIDs
+func (pl *PostingList) Len() int {
+
+	if pl == nil {
+		return 0
+	}
+
+	return pl.count
+}
+
+// IDs decodes the list to a plain array
+func (pl *PostingList) IDs() []int64 {
+
+	if pl == nil {
+		return nil
+	}
+
+	if pl.kind == plainPostings {
+		return pl.ids
+	}
+
+	res := make([]int64, 0, pl.count)
+
+	cr := pl.cursor()
+	for cr.next() {
+		res = append(res, cr.val)
+	}
+
+	return res
+}
+
+// decodeBlock appends the UIDs of one packed block to buf
+func (pl *PostingList) decodeBlock(blk int, buf []int64) []int64 {
+
+	prev := int64(0)
+	if blk > 0 {
+		prev = pl.lasts[blk-1]
+	}
+
+	data := pl.packed[pl.starts[blk]:pl.starts[blk+1]]
+
+	for len(data) > 0 {
+		delta, n := binary.Uvarint(data)
+		if n <= 0 {
+			break
+		}
+		data = data[n:]
+		prev += int64(delta)
+		buf = append(buf, prev)
+	}
+
+	return buf
+}
+
+// nextSetBit returns the position of the first set bit at or after from, or -1
+func nextSetBit(bmp []uint64, from int) int {
+
+	if from < 0 {
+		from = 0
+	}
+
+	wd := from >> 6
+	if wd >= len(bmp) {
+		return -1
+	}
+
+	word := bmp[wd] >> uint(from&63)
+	if word != 0 {
+		return from + bits.TrailingZeros64(word)
+	}
+
+	for wd++; wd < len(bmp); wd++ {
+		if bmp[wd] != 0 {
+			return wd<<6 + bits.TrailingZeros64(bmp[wd])
+		}
+	}
+
+	return -1
+}
+
+// postingCursor walks a posting list in ascending order, decoding blocks only as needed
+type postingCursor struct {
+	list    *PostingList
+	blk     int
+	idx     int
+	buf     []int64
+	val     int64
+	started bool
+	done    bool
+}
+
+func (pl *PostingList) cursor() *postingCursor {
+
+	cr := &postingCursor{list: pl, blk: -1, idx: -1}
+
+	if pl == nil || pl.count < 1 {
+		cr.done = true
+	}
+	if pl != nil && pl.kind == bitmapPostings {
+		cr.blk = 0
+	}
+
+	return cr
+}
+
+// scanContainers positions the cursor at the first roaring value at or after from in the current container,
+// moving on to later containers if necessary
+func (cr *postingCursor) scanContainers(from int) bool {
+
+	pl := cr.list
+
+	for cr.blk < len(pl.keys) {
+		high := int64(pl.keys[cr.blk]) << 16
+		if arr := pl.arrays[cr.blk]; arr != nil {
+			if from < len(arr) {
+				cr.idx = from
+				cr.val = high | int64(arr[from])
+				cr.started = true
+				return true
+			}
+		} else if bit := nextSetBit(pl.bitmaps[cr.blk], from); bit >= 0 {
+			cr.idx = bit
+			cr.val = high | int64(bit)
+			cr.started = true
+			return true
+		}
+		cr.blk++
+		from = 0
+	}
+
+	cr.done = true
+
+	return false
+}
+
+// next advances to the following UID
+func (cr *postingCursor) next() bool {
+
+	if cr.done {
+		return false
+	}
+
+	pl := cr.list
+
+	switch pl.kind {
+	case plainPostings:
+		cr.idx++
+		if cr.idx >= len(pl.ids) {
+			cr.done = true
+			return false
+		}
+		cr.val = pl.ids[cr.idx]
+	case blockPostings:
+		cr.idx++
+		for cr.blk < 0 || cr.idx >= len(cr.buf) {
+			cr.blk++
+			if cr.blk >= len(pl.lasts) {
+				cr.done = true
+				return false
+			}
+			cr.buf = pl.decodeBlock(cr.blk, cr.buf[:0])
+			cr.idx = 0
+		}
+		cr.val = cr.buf[cr.idx]
+	case bitmapPostings:
+		return cr.scanContainers(cr.idx + 1)
+	}
+
+	cr.started = true
+
+	return true
+}
+
+// gallop returns the index of the first element at or after from that is not less than target
+func gallop(arr []int64, from int, target int64) int {
+
+	if from < 0 {
+		from = 0
+	}
+
+	// exponential probe keeps short advances cheap, then binary search within the bracket
+	step := 1
+	hi := from
+	for hi < len(arr) && arr[hi] < target {
+		from = hi + 1
+		hi += step
+		step <<= 1
+	}
+	if hi > len(arr) {
+		hi = len(arr)
+	}
+
+	return from + sort.Search(hi-from, func(i int) bool { return arr[from+i] >= target })
+}
+
+// seek advances to the first UID not less than target, using skip pointers or container keys
+func (cr *postingCursor) seek(target int64) bool {
+
+	if cr.done {
+		return false
+	}
+	if cr.started && cr.val >= target {
+		return true
+	}
+
+	pl := cr.list
+
+	switch pl.kind {
+	case plainPostings:
+		cr.idx = gallop(pl.ids, cr.idx, target)
+		if cr.idx >= len(pl.ids) {
+			cr.done = true
+			return false
+		}
+		cr.val = pl.ids[cr.idx]
+	case blockPostings:
+		if cr.blk < 0 || target > pl.lasts[cr.blk] {
+			// skip directly to the block that could contain the target
+			start := cr.blk + 1
+			blk := start + sort.Search(len(pl.lasts)-start, func(i int) bool { return pl.lasts[start+i] >= target })
+			if blk >= len(pl.lasts) {
+				cr.done = true
+				return false
+			}
+			cr.blk = blk
+			cr.buf = pl.decodeBlock(blk, cr.buf[:0])
+			cr.idx = 0
+		}
+		// target is at most the last value of the current block
+		cr.idx = gallop(cr.buf, cr.idx, target)
+		cr.val = cr.buf[cr.idx]
+	case bitmapPostings:
+		// roaring encoding is only used when all UIDs fit in 32 bits
+		if target > math.MaxUint32 {
+			cr.done = true
+			return false
+		}
+		high := uint16(target >> 16)
+		low := int(target & 0xFFFF)
+		start := cr.blk
+		blk := start + sort.Search(len(pl.keys)-start, func(i int) bool { return pl.keys[start+i] >= high })
+		if blk >= len(pl.keys) {
+			cr.done = true
+			return false
+		}
+		from := 0
+		if blk == cr.blk && cr.started {
+			from = cr.idx
+		}
+		cr.blk = blk
+		if pl.keys[blk] == high {
+			if arr := pl.arrays[blk]; arr != nil {
+				from += sort.Search(len(arr)-from, func(i int) bool { return int(arr[from+i]) >= low })
+			} else if low > from {
+				from = low
+			}
+		}
+		return cr.scanContainers(from)
+	}
+
+	cr.started = true
+
+	return true
+}
+
+// encodeBlocks writes the block-packed form, with skip table ahead of the block data
+func encodeBlocks(ids []int64, buf []byte) []byte {
+
+	var tmp [binary.MaxVarintLen64]byte
+
+	putUvarint := func(dst []byte, val uint64) []byte {
+		n := binary.PutUvarint(tmp[:], val)
+		return append(dst, tmp[:n]...)
+	}
+
+	var blocks [][]byte
+	var lasts []int64
+
+	prev := int64(0)
+	for i := 0; i < len(ids); i += packedBlockSize {
+		j := i + packedBlockSize
+		if j > len(ids) {
+			j = len(ids)
+		}
+		var blk []byte
+		for _, uid := range ids[i:j] {
+			blk = putUvarint(blk, uint64(uid-prev))
+			prev = uid
+		}
+		blocks = append(blocks, blk)
+		lasts = append(lasts, prev)
+	}
+
+	buf = append(buf, blockPostings)
+	buf = putUvarint(buf, uint64(len(ids)))
+	buf = putUvarint(buf, uint64(len(blocks)))
+
+	last := int64(0)
+	for k, blk := range blocks {
+		buf = putUvarint(buf, uint64(lasts[k]-last))
+		buf = putUvarint(buf, uint64(len(blk)))
+		last = lasts[k]
+	}
+	for _, blk := range blocks {
+		buf = append(buf, blk...)
+	}
+
+	return buf
+}
+
+// encodeBitmap writes the roaring form, with arrays of little-endian 16-bit values or 1024-word bitmaps
+func encodeBitmap(ids []int64, buf []byte) []byte {
+
+	var tmp [binary.MaxVarintLen64]byte
+
+	putUvarint := func(dst []byte, val uint64) []byte {
+		n := binary.PutUvarint(tmp[:], val)
+		return append(dst, tmp[:n]...)
+	}
+
+	// split UIDs into runs sharing the same upper 16 bits
+	var groups [][]int64
+	for i := 0; i < len(ids); {
+		high := uint32(ids[i]) >> 16
+		j := i + 1
+		for j < len(ids) && uint32(ids[j])>>16 == high {
+			j++
+		}
+		groups = append(groups, ids[i:j])
+		i = j
+	}
+
+	buf = append(buf, bitmapPostings)
+	buf = putUvarint(buf, uint64(len(ids)))
+	buf = putUvarint(buf, uint64(len(groups)))
+
+	for _, grp := range groups {
+		buf = putUvarint(buf, uint64(uint32(grp[0])>>16))
+		buf = putUvarint(buf, uint64(len(grp)))
+	}
+
+	for _, grp := range groups {
+		if len(grp) <= packedArrayMax {
+			for _, uid := range grp {
+				buf = append(buf, byte(uid), byte(uid>>8))
+			}
+			continue
+		}
+		var bmp [1024]uint64
+		for _, uid := range grp {
+			low := uint32(uid) & 0xFFFF
+			bmp[low>>6] |= 1 << (low & 63)
+		}
+		for _, word := range bmp {
+			buf = append(buf, byte(word), byte(word>>8), byte(word>>16), byte(word>>24),
+				byte(word>>32), byte(word>>40), byte(word>>48), byte(word>>56))
+		}
+	}
+
+	return buf
+}
+
+// encodePostingList chooses the smaller of the block-packed and roaring encodings for frequent terms
+func encodePostingList(ids []int64) []byte {
+
+	blk := encodeBlocks(ids, nil)
+
+	if len(ids) < packedBitmapMin || ids[0] < 0 || ids[len(ids)-1] > math.MaxUint32 {
+		return blk
+	}
+
+	bmp := encodeBitmap(ids, nil)
+	if len(bmp) < len(blk) {
+		return bmp
+	}
+
+	return blk
+}
+
+// decodePostingList parses the headers of an encoded UID section, leaving block data packed
+func decodePostingList(data []byte) *PostingList {
+
+	if len(data) < 1 {
+		return nil
+	}
+
+	kind := int(data[0])
+	data = data[1:]
+
+	failed := false
+
+	getUvarint := func() int {
+		val, n := binary.Uvarint(data)
+		if n <= 0 {
+			failed = true
+			return 0
+		}
+		data = data[n:]
+		return int(val)
+	}
+
+	pl := &PostingList{kind: kind}
+	pl.count = getUvarint()
+
+	switch kind {
+	case blockPostings:
+		num := getUvarint()
+		pl.lasts = make([]int64, num)
+		pl.starts = make([]int, num+1)
+		last := int64(0)
+		for k := 0; k < num; k++ {
+			last += int64(getUvarint())
+			pl.lasts[k] = last
+			pl.starts[k+1] = pl.starts[k] + getUvarint()
+		}
+		if failed || pl.starts[num] > len(data) {
+			return nil
+		}
+		pl.packed = data[:pl.starts[num]]
+	case bitmapPostings:
+		num := getUvarint()
+		pl.keys = make([]uint16, num)
+		cards := make([]int, num)
+		for k := 0; k < num; k++ {
+			pl.keys[k] = uint16(getUvarint())
+			cards[k] = getUvarint()
+		}
+		if failed {
+			return nil
+		}
+		pl.arrays = make([][]uint16, num)
+		pl.bitmaps = make([][]uint64, num)
+		for k, card := range cards {
+			if card <= packedArrayMax {
+				if len(data) < card*2 {
+					return nil
+				}
+				arr := make([]uint16, card)
+				for i := range arr {
+					arr[i] = binary.LittleEndian.Uint16(data[i*2:])
+				}
+				pl.arrays[k] = arr
+				data = data[card*2:]
+				continue
+			}
+			if len(data) < 8192 {
+				return nil
+			}
+			bmp := make([]uint64, 1024)
+			for i := range bmp {
+				bmp[i] = binary.LittleEndian.Uint64(data[i*8:])
+			}
+			pl.bitmaps[k] = bmp
+			data = data[8192:]
+		}
+	default:
+		return nil
+	}
+
+	if failed {
+		return nil
+	}
+
+	return pl
+}
+
+// encodePositions writes per-UID position counts followed by delta-encoded word positions
+func encodePositions(ofst [][]int32) []byte {
+
+	var buf []byte
+	var tmp [binary.MaxVarintLen64]byte
+
+	for _, posn := range ofst {
+		n := binary.PutUvarint(tmp[:], uint64(len(posn)))
+		buf = append(buf, tmp[:n]...)
+		prev := int32(0)
+		for _, pos := range posn {
+			n = binary.PutUvarint(tmp[:], uint64(uint32(pos-prev)))
+			buf = append(buf, tmp[:n]...)
+			prev = pos
+		}
+	}
+
+	return buf
+}
+
+// decodePositions restores word positions for a given number of UIDs
+func decodePositions(data []byte, num int) [][]int32 {
+
+	arrs := make([][]int32, num)
+
+	for i := 0; i < num; i++ {
+		cnt, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil
+		}
+		data = data[n:]
+		posn := make([]int32, cnt)
+		prev := int32(0)
+		for j := range posn {
+			delta, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil
+			}
+			data = data[n:]
+			prev += int32(delta)
+			posn[j] = prev
+		}
+		arrs[i] = posn
+	}
+
+	return arrs
+}
+
+// packedIndex holds the term list and offset table of a .cps file, with the file kept open for reading sections
+type packedIndex struct {
+	fpath   string
+	file    *os.File
+	terms   []string
+	offsets [][2]int64
+	hasPosn bool
+}
+
+// packed indices are cached so wildcard and facet scans do not reload them for every term,
+// evicted entries are closed by the garbage collector once no search is using them
+const packedCacheMax = 128
+
+var (
+	packedLock  sync.Mutex
+	packedCache = make(map[string]*packedIndex)
+)
+
+// readPackedIndex returns nil if no packed postings file exists for this key and field,
+// or if the term list was promoted again after the packed file was written
+func readPackedIndex(dpath, key, field string) *packedIndex {
+
+	fpath := path.Join(dpath, key+"."+field+".cps")
+
+	packedLock.Lock()
+	pi, ok := packedCache[fpath]
+	packedLock.Unlock()
+
+	if ok {
+		return pi
+	}
+
+	inFile, _ := commonOpenFile(dpath, key+"."+field+".cps")
+	if inFile == nil {
+		return nil
+	}
+
+	pi = loadPackedIndex(inFile, dpath, key, field)
+	if pi == nil {
+		inFile.Close()
+	}
+
+	packedLock.Lock()
+	if prev, ok := packedCache[fpath]; ok {
+		// another search loaded it first
+		packedLock.Unlock()
+		if pi != nil {
+			inFile.Close()
+		}
+		return prev
+	}
+	if len(packedCache) >= packedCacheMax {
+		for ky := range packedCache {
+			delete(packedCache, ky)
+			break
+		}
+	}
+	packedCache[fpath] = pi
+	packedLock.Unlock()
+
+	return pi
+}
+
+// loadPackedIndex reads the header, offset table, and term list of an open .cps file
+func loadPackedIndex(inFile *os.File, dpath, key, field string) *packedIndex {
+
+	fpath := path.Join(dpath, key+"."+field+".cps")
+
+	// postings promoted after conversion are newer than the packed file
+	if cps, err := inFile.Stat(); err == nil {
+		if mst, err := os.Stat(path.Join(dpath, key+"."+field+".mst")); err == nil && mst.ModTime().After(cps.ModTime()) {
+			fmt.Fprintf(os.Stderr, "\nWARNING: Ignoring packed postings older than term list in '%s'\n", fpath)
+			return nil
+		}
+	}
+
+	var hdr struct {
+		Magic    [4]byte
+		Version  uint32
+		NumTerms uint32
+		Flags    uint32
+	}
+
+	err := binary.Read(inFile, binary.LittleEndian, &hdr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return nil
+	}
+
+	if string(hdr.Magic[:]) != packedMagic || hdr.Version != packedVersion {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unsupported packed postings version in '%s'\n", fpath)
+		return nil
+	}
+
+	pi := &packedIndex{
+		fpath:   fpath,
+		file:    inFile,
+		offsets: make([][2]int64, hdr.NumTerms+1),
+		hasPosn: hdr.Flags&1 != 0,
+	}
+
+	err = binary.Read(inFile, binary.LittleEndian, pi.offsets)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return nil
+	}
+
+	trms := readTermList(dpath, key, field)
+	pi.terms = strings.Split(strings.TrimSuffix(string(trms), "\n"), "\n")
+
+	if len(pi.terms) != int(hdr.NumTerms) {
+		fmt.Fprintf(os.Stderr, "\nERROR: Term list does not match packed postings in '%s'\n", pi.fpath)
+		return nil
+	}
+
+	return pi
+}
+
+// readSection loads the bytes between two offsets of the packed postings file
+func (pi *packedIndex) readSection(from, to int64) []byte {
+
+	if to <= from {
+		return nil
+	}
+
+	data := make([]byte, to-from)
+
+	// ReadAt is safe for concurrent use of the shared file handle
+	_, err := pi.file.ReadAt(data, from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return nil
+	}
+
+	return data
+}
+
+// count reads just the document frequency at the start of a term's UID section
+func (pi *packedIndex) count(idx int) int {
+
+	from, to := pi.offsets[idx][0], pi.offsets[idx][1]
+	if to-from > 1+binary.MaxVarintLen64 {
+		to = from + 1 + binary.MaxVarintLen64
+	}
+
+	data := pi.readSection(from, to)
+	if len(data) < 2 {
+		return 0
+	}
+
+	val, n := binary.Uvarint(data[1:])
+	if n <= 0 {
+		return 0
+	}
+
+	return int(val)
+}
+
+// postings looks up a normalized term, or a range of terms for a trailing wildcard
+func (pi *packedIndex) postings(term string, isWildCard, simple bool) (*PostingList, [][]int32) {
+
+	numTerms := len(pi.terms)
+
+	L := sort.SearchStrings(pi.terms, term)
+	R := L
+	if isWildCard {
+		for R < numTerms && strings.HasPrefix(pi.terms[R], term) {
+			R++
+		}
+	} else if L < numTerms && pi.terms[L] == term {
+		R = L + 1
+	}
+
+	if L >= R {
+		return nil, nil
+	}
+
+	if !simple && !pi.hasPosn {
+		return nil, nil
+	}
+
+	// one read covers the UID and position sections of all terms in range
+	base := pi.offsets[L][0]
+	data := pi.readSection(base, pi.offsets[R][0])
+	if data == nil {
+		return nil, nil
+	}
+
+	if R == L+1 {
+		pl := decodePostingList(data[:pi.offsets[L][1]-base])
+		if pl == nil || simple {
+			return pl, nil
+		}
+		arrs := decodePositions(data[pi.offsets[L][1]-base:], pl.count)
+		return newPostingList(pl.IDs()), arrs
+	}
+
+	// wild card search fuses adjacent postings lists
+	combo := make(map[int64][]int32)
+
+	for k := L; k < R; k++ {
+		pl := decodePostingList(data[pi.offsets[k][0]-base : pi.offsets[k][1]-base])
+		if pl == nil {
+			continue
+		}
+		ids := pl.IDs()
+		var arrs [][]int32
+		if !simple {
+			arrs = decodePositions(data[pi.offsets[k][1]-base:pi.offsets[k+1][0]-base], len(ids))
+		}
+		for i, uid := range ids {
+			if arrs != nil {
+				combo[uid] = append(combo[uid], arrs[i]...)
+			} else if _, ok := combo[uid]; !ok {
+				combo[uid] = nil
+			}
+		}
+	}
+
+	fused := make([]int64, 0, len(combo))
+	for uid := range combo {
+		fused = append(fused, uid)
+	}
+
+	sort.Slice(fused, func(i, j int) bool { return fused[i] < fused[j] })
+
+	if simple {
+		return newPostingList(fused), nil
+	}
+
+	arrs := make([][]int32, len(fused))
+	for j, uid := range fused {
+		posn := combo[uid]
+		sort.Slice(posn, func(i, j int) bool { return posn[i] < posn[j] })
+		arrs[j] = posn
+	}
+
+	return newPostingList(fused), arrs
+}
+
+// getPostingList returns postings in packed form when available, otherwise wraps the original format
+func getPostingList(prom, term, field string, simple bool) (*PostingList, [][]int32) {
+
+	var arry [516]rune
+
+	if needsDictionary(term) {
+		data, ofst := getDictionaryPostingIDs(prom, term, field, simple)
+		return newPostingList(data), ofst
+	}
+
+	if _, _, ok := parseFuzzyTerm(term); ok {
+		data, ofst := getFuzzyPostingIDs(prom, term, field, simple)
+		return newPostingList(data), ofst
+	}
+
+	dpath, key := PostingPath(prom, field, term, arry)
+	if dpath == "" {
+		return nil, nil
+	}
+
+	pi := readPackedIndex(dpath, key, field)
+	if pi == nil {
+		data, ofst := getPostingIDs(prom, term, field, simple)
+		return newPostingList(data), ofst
+	}
+
+	term, isWildCard, ok := normalizePostingTerm(term)
+	if !ok {
+		return nil, nil
+	}
+
+	return pi.postings(term, isWildCard, simple)
+}
+
+// writePackedPostings converts one term list from the original .mst, .pst, .uqi, and .ofs files
+func writePackedPostings(dpath, key, field string, hdr PostingsHeader) bool {
+
+	indx := readMasterIndex(dpath, key, field)
+	if len(indx) < 2 {
+		return false
+	}
+
+	numTerms := len(indx) - 1
+
+	pst := readPostingData(dpath, key, field, 0, indx[numTerms].PostOffset, hdr)
+	if len(pst) < 1 {
+		return false
+	}
+
+	// position index and offsets are only present for fields with position attributes
+	var uqis []int32
+	var ofst []int32
+	if fi, err := os.Stat(path.Join(dpath, key+"."+field+".uqi")); err == nil && fi.Size() > 0 {
+		uqis = readPositionIndex(dpath, key, field, 0, int32(fi.Size()))
+		if fi, err = os.Stat(path.Join(dpath, key+"."+field+".ofs")); err == nil && fi.Size() > 0 {
+			ofst = readOffsetData(dpath, key, field, 0, int32(fi.Size()), hdr)
+		}
+	}
+	hasPosn := len(uqis) == len(pst)+1 && ofst != nil
+
+	var body []byte
+	offsets := make([][2]int64, numTerms+1)
+
+	hdrSize := int64(16 + 16*(numTerms+1))
+
+	idSize := int32(hdr.idSize())
+	posSize := int32(hdr.posSize())
+
+	for i := 0; i < numTerms; i++ {
+		from := indx[i].PostOffset / idSize
+		to := indx[i+1].PostOffset / idSize
+
+		offsets[i][0] = hdrSize + int64(len(body))
+		body = append(body, encodePostingList(pst[from:to])...)
+		offsets[i][1] = hdrSize + int64(len(body))
+
+		if hasPosn {
+			arrs := make([][]int32, to-from)
+			for j := from; j < to; j++ {
+				arrs[j-from] = ofst[uqis[j]/posSize : uqis[j+1]/posSize]
+			}
+			body = append(body, encodePositions(arrs)...)
+		}
+	}
+
+	offsets[numTerms][0] = hdrSize + int64(len(body))
+	offsets[numTerms][1] = offsets[numTerms][0]
+
+	fl, err := os.Create(path.Join(dpath, key+"."+field+".cps"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return false
+	}
+
+	defer fl.Close()
+
+	wrtr := bufio.NewWriter(fl)
+
+	flags := uint32(0)
+	if hasPosn {
+		flags = 1
+	}
+
+	wrtr.WriteString(packedMagic)
+	binary.Write(wrtr, binary.LittleEndian, []uint32{packedVersion, uint32(numTerms), flags})
+	binary.Write(wrtr, binary.LittleEndian, offsets)
+	wrtr.Write(body)
+
+	err = wrtr.Flush()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// CreatePackers converts original postings files under the promoted index directory to the
+// packed format, for each of the specified fields, sending the name of each converted term list
+func CreatePackers(prom, fields string) <-chan string {
+
+	if prom == "" || fields == "" {
+		return nil
+	}
+
+	out := make(chan string, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create packer channel\n")
+		os.Exit(1)
+	}
+
+	flds := strings.Fields(fields)
+
+	hdr := ReadPostingsHeader(prom)
+
+	packPostings := func(out chan<- string) {
+
+		defer close(out)
+
+		for _, field := range flds {
+
+			sfx := "." + field + ".mst"
+
+			filepath.Walk(path.Join(prom, field), func(fpath string, info os.FileInfo, err error) error {
+
+				if err != nil || info.IsDir() || !strings.HasSuffix(fpath, sfx) {
+					return nil
+				}
+
+				dpath, fname := filepath.Split(fpath)
+				key := strings.TrimSuffix(fname, sfx)
+
+				if writePackedPostings(dpath, key, field, hdr) {
+					out <- key
+				}
+
+				return nil
+			})
+		}
+	}
+
+	go packPostings(out)
+
+	return out
+}
@@ -0,0 +1,331 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  prosite.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PROSITE patterns are described in the PROSITE user manual, section IV.E,
+// https://prosite.expasy.org/prosuser.html#conv_pa
+
+// Motif contains a named PROSITE pattern or regular expression
+type Motif struct {
+	Accession string
+	Name      string
+	Pattern   string
+	IsRegex   bool
+}
+
+// common post-translational modification and targeting motifs from PROSITE
+var prositeMotifs = []Motif{
+	{"PS00001", "ASN_GLYCOSYLATION", "N-{P}-[ST]-{P}.", false},
+	{"PS00004", "CAMP_PHOSPHO_SITE", "[RK](2)-x-[ST].", false},
+	{"PS00005", "PKC_PHOSPHO_SITE", "[ST]-x-[RK].", false},
+	{"PS00006", "CK2_PHOSPHO_SITE", "[ST]-x(2)-[DE].", false},
+	{"PS00007", "TYR_PHOSPHO_SITE_1", "[RK]-x(2)-[DE]-x(3)-Y.", false},
+	{"PS00008", "MYRISTYL", "G-{EDRKHPFYW}-x(2)-[STAGCN]-{P}.", false},
+	{"PS00009", "AMIDATION", "x-G-[RK]-[RK].", false},
+	{"PS00014", "ER_TARGET", "[KRHQSA]-[DENQ]-E-L>.", false},
+	{"PS00016", "RGD", "R-G-D.", false},
+	{"PS00017", "ATP_GTP_A", "[AG]-x(4)-G-K-[ST].", false},
+	{"PS00018", "EF_HAND_1", "D-{W}-[DNS]-{ILVFYW}-[DENSTG]-[DNQGHRK]-{GP}-[LIVMC]-[DENQSTAGC]-x(2)-[DE].", false},
+	{"PS00028", "ZINC_FINGER_C2H2_1", "C-x(2,4)-C-x(3)-[LIVMFYWC]-x(8)-H-x(3,5)-H.", false},
+	{"PS00029", "LEUCINE_ZIPPER", "L-x(6)-L-x(6)-L-x(6)-L.", false},
+	{"PS00342", "MICROBODIES_CTER", "[STAGCN]-[RKH]-[LIVMAFY]>.", false},
+}
+
+// DefaultMotifs returns the bundled PROSITE motif library
+func DefaultMotifs() []Motif {
+
+	arry := make([]Motif, len(prositeMotifs))
+	copy(arry, prositeMotifs)
+
+	return arry
+}
+
+// PrositeToRegexp compiles a PROSITE pattern, e.g., "N-{P}-[ST]-{P}.", into an equivalent
+// regular expression. It returns an empty string after reporting a syntax error.
+func PrositeToRegexp(pat string) string {
+
+	pat = strings.TrimSpace(pat)
+	pat = strings.TrimSuffix(pat, ".")
+	pat = strings.ToUpper(pat)
+
+	if pat == "" {
+		return ""
+	}
+
+	reportError := func(msg string) string {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s %s in PROSITE pattern '%s'%s\n", INVT, LOUD, msg, pat, INIT)
+		return ""
+	}
+
+	isResidue := func(ch byte) bool {
+		return ch >= 'A' && ch <= 'Z'
+	}
+
+	var buffer strings.Builder
+
+	elements := strings.Split(pat, "-")
+	last := len(elements) - 1
+
+	for i, elem := range elements {
+
+		elem = strings.TrimSpace(elem)
+		if elem == "" {
+			return reportError("Empty element")
+		}
+
+		// N-terminal anchor only allowed on first element
+		if strings.HasPrefix(elem, "<") {
+			if i != 0 {
+				return reportError("Misplaced '<' anchor")
+			}
+			buffer.WriteString("^")
+			elem = elem[1:]
+		}
+
+		// C-terminal anchor only allowed on last element
+		atEnd := false
+		if strings.HasSuffix(elem, ">") {
+			if i != last {
+				return reportError("Misplaced '>' anchor")
+			}
+			atEnd = true
+			elem = elem[:len(elem)-1]
+		}
+
+		// separate optional repeat count, e.g., x(2,4)
+		rept := ""
+		if strings.HasSuffix(elem, ")") {
+			idx := strings.Index(elem, "(")
+			if idx < 0 {
+				return reportError("Unbalanced parentheses")
+			}
+			rept = elem[idx+1 : len(elem)-1]
+			elem = elem[:idx]
+			lft, rgt := SplitInTwoLeft(rept, ",")
+			if lft == "" || !IsAllDigits(lft) || (strings.Contains(rept, ",") && !IsAllDigits(rgt)) {
+				return reportError("Bad repeat count")
+			}
+			if rgt != "" {
+				mn, _ := strconv.Atoi(lft)
+				mx, _ := strconv.Atoi(rgt)
+				if mn > mx {
+					return reportError("Minimum repeat count exceeds maximum")
+				}
+			}
+		}
+
+		switch {
+		case elem == "X":
+			buffer.WriteString(".")
+		case len(elem) == 1 && isResidue(elem[0]):
+			buffer.WriteString(elem)
+		case strings.HasPrefix(elem, "[") && strings.HasSuffix(elem, "]"):
+			inner := elem[1 : len(elem)-1]
+			// [G>] matches G or the C-terminus
+			orEnd := false
+			if strings.HasSuffix(inner, ">") && i == last {
+				orEnd = true
+				inner = inner[:len(inner)-1]
+			}
+			if inner == "" {
+				return reportError("Empty residue set")
+			}
+			for j := 0; j < len(inner); j++ {
+				if !isResidue(inner[j]) {
+					return reportError("Unexpected character in residue set")
+				}
+			}
+			if orEnd {
+				buffer.WriteString("(?:[" + inner + "]|$)")
+			} else {
+				buffer.WriteString("[" + inner + "]")
+			}
+		case strings.HasPrefix(elem, "{") && strings.HasSuffix(elem, "}"):
+			inner := elem[1 : len(elem)-1]
+			if inner == "" {
+				return reportError("Empty excluded residue set")
+			}
+			for j := 0; j < len(inner); j++ {
+				if !isResidue(inner[j]) {
+					return reportError("Unexpected character in excluded residue set")
+				}
+			}
+			buffer.WriteString("[^" + inner + "]")
+		default:
+			return reportError("Unrecognized element '" + elem + "'")
+		}
+
+		if rept != "" {
+			buffer.WriteString("{" + rept + "}")
+		}
+
+		if atEnd {
+			buffer.WriteString("$")
+		}
+	}
+
+	return buffer.String()
+}
+
+// ReadMotifLibrary reads PROSITE motifs from prosite.dat format, using the ID, AC, and PA
+// lines of PATTERN entries, or from a simple table of name, pattern, and optional accession
+func ReadMotifLibrary(inp io.Reader) []Motif {
+
+	if inp == nil {
+		return nil
+	}
+
+	var arry []Motif
+
+	var curr Motif
+	isPattern := false
+	inEntry := false
+
+	scanr := bufio.NewScanner(inp)
+	scanr.Buffer(make([]byte, 65536), 1048576)
+
+	for scanr.Scan() {
+
+		line := scanr.Text()
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// prosite.dat lines have a two-letter code followed by three spaces
+		if (len(line) > 4 && line[2:5] == "   ") || line == "//" {
+
+			code := line[:2]
+			rest := ""
+			if len(line) > 5 {
+				rest = strings.TrimSpace(line[5:])
+			}
+
+			switch code {
+			case "ID":
+				inEntry = true
+				name, kind := SplitInTwoLeft(rest, ";")
+				curr = Motif{Name: strings.TrimSpace(name)}
+				isPattern = strings.HasPrefix(strings.TrimSpace(kind), "PATTERN")
+			case "AC":
+				acc, _ := SplitInTwoLeft(rest, ";")
+				curr.Accession = strings.TrimSpace(acc)
+			case "PA":
+				// pattern can continue over several lines
+				curr.Pattern += rest
+			case "//":
+				if inEntry && isPattern && curr.Pattern != "" {
+					arry = append(arry, curr)
+				}
+				curr = Motif{}
+				isPattern = false
+				inEntry = false
+			}
+
+			continue
+		}
+
+		if inEntry {
+			continue
+		}
+
+		// otherwise tab-delimited name, pattern, and optional accession
+		cols := strings.Split(line, "\t")
+		if len(cols) < 2 {
+			fmt.Fprintf(os.Stderr, "%s ERROR: %s Unrecognized motif library line '%s'%s\n", INVT, LOUD, line, INIT)
+			continue
+		}
+		mtf := Motif{Name: strings.TrimSpace(cols[0]), Pattern: strings.TrimSpace(cols[1])}
+		if len(cols) > 2 {
+			mtf.Accession = strings.TrimSpace(cols[2])
+		}
+		arry = append(arry, mtf)
+	}
+
+	return arry
+}
+
+// MotifSearcher compiles PROSITE patterns or regular expressions for scanning protein sequences
+func MotifSearcher(motifs []Motif) *Searcher {
+
+	if motifs == nil {
+		return nil
+	}
+
+	var arry []motifEntry
+
+	for _, mtf := range motifs {
+
+		expr := mtf.Pattern
+		if !mtf.IsRegex {
+			expr = PrositeToRegexp(expr)
+			if expr == "" {
+				fmt.Fprintf(os.Stderr, "ERROR: Ignoring motif '%s'\n", mtf.Name)
+				continue
+			}
+		}
+
+		if mtf.IsRegex {
+			// sequence text is upper-cased before scanning
+			expr = "(?i)" + expr
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: Ignoring motif '%s' - %s\n", mtf.Name, err.Error())
+			continue
+		}
+
+		// searches after the first match begin one residue early, keeping '^' tied to the sequence start
+		nx, err := regexp.Compile("(?s:.)(" + expr + ")")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: Ignoring motif '%s' - %s\n", mtf.Name, err.Error())
+			continue
+		}
+
+		alias := mtf.Name
+		if alias == "" {
+			alias = mtf.Accession
+		}
+		if alias == "" {
+			alias = mtf.Pattern
+		}
+
+		me := motifEntry{expr: re, next: nx, alias: alias}
+		arry = append(arry, me)
+	}
+
+	if len(arry) < 1 {
+		return nil
+	}
+
+	return &Searcher{
+		useMotif:  true,
+		motifData: motifData{motifPats: arry},
+	}
+}
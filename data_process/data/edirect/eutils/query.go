@@ -0,0 +1,642 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  query.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// QUERY TOKENIZER AND PARSER
+
+// queryToken kinds
+const (
+	qryWord = iota
+	qryField
+	qryOpen
+	qryClose
+	qryAnd
+	qryOr
+	qryNot
+	qryProx
+	qryFuzzy
+	qryEnd
+)
+
+type queryToken struct {
+	Kind int
+	Text string
+	// 1-based character position in original query
+	Pos int
+}
+
+// queryNode kinds
+const (
+	qryPhrase = iota
+	qryRange
+	qryAndNode
+	qryOrNode
+	qryNotNode
+	qryProxNode
+	qryHistory
+)
+
+// queryNode is one element of the parsed query tree
+type queryNode struct {
+	Kind int
+	// original words and field qualifier of a phrase, e.g., "vitamin c [TIAB]", or "#n" history reference
+	Text string
+	// normalized clause used for postings lookup
+	Clause string
+	Pos    int
+	// field qualifier and its position, checked against the index schema
+	Field    string
+	FieldPos int
+	// tilde counts between successive proximity operands
+	Dist []int
+	// maximum edit distance requested by ~fuzzy, -1 chooses by word length
+	Fuzzy    int
+	Children []*queryNode
+	// document count and wildcard expansions recorded during evaluation
+	Count    int
+	Expanded []string
+	// phrase start positions and length in words, kept for highlighting
+	hits []int64
+	psns [][]int32
+	span int
+}
+
+// QueryError reports a syntax error at a character position in the original query
+type QueryError struct {
+	Query string
+	Pos   int
+	Msg   string
+}
+
+func (e *QueryError) Error() string {
+
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+}
+
+// report prints the error message and the query with a caret under the offending character
+func (e *QueryError) report() {
+
+	fmt.Fprintf(os.Stderr, "\nERROR: %s\n\n", e.Error())
+
+	var buffer strings.Builder
+
+	for i, ch := range []rune(e.Query) {
+		if i+1 >= e.Pos {
+			break
+		}
+		if ch == '\t' {
+			buffer.WriteRune('\t')
+		} else {
+			buffer.WriteRune(' ')
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "  %s\n  %s^\n\n", e.Query, buffer.String())
+}
+
+// tokenizeQuery breaks a query into words, bracketed fields, parentheses, and operators
+func tokenizeQuery(str string) ([]queryToken, *QueryError) {
+
+	var tkns []queryToken
+
+	runes := []rune(str)
+	max := len(runes)
+
+	isDelim := func(ch rune) bool {
+		return unicode.IsSpace(ch) || ch == '(' || ch == ')' || ch == '&' || ch == '|' ||
+			ch == '!' || ch == '~' || ch == '[' || ch == ']'
+	}
+
+	// isFuzzy checks for "fuzzy" and optional digits immediately after a tilde
+	isFuzzy := func(i int) bool {
+		if i+5 > max || strings.ToLower(string(runes[i:i+5])) != "fuzzy" {
+			return false
+		}
+		j := i + 5
+		for j < max && unicode.IsDigit(runes[j]) {
+			j++
+		}
+		return j == max || isDelim(runes[j])
+	}
+
+	// quotation marks are ignored when searching, but must be paired
+	open := 0
+	for i, ch := range runes {
+		if ch != '"' {
+			continue
+		}
+		if open > 0 {
+			open = 0
+		} else {
+			open = i + 1
+		}
+	}
+	if open > 0 {
+		return nil, &QueryError{str, open, "Unterminated quotation"}
+	}
+
+	for i := 0; i < max; {
+
+		ch := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case ch == '(':
+			tkns = append(tkns, queryToken{qryOpen, "(", pos})
+			i++
+		case ch == ')':
+			tkns = append(tkns, queryToken{qryClose, ")", pos})
+			i++
+		case ch == '&':
+			tkns = append(tkns, queryToken{qryAnd, "&", pos})
+			i++
+		case ch == '|':
+			tkns = append(tkns, queryToken{qryOr, "|", pos})
+			i++
+		case ch == '!':
+			tkns = append(tkns, queryToken{qryNot, "!", pos})
+			i++
+		case ch == '~' && isFuzzy(i+1):
+			// ~fuzzy qualifier, with optional maximum edit distance
+			j := i + 1
+			for j < max && !isDelim(runes[j]) {
+				j++
+			}
+			tkns = append(tkns, queryToken{qryFuzzy, string(runes[i:j]), pos})
+			i = j
+		case ch == '~':
+			// runs of tildes, even if separated by spaces, increase the proximity distance
+			j := i
+			dist := 0
+			for j < max && (runes[j] == '~' || unicode.IsSpace(runes[j])) {
+				if runes[j] == '~' {
+					dist++
+				}
+				j++
+			}
+			tkns = append(tkns, queryToken{qryProx, strings.Repeat("~", dist), pos})
+			i = j
+		case ch == '[':
+			j := i + 1
+			for j < max && runes[j] != ']' && runes[j] != '[' {
+				j++
+			}
+			if j >= max || runes[j] != ']' {
+				return nil, &QueryError{str, pos, "Unterminated field qualifier"}
+			}
+			fld := strings.ToUpper(strings.TrimSpace(string(runes[i+1 : j])))
+			if fld == "" {
+				return nil, &QueryError{str, pos, "Empty field qualifier"}
+			}
+			tkns = append(tkns, queryToken{qryField, fld, pos})
+			i = j + 1
+		case ch == ']':
+			return nil, &QueryError{str, pos, "Unexpected ']'"}
+		default:
+			j := i
+			for j < max && !isDelim(runes[j]) {
+				j++
+			}
+			word := string(runes[i:j])
+			switch word {
+			case "AND":
+				tkns = append(tkns, queryToken{qryAnd, word, pos})
+			case "OR":
+				tkns = append(tkns, queryToken{qryOr, word, pos})
+			case "NOT":
+				tkns = append(tkns, queryToken{qryNot, word, pos})
+			default:
+				tkns = append(tkns, queryToken{qryWord, word, pos})
+			}
+			i = j
+		}
+	}
+
+	tkns = append(tkns, queryToken{qryEnd, "", max + 1})
+
+	return tkns, nil
+}
+
+// parseQuery builds a query tree, with NOT binding more tightly than AND, and AND more tightly than OR
+func parseQuery(str string) (*queryNode, *QueryError) {
+
+	tkns, qerr := tokenizeQuery(str)
+	if qerr != nil {
+		return nil, qerr
+	}
+
+	idx := 0
+
+	peek := func() queryToken {
+		return tkns[idx]
+	}
+
+	next := func() queryToken {
+		tkn := tkns[idx]
+		if idx < len(tkns)-1 {
+			idx++
+		}
+		return tkn
+	}
+
+	fail := func(tkn queryToken, msg string) *QueryError {
+		return &QueryError{str, tkn.Pos, msg}
+	}
+
+	// operands must be separated by an operator
+	checkAdjacent := func(prev string) *QueryError {
+		tkn := peek()
+		if tkn.Kind == qryField {
+			return fail(tkn, "Field qualifier ["+tkn.Text+"] must follow search terms")
+		}
+		if tkn.Kind == qryFuzzy {
+			return fail(tkn, "Qualifier '"+tkn.Text+"' must follow search terms")
+		}
+		if tkn.Kind == qryWord || tkn.Kind == qryOpen {
+			return fail(tkn, "Tokens '"+prev+"' and '"+tkn.Text+"' should be separated by AND, OR, or NOT")
+		}
+		return nil
+	}
+
+	// fuzzy reads an optional ~fuzzy qualifier into a phrase
+	fuzzy := func(node *queryNode, words []string) *QueryError {
+		if peek().Kind != qryFuzzy || node.Fuzzy != 0 {
+			return nil
+		}
+		tkn := next()
+		if len(words) < 1 {
+			return fail(tkn, "Qualifier '"+tkn.Text+"' has no search terms")
+		}
+		node.Fuzzy = -1
+		if num := tkn.Text[len("~fuzzy"):]; num != "" {
+			val, err := strconv.Atoi(num)
+			if err != nil || val < 1 || val > 3 {
+				return fail(tkn, "Fuzzy edit distance must be 1, 2, or 3")
+			}
+			node.Fuzzy = val
+		}
+		return nil
+	}
+
+	// recursive definitions
+	var expr func() (*queryNode, *QueryError)
+	var term func() (*queryNode, *QueryError)
+	var excl func() (*queryNode, *QueryError)
+	var prox func() (*queryNode, *QueryError)
+	var fact func() (*queryNode, *QueryError)
+
+	fact = func() (*queryNode, *QueryError) {
+
+		tkn := peek()
+
+		switch tkn.Kind {
+		case qryOpen:
+			next()
+			node, qerr := expr()
+			if qerr != nil {
+				return nil, qerr
+			}
+			if peek().Kind != qryClose {
+				return nil, fail(tkn, "Unmatched '('")
+			}
+			next()
+			if qerr := checkAdjacent(")"); qerr != nil {
+				return nil, qerr
+			}
+			return node, nil
+		case qryWord, qryField:
+			if isHistoryRef(tkn.Text) {
+				// saved result of an earlier search
+				next()
+				node := &queryNode{Kind: qryHistory, Text: tkn.Text, Pos: tkn.Pos}
+				if qerr := checkAdjacent(tkn.Text); qerr != nil {
+					return nil, qerr
+				}
+				return node, nil
+			}
+			// collect run of words and optional field qualifier
+			var words []string
+			for peek().Kind == qryWord && !isHistoryRef(peek().Text) {
+				words = append(words, next().Text)
+			}
+			node := &queryNode{Kind: qryPhrase, Text: strings.Join(words, " "), Pos: tkn.Pos}
+			// qualifier may come before or after the field
+			if qerr := fuzzy(node, words); qerr != nil {
+				return nil, qerr
+			}
+			if peek().Kind == qryField {
+				fld := next()
+				if len(words) < 1 && fld.Text != "PIPE" {
+					return nil, fail(fld, "Field qualifier ["+fld.Text+"] has no search terms")
+				}
+				node.Text = strings.TrimSpace(node.Text + " [" + fld.Text + "]")
+				node.Field = fld.Text
+				node.FieldPos = fld.Pos
+			}
+			if qerr := fuzzy(node, words); qerr != nil {
+				return nil, qerr
+			}
+			if qerr := checkAdjacent(node.Text); qerr != nil {
+				return nil, qerr
+			}
+			return node, nil
+		case qryClose:
+			return nil, fail(tkn, "Unexpected ')'")
+		case qryFuzzy:
+			return nil, fail(tkn, "Qualifier '"+tkn.Text+"' must follow search terms")
+		case qryEnd:
+			return nil, fail(tkn, "Unexpected end of query")
+		default:
+			return nil, fail(tkn, "Unexpected operator '"+tkn.Text+"'")
+		}
+	}
+
+	prox = func() (*queryNode, *QueryError) {
+
+		node, qerr := fact()
+		if qerr != nil || peek().Kind != qryProx {
+			return node, qerr
+		}
+
+		res := &queryNode{Kind: qryProxNode, Pos: node.Pos, Children: []*queryNode{node}}
+
+		for peek().Kind == qryProx {
+			op := next()
+			nxt, qerr := fact()
+			if qerr != nil {
+				return nil, qerr
+			}
+			// positions are only available for individual phrases
+			if node.Kind != qryPhrase || nxt.Kind != qryPhrase {
+				return nil, fail(op, "Proximity operator requires a phrase on each side")
+			}
+			res.Children = append(res.Children, nxt)
+			res.Dist = append(res.Dist, len(op.Text))
+			node = nxt
+		}
+
+		return res, nil
+	}
+
+	// binary builds a left-associative chain of operands joined by one operator
+	binary := func(op, kind int, operand func() (*queryNode, *QueryError)) (*queryNode, *QueryError) {
+
+		node, qerr := operand()
+		if qerr != nil || peek().Kind != op {
+			return node, qerr
+		}
+
+		res := &queryNode{Kind: kind, Pos: node.Pos, Children: []*queryNode{node}}
+
+		for peek().Kind == op {
+			next()
+			nxt, qerr := operand()
+			if qerr != nil {
+				return nil, qerr
+			}
+			res.Children = append(res.Children, nxt)
+		}
+
+		return res, nil
+	}
+
+	excl = func() (*queryNode, *QueryError) {
+		return binary(qryNot, qryNotNode, prox)
+	}
+
+	term = func() (*queryNode, *QueryError) {
+		return binary(qryAnd, qryAndNode, excl)
+	}
+
+	expr = func() (*queryNode, *QueryError) {
+		return binary(qryOr, qryOrNode, term)
+	}
+
+	// enter recursive descent parser
+	root, qerr := expr()
+	if qerr != nil {
+		return nil, qerr
+	}
+
+	if tkn := peek(); tkn.Kind != qryEnd {
+		if tkn.Kind == qryClose {
+			return nil, fail(tkn, "Unexpected ')'")
+		}
+		return nil, fail(tkn, "Unexpected '"+tkn.Text+"'")
+	}
+
+	return root, nil
+}
+
+// normalizeQueryTree converts the words of each phrase to the normalized clauses used for postings lookup,
+// expanding year ranges into a set of alternative years
+func normalizeQueryTree(str string, node *queryNode, rlxd, deStop bool, schema *IndexSchema) *QueryError {
+
+	if node == nil {
+		return nil
+	}
+
+	if node.Kind != qryPhrase {
+		for _, chld := range node.Children {
+			if qerr := normalizeQueryTree(str, chld, rlxd, deStop, schema); qerr != nil {
+				return qerr
+			}
+		}
+		return nil
+	}
+
+	if node.Field == "EXPN" {
+		// thesaurus expansion replaces the phrase with an OR node
+		return expandThesaurusTerm(str, node, rlxd, deStop, schema)
+	}
+
+	if node.Field != "" && node.Field != "PIPE" && schema.strict && schema.Field(node.Field) == nil {
+		return &QueryError{str, node.FieldPos, "Field [" + node.Field + "] is not in the index schema"}
+	}
+	if node.Field != "" && node.Field != "PIPE" && !schema.hasPostings(node.Field) {
+		return &QueryError{str, node.FieldPos, "Field [" + node.Field + "] is not in the index"}
+	}
+
+	txt := prepareQuery(node.Text)
+	txt = processStopWords(txt, deStop, schema)
+	clauses := partitionQuery(txt)
+	clauses = setFieldQualifiers(clauses, rlxd, schema)
+
+	if len(clauses) > 0 && clauses[0] == "(" {
+		// year range expanded by setFieldQualifiers
+		node.Kind = qryRange
+		for _, cls := range clauses {
+			if cls == "(" || cls == ")" || cls == "|" {
+				continue
+			}
+			node.Children = append(node.Children, &queryNode{Kind: qryPhrase, Text: cls, Clause: cls, Pos: node.Pos})
+		}
+		return nil
+	}
+
+	node.Clause = strings.TrimSpace(strings.Join(clauses, " "))
+
+	if node.Fuzzy != 0 && node.Clause != "" {
+		name, fld := schema.resolveField(node.Field)
+		if fld == nil || fld.Tokenizer == "year" {
+			return &QueryError{str, node.Pos, "Qualifier '~fuzzy' cannot be used with [" + name + "]"}
+		}
+		node.Clause = fuzzyClause(node.Clause, node.Fuzzy, fld.Tokenizer != "words")
+	}
+
+	return nil
+}
+
+// buildQueryTree parses and normalizes a query, reporting any syntax error with its position
+func buildQueryTree(phrase string, xact, titl, rlxd, deStop bool, schema *IndexSchema) *queryNode {
+
+	if xact || titl {
+		// exact and title searches are a single phrase, with punctuation treated as text
+		sfx := "[tiab]"
+		if titl {
+			sfx = "[titl]"
+		}
+		str := prepareExact(phrase, sfx, deStop)
+		str = processStopWords(str, deStop, schema)
+		clauses := partitionQuery(str)
+		clauses = setFieldQualifiers(clauses, rlxd, schema)
+		if len(clauses) < 1 || clauses[0] == "" {
+			return nil
+		}
+		cls := strings.Join(clauses, " ")
+		return &queryNode{Kind: qryPhrase, Text: phrase, Clause: cls, Pos: 1}
+	}
+
+	phrase = strings.TrimSpace(phrase)
+
+	root, qerr := parseQuery(phrase)
+	if qerr == nil {
+		qerr = normalizeQueryTree(phrase, root, rlxd, deStop, schema)
+	}
+	if qerr != nil {
+		qerr.report()
+		os.Exit(1)
+	}
+
+	return root
+}
+
+// formatQueryTree reconstructs the normalized query, qualifying every phrase with its field
+func formatQueryTree(node *queryNode, schema *IndexSchema, nested bool) string {
+
+	if node == nil {
+		return ""
+	}
+
+	var arry []string
+
+	op := ""
+	switch node.Kind {
+	case qryPhrase:
+		cls := node.Clause
+		if cls == "" {
+			return "(no searchable terms)"
+		}
+		if !strings.HasSuffix(cls, "]") {
+			cls += " [" + schema.Default + "]"
+		}
+		return cls
+	case qryHistory:
+		return node.Text
+	case qryRange, qryOrNode:
+		op = "OR"
+	case qryAndNode:
+		op = "AND"
+	case qryNotNode:
+		op = "NOT"
+	}
+
+	for i, chld := range node.Children {
+		if node.Kind == qryProxNode {
+			if i > 0 {
+				arry = append(arry, strings.Repeat("~", node.Dist[i-1]))
+			}
+			arry = append(arry, formatQueryTree(chld, schema, true))
+			continue
+		}
+		if i > 0 {
+			arry = append(arry, op)
+		}
+		arry = append(arry, formatQueryTree(chld, schema, true))
+	}
+
+	str := strings.Join(arry, " ")
+	if nested {
+		str = "(" + str + ")"
+	}
+
+	return str
+}
+
+// printQueryTree shows the document count at each node and the terms matched by each wildcard
+func printQueryTree(node *queryNode, schema *IndexSchema, indent string) {
+
+	if node == nil {
+		return
+	}
+
+	label := ""
+	switch node.Kind {
+	case qryPhrase:
+		label = formatQueryTree(node, schema, false)
+	case qryHistory:
+		label = node.Text
+	case qryRange:
+		label = "RANGE"
+	case qryAndNode:
+		label = "AND"
+	case qryOrNode:
+		label = "OR"
+	case qryNotNode:
+		label = "NOT"
+	case qryProxNode:
+		dist := ""
+		for _, d := range node.Dist {
+			if dist != "" {
+				dist += ","
+			}
+			dist += strconv.Itoa(d)
+		}
+		label = "NEAR " + dist
+	}
+
+	fmt.Fprintf(os.Stdout, "%s%s\t%d\n", indent, label, node.Count)
+
+	for _, str := range node.Expanded {
+		fmt.Fprintf(os.Stdout, "%s    %s\n", indent, str)
+	}
+
+	for _, chld := range node.Children {
+		printQueryTree(chld, schema, indent+"  ")
+	}
+}
@@ -0,0 +1,686 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  schema.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"fmt"
+	"github.com/surgebase/porter2"
+	"html"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// IndexField describes one search field, the XML paths that feed it, and how its contents are tokenized
+type IndexField struct {
+	Name      string
+	Paths     []string
+	Tokenizer string
+	Stem      bool
+	Stop      bool
+	Positions bool
+	Aliases   []string
+	// facet counts group by whole "terms" or by top-level "branch" of dotted codes
+	Facet string
+}
+
+// IndexSchema lists the search fields for a local collection, with the record pattern and identifier path
+type IndexSchema struct {
+	Pattern    string
+	Identifier string
+	Default    string
+	Fields     []IndexField
+	// identifier type and position width passed to WritePostingsHeader, empty for defaults
+	IDType  string
+	PosBits string
+	// unknown fields are rejected only when the schema was read from a file
+	strict bool
+	// postings directory, used to find the query thesaurus
+	prom string
+}
+
+// DefaultIndexSchema returns the PubMed fields previously hard-coded in xtract -e2index and phrase search
+func DefaultIndexSchema() *IndexSchema {
+
+	tiab := []string{"ArticleTitle", "Abstract/AbstractText"}
+
+	return &IndexSchema{
+		Pattern:    "PubmedArticle",
+		Identifier: "MedlineCitation/PMID",
+		Default:    "TIAB",
+		Fields: []IndexField{
+			{Name: "YEAR", Paths: []string{"PubDate/*"}, Tokenizer: "year"},
+			{Name: "TITL", Paths: []string{"ArticleTitle"}, Tokenizer: "words", Stop: true, Positions: true},
+			{Name: "TIAB", Paths: tiab, Tokenizer: "words", Stop: true, Positions: true, Aliases: []string{"NORM"}},
+			{Name: "STEM", Paths: tiab, Tokenizer: "words", Stem: true, Stop: true, Positions: true},
+		},
+	}
+}
+
+// Field returns the field with the given name or alias, or nil if it is not in the schema
+func (s *IndexSchema) Field(name string) *IndexField {
+
+	if s == nil {
+		return nil
+	}
+
+	name = strings.ToUpper(name)
+
+	for i := range s.Fields {
+		fld := &s.Fields[i]
+		if fld.Name == name {
+			return fld
+		}
+		for _, alias := range fld.Aliases {
+			if alias == name {
+				return fld
+			}
+		}
+	}
+
+	return nil
+}
+
+// Names returns the field names in schema order
+func (s *IndexSchema) Names() []string {
+
+	var res []string
+
+	if s == nil {
+		return nil
+	}
+
+	for _, fld := range s.Fields {
+		res = append(res, fld.Name)
+	}
+
+	return res
+}
+
+// resolveField maps a query qualifier to its indexed field name, returning the field description if known
+func (s *IndexSchema) resolveField(name string) (string, *IndexField) {
+
+	if name == "" {
+		name = s.Default
+	}
+
+	name = strings.ToUpper(name)
+
+	if name == "PIPE" {
+		return name, nil
+	}
+
+	fld := s.Field(name)
+	if fld != nil {
+		return fld.Name, fld
+	}
+
+	if s.strict {
+		fmt.Fprintf(os.Stderr, "\nERROR: Field [%s] is not in the index schema\n", name)
+		os.Exit(1)
+	}
+
+	return name, nil
+}
+
+// hasPostings reports whether a field can be searched, checking the postings directory
+// for fields of the legacy PubMed index that are not listed in the default schema
+func (s *IndexSchema) hasPostings(name string) bool {
+
+	if s.Field(name) != nil {
+		return true
+	}
+	if s.strict {
+		return false
+	}
+	if s.prom == "" {
+		// no postings directory to check
+		return true
+	}
+
+	fi, err := os.Stat(path.Join(s.prom, strings.ToUpper(name)))
+
+	return err == nil && fi.IsDir()
+}
+
+// String writes the schema in the same XML form read by LoadIndexSchema
+func (s *IndexSchema) String() string {
+
+	var buffer strings.Builder
+
+	yesNo := func(flag bool) string {
+		if flag {
+			return "true"
+		}
+		return "false"
+	}
+
+	buffer.WriteString("<IndexSchema>\n")
+	buffer.WriteString("  <Pattern>" + s.Pattern + "</Pattern>\n")
+	buffer.WriteString("  <Identifier>" + s.Identifier + "</Identifier>\n")
+	buffer.WriteString("  <Default>" + s.Default + "</Default>\n")
+	if s.IDType != "" {
+		buffer.WriteString("  <IDType>" + s.IDType + "</IDType>\n")
+	}
+	if s.PosBits != "" {
+		buffer.WriteString("  <PosBits>" + s.PosBits + "</PosBits>\n")
+	}
+
+	for _, fld := range s.Fields {
+		buffer.WriteString("  <Field name=\"" + fld.Name + "\"")
+		buffer.WriteString(" path=\"" + strings.Join(fld.Paths, ",") + "\"")
+		buffer.WriteString(" tokenizer=\"" + fld.Tokenizer + "\"")
+		buffer.WriteString(" stem=\"" + yesNo(fld.Stem) + "\"")
+		buffer.WriteString(" stop=\"" + yesNo(fld.Stop) + "\"")
+		buffer.WriteString(" positions=\"" + yesNo(fld.Positions) + "\"")
+		if len(fld.Aliases) > 0 {
+			buffer.WriteString(" alias=\"" + strings.Join(fld.Aliases, ",") + "\"")
+		}
+		if fld.Facet != "" {
+			buffer.WriteString(" facet=\"" + fld.Facet + "\"")
+		}
+		buffer.WriteString("/>\n")
+	}
+
+	buffer.WriteString("</IndexSchema>\n")
+
+	return buffer.String()
+}
+
+// ParseIndexSchema reads an XML index configuration, e.g.:
+//
+//	<IndexSchema>
+//	  <Pattern>AssayReport</Pattern>
+//	  <Identifier>ReportId</Identifier>
+//	  <IDType>string</IDType>
+//	  <Default>TEXT</Default>
+//	  <Field name="TEXT" path="Title,Summary/Paragraph" tokenizer="words" stop="true"/>
+//	  <Field name="STEM" path="Title,Summary/Paragraph" tokenizer="words" stem="true" stop="true"/>
+//	  <Field name="TRGT" path="Target@symbol" tokenizer="terms"/>
+//	  <Field name="YEAR" path="RunDate/*" tokenizer="year"/>
+//	</IndexSchema>
+//
+// The tokenizer is "words" for positional text indexing, "terms" for whole
+// values such as codes or controlled vocabulary, or "year" for the first
+// four-digit year. Word fields keep positions unless positions="false".
+// Setting facet="branch" on a field of hierarchical codes makes -facets
+// count records by the first component of each code. IDType (int32, int64,
+// or string) and PosBits (16 or 32) set the postings header for -promote.
+func ParseIndexSchema(text string) *IndexSchema {
+
+	pat := ParseRecord(text, "IndexSchema")
+	if pat == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to parse index schema\n")
+		return nil
+	}
+
+	s := &IndexSchema{strict: true}
+
+	isTrue := func(str string) bool {
+		switch strings.ToLower(str) {
+		case "true", "yes", "1":
+			return true
+		}
+		return false
+	}
+
+	isFieldName := func(str string) bool {
+		if len(str) < 1 || len(str) > 4 {
+			return false
+		}
+		for _, ch := range str {
+			if (ch < 'A' || ch > 'Z') && (ch < '0' || ch > '9') {
+				return false
+			}
+		}
+		return true
+	}
+
+	for chld := pat.Children; chld != nil; chld = chld.Next {
+
+		switch chld.Name {
+		case "Pattern":
+			s.Pattern = strings.TrimSpace(chld.Contents)
+		case "Identifier":
+			s.Identifier = strings.TrimSpace(chld.Contents)
+		case "Default":
+			s.Default = strings.ToUpper(strings.TrimSpace(chld.Contents))
+		case "IDType":
+			s.IDType = strings.ToLower(strings.TrimSpace(chld.Contents))
+		case "PosBits":
+			s.PosBits = strings.TrimSpace(chld.Contents)
+		case "Field":
+			fld := IndexField{Tokenizer: "words", Positions: true}
+			pos := ""
+			attrs := ParseAttributes(chld.Attributes)
+			for i := 0; i < len(attrs)-1; i += 2 {
+				val := strings.TrimSpace(attrs[i+1])
+				switch attrs[i] {
+				case "name":
+					fld.Name = strings.ToUpper(val)
+				case "path":
+					for _, str := range strings.Split(val, ",") {
+						str = strings.TrimSpace(str)
+						if str != "" {
+							fld.Paths = append(fld.Paths, str)
+						}
+					}
+				case "tokenizer":
+					fld.Tokenizer = strings.ToLower(val)
+				case "stem":
+					fld.Stem = isTrue(val)
+				case "stop":
+					fld.Stop = isTrue(val)
+				case "positions":
+					pos = val
+				case "facet":
+					fld.Facet = strings.ToLower(val)
+					if fld.Facet != "terms" && fld.Facet != "branch" {
+						fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized facet '%s' for field '%s'\n", val, fld.Name)
+						return nil
+					}
+				case "alias":
+					for _, str := range strings.Split(val, ",") {
+						str = strings.TrimSpace(str)
+						if str != "" {
+							fld.Aliases = append(fld.Aliases, strings.ToUpper(str))
+						}
+					}
+				default:
+					fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized index schema attribute '%s'\n", attrs[i])
+					return nil
+				}
+			}
+			switch fld.Tokenizer {
+			case "words":
+				if pos != "" {
+					fld.Positions = isTrue(pos)
+				}
+			case "terms", "year":
+				fld.Positions = false
+			default:
+				fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized tokenizer '%s' for field '%s'\n", fld.Tokenizer, fld.Name)
+				return nil
+			}
+			if !isFieldName(fld.Name) {
+				fmt.Fprintf(os.Stderr, "\nERROR: Index field name '%s' must be one to four capital letters or digits\n", fld.Name)
+				return nil
+			}
+			if fld.Name == "PIPE" || fld.Name == "EXPN" || s.Field(fld.Name) != nil {
+				fmt.Fprintf(os.Stderr, "\nERROR: Duplicate or reserved index field name '%s'\n", fld.Name)
+				return nil
+			}
+			s.Fields = append(s.Fields, fld)
+		default:
+		}
+	}
+
+	if s.Pattern == "" || s.Identifier == "" || len(s.Fields) < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: Index schema requires Pattern, Identifier, and at least one Field\n")
+		return nil
+	}
+
+	if _, ok := ParsePostingsHeader(s.IDType, s.PosBits); !ok {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unrecognized IDType '%s' or PosBits '%s' in index schema\n", s.IDType, s.PosBits)
+		return nil
+	}
+
+	if s.Default == "" {
+		s.Default = s.Fields[0].Name
+	}
+	if s.Field(s.Default) == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Default field '%s' is not in the index schema\n", s.Default)
+		return nil
+	}
+	s.Default = s.Field(s.Default).Name
+
+	return s
+}
+
+// LoadIndexSchema reads an index configuration file
+func LoadIndexSchema(fname string) *IndexSchema {
+
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to read index schema '%s'\n", fname)
+		return nil
+	}
+
+	return ParseIndexSchema(string(data))
+}
+
+var (
+	schemaLock  sync.Mutex
+	schemaCache = make(map[string]*IndexSchema)
+)
+
+// ReadIndexSchema returns the schema saved in a postings directory, or the PubMed default if there is none
+func ReadIndexSchema(prom string) *IndexSchema {
+
+	schemaLock.Lock()
+	defer schemaLock.Unlock()
+
+	if s, ok := schemaCache[prom]; ok {
+		return s
+	}
+
+	s := DefaultIndexSchema()
+
+	fpath := path.Join(prom, "schema.xml")
+	if _, err := os.Stat(fpath); err == nil {
+		s = LoadIndexSchema(fpath)
+		if s == nil {
+			os.Exit(1)
+		}
+	}
+
+	s.prom = prom
+	schemaCache[prom] = s
+
+	return s
+}
+
+// WriteIndexSchema records the schema in a postings directory for use by phrase search
+func WriteIndexSchema(prom string, s *IndexSchema) bool {
+
+	err := os.MkdirAll(prom, os.ModePerm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return false
+	}
+
+	err = os.WriteFile(path.Join(prom, "schema.xml"), []byte(s.String()), 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return false
+	}
+
+	schemaLock.Lock()
+	s.prom = prom
+	schemaCache[prom] = s
+	schemaLock.Unlock()
+
+	return true
+}
+
+// splitSchemaPath separates Parent/Element@attribute into its components
+func splitSchemaPath(str string) (string, string, string) {
+
+	prnt, match := SplitInTwoRight(str, "/")
+	match, attrib := SplitInTwoLeft(match, "@")
+
+	return prnt, match, attrib
+}
+
+// indexWords breaks one element value into normalized words in the manner of xtract -indices,
+// returning the updated cumulative word position padded to separate paragraphs
+func indexWords(str string, cumulative int, proc func(string, int)) int {
+
+	if str == "" || str == "[Not Available]." {
+		return cumulative
+	}
+
+	if IsNotASCII(str) {
+		str = DoAccentTransform(str)
+		if HasUnicodeMarkup(str) {
+			str = RepairUnicodeMarkup(str, SPACE)
+		}
+	}
+
+	str = strings.ToLower(str)
+
+	if HasBadSpace(str) {
+		str = CleanupBadSpaces(str)
+	}
+	if HasAngleBracket(str) {
+		str = RepairEncodedMarkup(str)
+		str = RepairTableMarkup(str, SPACE)
+		str = RepairScriptMarkup(str, SPACE)
+		str = RepairMathMLMarkup(str, SPACE)
+		str = RemoveEmbeddedMarkup(str)
+	}
+
+	if HasAmpOrNotASCII(str) {
+		str = html.UnescapeString(str)
+		str = strings.ToLower(str)
+	}
+
+	if IsNotASCII(str) {
+		if HasGreek(str) {
+			str = SpellGreek(str)
+			str = CompressRunsOfSpaces(str)
+		}
+	}
+
+	str = strings.Replace(str, "(", " ", -1)
+	str = strings.Replace(str, ")", " ", -1)
+
+	str = strings.Replace(str, "_", " ", -1)
+
+	if HasHyphenOrApostrophe(str) {
+		str = FixSpecialCases(str)
+	}
+
+	str = strings.Replace(str, "-", " ", -1)
+
+	// remove trailing punctuation from each word
+	var arry []string
+
+	for _, item := range strings.Fields(str) {
+		item = strings.TrimRight(item, ".,:;")
+		if item == "" {
+			continue
+		}
+		arry = append(arry, item)
+	}
+
+	cleaned := strings.Join(arry, " ")
+
+	// break clauses at punctuation other than space or underscore, and at non-ASCII characters
+	clauses := strings.FieldsFunc(cleaned, func(c rune) bool {
+		return (!unicode.IsLetter(c) && !unicode.IsDigit(c)) && c != ' ' && c != '_' || c > 127
+	})
+
+	words := strings.Fields(strings.Join(clauses, " "))
+
+	for _, item := range words {
+		cumulative++
+		proc(item, cumulative)
+	}
+
+	// pad to avoid false positive proximity match of words in adjacent paragraphs
+	rounded := ((cumulative + 99) / 100) * 100
+	if rounded-cumulative < 20 {
+		rounded += 100
+	}
+
+	return rounded
+}
+
+// indexSchemaRecord creates an IdxDocument object from one XML record
+func indexSchemaRecord(s *IndexSchema, text string) string {
+
+	pat := ParseRecord(text, s.Pattern)
+	if pat == nil {
+		return ""
+	}
+
+	explore := func(pth string, unescape bool, proc func(string)) {
+		prnt, match, attrib := splitSchemaPath(pth)
+		ExploreElements(pat, "", prnt, match, attrib, false, unescape, 1, func(str string, lvl int) {
+			proc(str)
+		})
+	}
+
+	uid := ""
+	for _, pth := range strings.Split(s.Identifier, ",") {
+		explore(pth, true, func(str string) {
+			if uid == "" {
+				uid = strings.TrimSpace(str)
+			}
+		})
+	}
+	if uid == "" {
+		return ""
+	}
+
+	var buffer strings.Builder
+
+	buffer.WriteString("  <IdxDocument>\n")
+	buffer.WriteString("    <IdxUid>")
+	buffer.WriteString(html.EscapeString(uid))
+	buffer.WriteString("</IdxUid>\n")
+	buffer.WriteString("    <IdxSearchFields>\n")
+
+	for _, fld := range s.Fields {
+
+		terms := make(map[string][]string)
+
+		addItem := func(term string, position int) {
+			arry := terms[term]
+			if position > 0 {
+				arry = append(arry, strconv.Itoa(position))
+			}
+			terms[term] = arry
+		}
+
+		cumulative := 0
+
+		for _, pth := range fld.Paths {
+			switch fld.Tokenizer {
+			case "words":
+				explore(pth, false, func(str string) {
+					cumulative = indexWords(str, cumulative, func(item string, pos int) {
+						// skip terms that are all digits, and optionally stop words
+						if IsAllDigitsOrPeriod(item) || (fld.Stop && IsStopWord(item)) {
+							return
+						}
+						if fld.Stem {
+							item = strings.TrimSpace(porter2.Stem(item))
+						}
+						if !fld.Positions {
+							pos = 0
+						}
+						addItem(item, pos)
+					})
+				})
+			case "terms":
+				explore(pth, true, func(str string) {
+					str = strings.ToLower(CompressRunsOfSpaces(strings.TrimSpace(str)))
+					if fld.Facet == "branch" {
+						// protect code components, as in the MeSH tree index
+						str = strings.Replace(str, ".", "_", -1)
+					}
+					if str != "" {
+						addItem(html.EscapeString(str), 0)
+					}
+				})
+			case "year":
+				explore(pth, true, func(str string) {
+					if len(terms) > 0 {
+						// only record first year, e.g., PubDate/MedlineDate "2008 Dec-2009 Jan"
+						return
+					}
+					for _, item := range strings.FieldsFunc(str, func(c rune) bool { return !unicode.IsDigit(c) }) {
+						if len(item) == 4 {
+							addItem(item, 0)
+							break
+						}
+					}
+				})
+			}
+		}
+
+		var arry []string
+		for item := range terms {
+			arry = append(arry, item)
+		}
+		sort.Strings(arry)
+
+		for _, item := range arry {
+			buffer.WriteString("      <")
+			buffer.WriteString(fld.Name)
+			if len(terms[item]) > 0 {
+				buffer.WriteString(" pos=\"")
+				buffer.WriteString(strings.Join(terms[item], ","))
+				buffer.WriteString("\"")
+			}
+			buffer.WriteString(">")
+			buffer.WriteString(item)
+			buffer.WriteString("</")
+			buffer.WriteString(fld.Name)
+			buffer.WriteString(">\n")
+		}
+	}
+
+	buffer.WriteString("    </IdxSearchFields>\n")
+	buffer.WriteString("  </IdxDocument>")
+
+	return buffer.String()
+}
+
+// CreateSchemaIndexers converts XML records to IdxDocument objects using the fields in an index schema
+func CreateSchemaIndexers(s *IndexSchema, inp <-chan XMLRecord) <-chan XMLRecord {
+
+	if s == nil || inp == nil {
+		return nil
+	}
+
+	out := make(chan XMLRecord, ChanDepth())
+	if out == nil {
+		fmt.Fprintf(os.Stderr, "\nERROR: Unable to create schema indexer channel\n")
+		os.Exit(1)
+	}
+
+	// xmlIndexer applies the schema to each record
+	xmlIndexer := func(wg *sync.WaitGroup, inp <-chan XMLRecord, out chan<- XMLRecord) {
+
+		defer wg.Done()
+
+		for ext := range inp {
+
+			str := indexSchemaRecord(s, ext.Text[:])
+
+			runtime.Gosched()
+
+			// empty results are still sent to keep the unshuffler in order
+			out <- XMLRecord{Index: ext.Index, Text: str}
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	// launch multiple indexer goroutines
+	for i := 0; i < NumServe(); i++ {
+		wg.Add(1)
+		go xmlIndexer(&wg, inp, out)
+	}
+
+	// launch separate anonymous goroutine to wait until all indexers are done
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
@@ -34,6 +34,8 @@ import (
 	"container/list"
 	"fmt"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
 	"unicode"
 )
@@ -51,6 +53,13 @@ import (
 // as described in Andrew Binstock and John Rex, Practical Algorithms for
 // Programmers, Addison-Wesley, 1995, p. 111.
 
+// Approximate searching with substitutions only uses the bit-parallel method
+// of Sun Wu and Udi Manber, Fast Text Searching Allowing Errors, Communications
+// of the ACM 35(10), 1992, p. 83.
+// Approximate searching with insertions and deletions uses the bit-vector
+// algorithm of Gene Myers, A Fast Bit-Vector Algorithm for Approximate String
+// Matching Based on Dynamic Programming, Journal of the ACM 46(3), 1999, p. 395.
+
 // Boyer-Moore-Horspool structure
 type bmhData struct {
 	skipTable [256]int
@@ -82,13 +91,44 @@ type fsmData struct {
 	maxpatlen  int
 }
 
-// Searcher contains private fields for Boyer-Moore-Horspool or Finite State Machine searching
+// Approximate matching structures
+type approxEntry struct {
+	pattern string
+	alias   string
+	minus   bool
+	peq     [256]uint64
+}
+
+type approxData struct {
+	approxPats  []approxEntry
+	maxErrors   int
+	allowIndels bool
+	approxLen   int
+}
+
+// Motif scanning structures
+type motifEntry struct {
+	expr *regexp.Regexp
+	// same expression after one consumed residue, so anchors do not match at a resumed offset
+	next  *regexp.Regexp
+	alias string
+}
+
+type motifData struct {
+	motifPats []motifEntry
+}
+
+// Searcher contains private fields for Boyer-Moore-Horspool, Finite State Machine, approximate, or motif searching
 type Searcher struct {
-	relaxed  bool
-	circular bool
-	useBMH   bool
+	relaxed   bool
+	circular  bool
+	useBMH    bool
+	useApprox bool
+	useMotif  bool
 	bmhData
 	fsmData
+	approxData
+	motifData
 }
 
 const failState = -1
@@ -596,10 +636,321 @@ func SequenceSearcher(patterns []string, isProtein, isCircular, topStrandOnly bo
 	return newSearcher(arry, false, false, true, isCircular, !topStrandOnly)
 }
 
-// SearchHit contains position and match or alias
+// maximum pattern length for single-word bit-parallel approximate matching
+const maxApproxLen = 64
+
+// protein ambiguity residues, X matches any amino acid
+var expandPrt = map[byte]string{
+	'B': "DN",
+	'J': "IL",
+	'Z': "EQ",
+}
+
+// approxCompatible returns true if every base or residue represented by the text
+// character is allowed by the pattern character, so an N in the text only matches
+// an N in the pattern, but an N in the pattern matches anything in the text
+func approxCompatible(pat, txt byte, isProtein bool) bool {
+
+	if pat >= 'a' && pat <= 'z' {
+		pat -= 'a' - 'A'
+	}
+	if txt >= 'a' && txt <= 'z' {
+		txt -= 'a' - 'A'
+	}
+
+	if pat == txt {
+		return true
+	}
+
+	if isProtein {
+		if pat == 'X' {
+			return txt >= 'A' && txt <= 'Z' && txt != 'X'
+		}
+		allowed, ok := expandPrt[pat]
+		if !ok {
+			return false
+		}
+		return strings.IndexByte(allowed, txt) >= 0
+	}
+
+	if pat == 'U' {
+		pat = 'T'
+	}
+	if txt == 'U' {
+		txt = 'T'
+	}
+
+	allowed, ok := expandNuc[string(pat)]
+	if !ok {
+		return pat == txt
+	}
+	bases, ok := expandNuc[string(txt)]
+	if !ok {
+		return false
+	}
+	for i := 0; i < len(bases); i++ {
+		if strings.IndexByte(allowed, bases[i]) < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ApproximateSearcher primes tables for mismatch-tolerant searching on one or more
+// nucleotide or protein sequences. Ambiguity characters are matched directly instead
+// of being expanded into instantiated patterns, so degenerate primers do not overflow.
+// If allowIndels is true, maxErrors is an edit distance, otherwise it is a count of
+// substitutions.
+func ApproximateSearcher(patterns []string, isProtein, isCircular, topStrandOnly bool, maxErrors int, allowIndels bool) *Searcher {
+
+	if patterns == nil {
+		return nil
+	}
+
+	if isProtein {
+		topStrandOnly = true
+	}
+
+	if maxErrors < 0 {
+		maxErrors = 0
+	}
+
+	var arry []approxEntry
+
+	alreadySeen := make(map[string]bool)
+
+	addEntry := func(pat, alias string, minus bool) {
+
+		var peq [256]uint64
+
+		for i := 0; i < len(pat); i++ {
+			for ch := 0; ch < 256; ch++ {
+				if approxCompatible(pat[i], byte(ch), isProtein) {
+					peq[ch] |= 1 << uint(i)
+				}
+			}
+		}
+
+		ae := approxEntry{pattern: pat, alias: alias, minus: minus, peq: peq}
+		arry = append(arry, ae)
+	}
+
+	maxpatlen := 0
+
+	for _, pat := range patterns {
+
+		// each pattern can optionally be followed by a colon and an alias
+		txt, alias := SplitInTwoLeft(pat, ":")
+
+		txt = strings.ToUpper(txt)
+
+		if txt == "" {
+			continue
+		}
+
+		if len(txt) > maxApproxLen {
+			fmt.Fprintf(os.Stderr, "ERROR: Ignoring pattern '%s' longer than %d characters\n", pat, maxApproxLen)
+			continue
+		}
+
+		if maxErrors >= len(txt) {
+			fmt.Fprintf(os.Stderr, "ERROR: Ignoring pattern '%s' not longer than number of allowed errors\n", pat)
+			continue
+		}
+
+		if alreadySeen[txt] {
+			continue
+		}
+		alreadySeen[txt] = true
+
+		if alias == "" || alias == "+" {
+			alias = txt
+		}
+
+		addEntry(txt, alias, false)
+
+		if !topStrandOnly {
+			// also search for non-palindromic reverse complement
+			rev := ReverseComplement(txt)
+			if rev != txt {
+				addEntry(rev, alias, true)
+			}
+		}
+
+		// track longest pattern, duplicate that much past end if circular molecule
+		if maxpatlen < len(txt) {
+			maxpatlen = len(txt)
+		}
+	}
+
+	if len(arry) < 1 {
+		return nil
+	}
+
+	return &Searcher{
+		circular:  isCircular,
+		useApprox: true,
+		approxData: approxData{
+			approxPats:  arry,
+			maxErrors:   maxErrors,
+			allowIndels: allowIndels,
+			approxLen:   maxpatlen,
+		},
+	}
+}
+
+// SearchHit contains position and match or alias, plus strand and number of
+// mismatches or edits for approximate searches, and matched length for approximate
+// and motif searches
 type SearchHit struct {
-	Point int
-	Match string
+	Point      int
+	Match      string
+	Strand     string
+	Mismatches int
+	Length     int
+}
+
+// approxStart finds the leftmost text position of an alignment of the full pattern
+// ending at a given position, using the reversed pattern and text on a small window
+func approxStart(ae *approxEntry, text string, end, dist int) int {
+
+	patlen := len(ae.pattern)
+
+	// window can hold at most pattern length plus allowed insertions
+	span := patlen + dist
+	if span > end+1 {
+		span = end + 1
+	}
+
+	prev := make([]int, span+1)
+	curr := make([]int, span+1)
+
+	for j := 0; j <= span; j++ {
+		prev[j] = j
+	}
+
+	for i := 1; i <= patlen; i++ {
+		mask := uint64(1) << uint(patlen-i)
+		curr[0] = i
+		for j := 1; j <= span; j++ {
+			cost := 1
+			if ae.peq[text[end-j+1]]&mask != 0 {
+				cost = 0
+			}
+			best := prev[j-1] + cost
+			if prev[j]+1 < best {
+				best = prev[j] + 1
+			}
+			if curr[j-1]+1 < best {
+				best = curr[j-1] + 1
+			}
+			curr[j] = best
+		}
+		prev, curr = curr, prev
+	}
+
+	// prefer the alignment span closest to the pattern length
+	best := -1
+	for j := 1; j <= span; j++ {
+		if prev[j] != dist {
+			continue
+		}
+		if best < 0 || abs(j-patlen) < abs(best-patlen) {
+			best = j
+		}
+	}
+	if best < 0 {
+		best = patlen
+	}
+
+	return end - best + 1
+}
+
+func abs(x int) int {
+
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// approxSearch runs the bit-parallel scan for one pattern, sending the start
+// position, matched length, and error count of each hit to the callback
+func approxSearch(ae *approxEntry, text string, maxErrors int, allowIndels bool, proc func(start, end, errs int)) {
+
+	patlen := len(ae.pattern)
+	high := uint64(1) << uint(patlen-1)
+
+	if !allowIndels {
+
+		// Wu-Manber shift-and, R[j] holds prefixes matched with at most j substitutions
+		r := make([]uint64, maxErrors+1)
+
+		for pos := 0; pos < len(text); pos++ {
+			eq := ae.peq[text[pos]]
+			prev := r[0]
+			r[0] = ((r[0] << 1) | 1) & eq
+			for j := 1; j <= maxErrors; j++ {
+				old := r[j]
+				r[j] = (((r[j] << 1) | 1) & eq) | ((prev << 1) | 1)
+				prev = old
+			}
+			for j := 0; j <= maxErrors; j++ {
+				if r[j]&high != 0 {
+					proc(pos-patlen+1, pos, j)
+					break
+				}
+			}
+		}
+
+		return
+	}
+
+	// Myers bit-vector, score is edit distance of best alignment ending at current position
+	pv := ^uint64(0)
+	mv := uint64(0)
+	score := patlen
+
+	// report only the best end position in each run of acceptable scores
+	runEnd := -1
+	runScore := 0
+
+	flush := func() {
+		if runEnd >= 0 {
+			start := approxStart(ae, text, runEnd, runScore)
+			proc(start, runEnd, runScore)
+			runEnd = -1
+		}
+	}
+
+	for pos := 0; pos < len(text); pos++ {
+		eq := ae.peq[text[pos]]
+		xv := eq | mv
+		xh := (((eq & pv) + pv) ^ pv) | eq
+		ph := mv | ^(xh | pv)
+		mh := pv & xh
+		if ph&high != 0 {
+			score++
+		} else if mh&high != 0 {
+			score--
+		}
+		ph <<= 1
+		mh <<= 1
+		pv = mh | ^(xv | ph)
+		mv = ph & xv
+
+		if score <= maxErrors {
+			if runEnd < 0 || score < runScore {
+				runEnd = pos
+				runScore = score
+			}
+		} else {
+			flush()
+		}
+	}
+
+	flush()
 }
 
 // Search uses precomputed Searcher tables to search a string or sequence.
@@ -620,6 +971,99 @@ func (srch *Searcher) Search(text string) []SearchHit {
 		text = RelaxString(text)
 	}
 
+	if srch.useApprox {
+		// bit-parallel search allowing mismatches, or insertions and deletions
+
+		cutoff := len(text)
+
+		if cutoff < srch.approxLen-srch.maxErrors {
+			fmt.Fprintf(os.Stderr, "ERROR: Search text is shorter than pattern\n")
+			return nil
+		}
+
+		if srch.circular {
+			// for circular DNA molecule, copy initial characters and add them to the end of the text
+			ovr := srch.approxLen + srch.maxErrors
+			if ovr > cutoff {
+				ovr = cutoff
+			}
+			overhang := text[:ovr]
+			text += overhang
+		}
+
+		for i := range srch.approxPats {
+
+			ae := &srch.approxPats[i]
+
+			strand := "+"
+			if ae.minus {
+				strand = "-"
+			}
+
+			approxSearch(ae, text, srch.maxErrors, srch.allowIndels, func(start, end, errs int) {
+
+				// send result if not past end of original text
+				if start < 0 || start >= cutoff {
+					return
+				}
+
+				alias := ae.alias
+				if alias == "-" {
+					// ACGTNNAC:- prints ACGTNNAC-ACGTTAAC, etc.
+					alias = ae.pattern + "-" + strings.ToUpper(text[start:end+1])
+					if ae.minus {
+						alias = ReverseComplement(ae.pattern) + "-" + strings.ToUpper(ReverseComplement(text[start:end+1]))
+					}
+				}
+
+				hit := SearchHit{Point: start, Match: alias, Strand: strand, Mismatches: errs, Length: end - start + 1}
+				arry = append(arry, hit)
+			})
+		}
+
+		// merge results from separate pattern scans into sequence order
+		sort.SliceStable(arry, func(i, j int) bool { return arry[i].Point < arry[j].Point })
+
+		return arry
+	}
+
+	if srch.useMotif {
+		// PROSITE patterns or regular expressions, reports overlapping matches
+
+		text = strings.ToUpper(text)
+
+		for _, me := range srch.motifPats {
+
+			for pos := 0; pos < len(text); {
+
+				var loc []int
+				if pos == 0 {
+					loc = me.expr.FindStringIndex(text)
+				} else if sub := me.next.FindStringSubmatchIndex(text[pos-1:]); sub != nil {
+					// previous residue stays in the searched text, position of the motif is in the first group
+					loc = []int{sub[2] - 1, sub[3] - 1}
+				}
+				if loc == nil {
+					break
+				}
+
+				start := pos + loc[0]
+				if loc[1] > loc[0] {
+					// zero-length matches, e.g., from "X*", do not cover any residue
+					hit := SearchHit{Point: start, Match: me.alias, Length: loc[1] - loc[0]}
+					arry = append(arry, hit)
+				}
+
+				// resume search one residue past start of previous match
+				pos = start + 1
+			}
+		}
+
+		sort.SliceStable(arry, func(i, j int) bool { return arry[i].Point < arry[j].Point })
+
+		return arry
+	}
+
 	if srch.useBMH {
 		// single pattern, use Boyer-Moore-Horspool
 
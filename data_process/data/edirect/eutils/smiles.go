@@ -0,0 +1,724 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  smiles.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SMILES parsing follows the OpenSMILES specification, with implicit hydrogens assigned to
+// organic subset atoms from their lowest normal valence consistent with explicit bonds
+
+// MolAtom is a single atom, with Hydrogens holding implicit or bracketed hydrogen count
+type MolAtom struct {
+	Element   string
+	Aromatic  bool
+	Bracket   bool
+	Isotope   int
+	Charge    int
+	Hydrogens int
+}
+
+// MolBond connects two atoms by index, with Order 4 representing an aromatic bond and 5 a quadruple bond
+type MolBond struct {
+	From  int
+	To    int
+	Order int
+}
+
+// Molecule holds the connection table from a SMILES string
+type Molecule struct {
+	Atoms []MolAtom
+	Bonds []MolBond
+}
+
+// standard atomic weights and most abundant isotope masses
+type elementMass struct {
+	Average  float64
+	Mono     float64
+	Abundant int
+	Valences []int
+}
+
+var elementMasses = map[string]elementMass{
+	"H":  {1.008, 1.00782503207, 1, nil},
+	"He": {4.002602, 4.00260325415, 4, nil},
+	"Li": {6.94, 7.0160045, 7, nil},
+	"Be": {9.0121831, 9.0121822, 9, nil},
+	"B":  {10.81, 11.0093054, 11, []int{3}},
+	"C":  {12.011, 12.0, 12, []int{4}},
+	"N":  {14.007, 14.0030740048, 14, []int{3, 5}},
+	"O":  {15.999, 15.99491461956, 16, []int{2}},
+	"F":  {18.998403163, 18.99840322, 19, []int{1}},
+	"Ne": {20.1797, 19.9924401754, 20, nil},
+	"Na": {22.98976928, 22.9897692809, 23, nil},
+	"Mg": {24.305, 23.985041700, 24, nil},
+	"Al": {26.9815385, 26.98153863, 27, nil},
+	"Si": {28.085, 27.9769265325, 28, nil},
+	"P":  {30.973761998, 30.97376163, 31, []int{3, 5}},
+	"S":  {32.06, 31.97207100, 32, []int{2, 4, 6}},
+	"Cl": {35.45, 34.96885268, 35, []int{1}},
+	"Ar": {39.948, 39.9623831225, 40, nil},
+	"K":  {39.0983, 38.96370668, 39, nil},
+	"Ca": {40.078, 39.96259098, 40, nil},
+	"Mn": {54.938044, 54.9380451, 55, nil},
+	"Fe": {55.845, 55.9349375, 56, nil},
+	"Co": {58.933194, 58.9331950, 59, nil},
+	"Ni": {58.6934, 57.9353429, 58, nil},
+	"Cu": {63.546, 62.9295975, 63, nil},
+	"Zn": {65.38, 63.9291422, 64, nil},
+	"Ga": {69.723, 68.9255736, 69, nil},
+	"Ge": {72.630, 73.9211778, 74, nil},
+	"As": {74.921595, 74.9215965, 75, nil},
+	"Se": {78.971, 79.9165213, 80, nil},
+	"Br": {79.904, 78.9183371, 79, []int{1}},
+	"Kr": {83.798, 83.911507, 84, nil},
+	"Rb": {85.4678, 84.911789738, 85, nil},
+	"Sr": {87.62, 87.9056121, 88, nil},
+	"Mo": {95.95, 97.9054082, 98, nil},
+	"Ag": {107.8682, 106.905097, 107, nil},
+	"Cd": {112.414, 113.9033585, 114, nil},
+	"Sn": {118.710, 119.9021947, 120, nil},
+	"Sb": {121.760, 120.9038157, 121, nil},
+	"Te": {127.60, 129.9062244, 130, nil},
+	"I":  {126.90447, 126.904473, 127, []int{1}},
+	"Xe": {131.293, 131.9041535, 132, nil},
+	"Cs": {132.90545196, 132.905451933, 133, nil},
+	"Ba": {137.327, 137.9052472, 138, nil},
+	"Gd": {157.25, 157.9241039, 158, nil},
+	"Pt": {195.084, 194.9647911, 195, nil},
+	"Au": {196.966569, 196.9665687, 197, nil},
+	"Hg": {200.592, 201.970643, 202, nil},
+	"Tl": {204.38, 204.9744275, 205, nil},
+	"Pb": {207.2, 207.9766521, 208, nil},
+	"Bi": {208.98040, 208.9803987, 209, nil},
+}
+
+// exact masses of isotopes commonly used in labeled compounds
+var isotopeMasses = map[string]float64{
+	"H2":   2.0141017778,
+	"H3":   3.0160492777,
+	"C11":  11.0114336,
+	"C13":  13.0033548378,
+	"C14":  14.003241989,
+	"N15":  15.0001088982,
+	"O17":  16.99913170,
+	"O18":  17.9991610,
+	"F18":  18.0009380,
+	"P32":  31.97390727,
+	"S34":  33.96786690,
+	"S35":  34.96903216,
+	"Cl37": 36.96590259,
+	"Br81": 80.9162906,
+	"I123": 122.905589,
+	"I125": 124.9046302,
+	"I131": 130.9061246,
+}
+
+// organic subset atoms that may appear outside of brackets
+var smilesOrganic = []string{"Cl", "Br", "B", "C", "N", "O", "P", "S", "F", "I", "b", "c", "n", "o", "p", "s"}
+
+// ParseSMILES reads a SMILES string into a connection table
+func ParseSMILES(str string) *Molecule {
+
+	smilesError := func(msg string, pos int) *Molecule {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s %s at position %d in SMILES '%s'%s\n", INVT, LOUD, msg, pos+1, str, INIT)
+		return nil
+	}
+
+	mol := &Molecule{}
+
+	// index of previous atom, -1 after dot or at start
+	prev := -1
+	// pending bond order, 0 for default
+	order := 0
+	var branches []int
+	// ring closure digit to opening atom and bond order
+	type ringOpen struct {
+		atom  int
+		order int
+	}
+	rings := make(map[int]ringOpen)
+
+	defaultOrder := func(a1, a2 int) int {
+		if mol.Atoms[a1].Aromatic && mol.Atoms[a2].Aromatic {
+			return 4
+		}
+		return 1
+	}
+
+	addAtom := func(atm MolAtom) {
+		idx := len(mol.Atoms)
+		mol.Atoms = append(mol.Atoms, atm)
+		if prev >= 0 {
+			ord := order
+			if ord == 0 {
+				ord = defaultOrder(prev, idx)
+			}
+			mol.Bonds = append(mol.Bonds, MolBond{From: prev, To: idx, Order: ord})
+		}
+		prev = idx
+		order = 0
+	}
+
+	i := 0
+	for i < len(str) {
+
+		ch := str[i]
+
+		switch {
+		case ch == '(':
+			if prev < 0 {
+				return smilesError("Branch without preceding atom", i)
+			}
+			branches = append(branches, prev)
+			i++
+		case ch == ')':
+			if len(branches) < 1 {
+				return smilesError("Unbalanced parenthesis", i)
+			}
+			prev = branches[len(branches)-1]
+			branches = branches[:len(branches)-1]
+			order = 0
+			i++
+		case ch == '.':
+			prev = -1
+			order = 0
+			i++
+		case ch == '-' || ch == '/' || ch == '\\':
+			order = 1
+			i++
+		case ch == '=':
+			order = 2
+			i++
+		case ch == '#':
+			order = 3
+			i++
+		case ch == '$':
+			// quadruple bond is stored as order 5, since order 4 marks aromatic bonds
+			order = 5
+			i++
+		case ch == ':':
+			order = 4
+			i++
+		case ch == '%' || (ch >= '0' && ch <= '9'):
+			if prev < 0 {
+				return smilesError("Ring closure without preceding atom", i)
+			}
+			num := 0
+			if ch == '%' {
+				if i+2 >= len(str) || str[i+1] < '0' || str[i+1] > '9' || str[i+2] < '0' || str[i+2] > '9' {
+					return smilesError("Expected two digits after %", i)
+				}
+				num, _ = strconv.Atoi(str[i+1 : i+3])
+				i += 3
+			} else {
+				num = int(ch - '0')
+				i++
+			}
+			opn, ok := rings[num]
+			if !ok {
+				rings[num] = ringOpen{atom: prev, order: order}
+				order = 0
+				continue
+			}
+			delete(rings, num)
+			if opn.atom == prev {
+				return smilesError("Ring closure to same atom", i-1)
+			}
+			ord := order
+			if ord == 0 {
+				ord = opn.order
+			}
+			if ord == 0 {
+				ord = defaultOrder(opn.atom, prev)
+			}
+			mol.Bonds = append(mol.Bonds, MolBond{From: opn.atom, To: prev, Order: ord})
+			order = 0
+		case ch == '[':
+			end := strings.IndexByte(str[i:], ']')
+			if end < 0 {
+				return smilesError("Unterminated bracket atom", i)
+			}
+			atm, ok := parseBracketAtom(str[i+1 : i+end])
+			if !ok {
+				return smilesError("Unrecognized bracket atom", i)
+			}
+			addAtom(atm)
+			i += end + 1
+		case ch == '*':
+			addAtom(MolAtom{Element: "*", Hydrogens: -1})
+			i++
+		default:
+			matched := ""
+			for _, sym := range smilesOrganic {
+				if strings.HasPrefix(str[i:], sym) {
+					matched = sym
+					break
+				}
+			}
+			if matched == "" {
+				return smilesError("Unexpected character", i)
+			}
+			atm := MolAtom{Element: matched, Hydrogens: -1}
+			if matched[0] >= 'a' && matched[0] <= 'z' {
+				atm.Aromatic = true
+				atm.Element = strings.ToUpper(matched)
+			}
+			addAtom(atm)
+			i += len(matched)
+		}
+	}
+
+	if len(branches) > 0 {
+		return smilesError("Unclosed branch", len(str)-1)
+	}
+	if len(rings) > 0 {
+		return smilesError("Unclosed ring", len(str)-1)
+	}
+	if len(mol.Atoms) < 1 {
+		return smilesError("No atoms", 0)
+	}
+
+	mol.assignImplicitHydrogens()
+
+	return mol
+}
+
+// parseBracketAtom handles isotope, symbol, chirality, hydrogen count, charge, and atom class
+func parseBracketAtom(str string) (MolAtom, bool) {
+
+	atm := MolAtom{}
+
+	i := 0
+	for i < len(str) && str[i] >= '0' && str[i] <= '9' {
+		atm.Isotope = atm.Isotope*10 + int(str[i]-'0')
+		i++
+	}
+
+	if i >= len(str) {
+		return atm, false
+	}
+
+	// element symbol, with lower-case aromatic forms
+	switch {
+	case str[i] == '*':
+		atm.Element = "*"
+		i++
+	case str[i] >= 'A' && str[i] <= 'Z':
+		sym := str[i : i+1]
+		if i+1 < len(str) && str[i+1] >= 'a' && str[i+1] <= 'z' {
+			if _, ok := elementMasses[str[i:i+2]]; ok {
+				sym = str[i : i+2]
+			}
+		}
+		if _, ok := elementMasses[sym]; !ok {
+			return atm, false
+		}
+		atm.Element = sym
+		i += len(sym)
+	case str[i] >= 'a' && str[i] <= 'z':
+		sym := ""
+		for _, cand := range []string{"se", "as", "te", "b", "c", "n", "o", "p", "s"} {
+			if strings.HasPrefix(str[i:], cand) {
+				sym = cand
+				break
+			}
+		}
+		if sym == "" {
+			return atm, false
+		}
+		atm.Element = strings.ToUpper(sym[:1]) + sym[1:]
+		atm.Aromatic = true
+		i += len(sym)
+	default:
+		return atm, false
+	}
+
+	// skip chirality, e.g., @, @@, @TH1, @OH12
+	if i < len(str) && str[i] == '@' {
+		i++
+		if i < len(str) && str[i] == '@' {
+			i++
+		}
+		for i < len(str) && str[i] >= 'A' && str[i] <= 'Z' && str[i] != 'H' {
+			i++
+		}
+		for i < len(str) && str[i] >= '0' && str[i] <= '9' {
+			i++
+		}
+	}
+
+	// hydrogen count
+	if i < len(str) && str[i] == 'H' {
+		i++
+		atm.Hydrogens = 1
+		if i < len(str) && str[i] >= '0' && str[i] <= '9' {
+			atm.Hydrogens = int(str[i] - '0')
+			i++
+		}
+	}
+
+	// charge as +, ++, +2, -, --, -3
+	if i < len(str) && (str[i] == '+' || str[i] == '-') {
+		sign := 1
+		if str[i] == '-' {
+			sign = -1
+		}
+		sym := str[i]
+		i++
+		num := 1
+		if i < len(str) && str[i] >= '0' && str[i] <= '9' {
+			num = 0
+			for i < len(str) && str[i] >= '0' && str[i] <= '9' {
+				num = num*10 + int(str[i]-'0')
+				i++
+			}
+		} else {
+			for i < len(str) && str[i] == sym {
+				num++
+				i++
+			}
+		}
+		atm.Charge = sign * num
+	}
+
+	// ignore atom class
+	if i < len(str) && str[i] == ':' {
+		i++
+		for i < len(str) && str[i] >= '0' && str[i] <= '9' {
+			i++
+		}
+	}
+
+	if i != len(str) {
+		return atm, false
+	}
+
+	atm.Bracket = true
+
+	return atm, true
+}
+
+// assignImplicitHydrogens fills organic subset atoms to the lowest normal valence, with aromatic
+// atoms reserving one bond for the delocalized pi system when their valence allows it
+func (mol *Molecule) assignImplicitHydrogens() {
+
+	bonded := make([]int, len(mol.Atoms))
+	for _, bnd := range mol.Bonds {
+		val := bnd.Order
+		if val == 4 {
+			val = 1
+		} else if val == 5 {
+			val = 4
+		}
+		bonded[bnd.From] += val
+		bonded[bnd.To] += val
+	}
+
+	for i := range mol.Atoms {
+		atm := &mol.Atoms[i]
+		if atm.Bracket {
+			continue
+		}
+		em, ok := elementMasses[atm.Element]
+		if !ok || len(em.Valences) < 1 {
+			atm.Hydrogens = 0
+			continue
+		}
+		sum := bonded[i]
+		atm.Hydrogens = 0
+		if atm.Aromatic {
+			val := em.Valences[0]
+			if sum+1 <= val {
+				atm.Hydrogens = val - sum - 1
+			}
+			continue
+		}
+		for _, val := range em.Valences {
+			if val >= sum {
+				atm.Hydrogens = val - sum
+				break
+			}
+		}
+	}
+}
+
+// atomMass returns the average and monoisotopic mass of an atom, excluding attached hydrogens
+func atomMass(atm MolAtom) (float64, float64) {
+
+	em := elementMasses[atm.Element]
+	if atm.Isotope == 0 {
+		return em.Average, em.Mono
+	}
+
+	exact, ok := isotopeMasses[atm.Element+strconv.Itoa(atm.Isotope)]
+	if !ok {
+		if atm.Isotope == em.Abundant {
+			exact = em.Mono
+		} else {
+			exact = float64(atm.Isotope)
+		}
+	}
+
+	// labeled atoms contribute their isotopic mass to both totals
+	return exact, exact
+}
+
+// elementCounts tallies atoms, including implicit and bracketed hydrogens, by element symbol
+func (mol *Molecule) elementCounts() map[string]int {
+
+	counts := make(map[string]int)
+
+	for _, atm := range mol.Atoms {
+		counts[atm.Element]++
+		if atm.Hydrogens > 0 {
+			counts["H"] += atm.Hydrogens
+		}
+	}
+
+	return counts
+}
+
+// Formula returns the molecular formula in Hill order, followed by any net charge
+func (mol *Molecule) Formula() string {
+
+	counts := mol.elementCounts()
+
+	var syms []string
+	for sym := range counts {
+		if sym == "*" {
+			continue
+		}
+		syms = append(syms, sym)
+	}
+
+	_, hasC := counts["C"]
+	rank := func(sym string) int {
+		if hasC && sym == "C" {
+			return 0
+		}
+		if hasC && sym == "H" {
+			return 1
+		}
+		return 2
+	}
+	sort.Slice(syms, func(i, j int) bool {
+		ri, rj := rank(syms[i]), rank(syms[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return syms[i] < syms[j]
+	})
+
+	var buffer strings.Builder
+
+	for _, sym := range syms {
+		buffer.WriteString(sym)
+		if counts[sym] > 1 {
+			buffer.WriteString(strconv.Itoa(counts[sym]))
+		}
+	}
+
+	chg := mol.Charge()
+	if chg > 0 {
+		buffer.WriteString("+")
+	} else if chg < 0 {
+		buffer.WriteString("-")
+	}
+	if chg > 1 || chg < -1 {
+		if chg < 0 {
+			chg = -chg
+		}
+		buffer.WriteString(strconv.Itoa(chg))
+	}
+
+	return buffer.String()
+}
+
+// Charge returns the net formal charge
+func (mol *Molecule) Charge() int {
+
+	chg := 0
+	for _, atm := range mol.Atoms {
+		chg += atm.Charge
+	}
+
+	return chg
+}
+
+// Masses returns the average molecular weight and the monoisotopic mass
+func (mol *Molecule) Masses() (float64, float64) {
+
+	avg := 0.0
+	mono := 0.0
+
+	hyd := elementMasses["H"]
+
+	for _, atm := range mol.Atoms {
+		av, mo := atomMass(atm)
+		avg += av
+		mono += mo
+		if atm.Hydrogens > 0 {
+			avg += float64(atm.Hydrogens) * hyd.Average
+			mono += float64(atm.Hydrogens) * hyd.Mono
+		}
+	}
+
+	return avg, mono
+}
+
+// HeavyAtoms counts non-hydrogen atoms
+func (mol *Molecule) HeavyAtoms() int {
+
+	num := 0
+	for _, atm := range mol.Atoms {
+		if atm.Element != "H" {
+			num++
+		}
+	}
+
+	return num
+}
+
+// components returns the number of disconnected fragments
+func (mol *Molecule) components() int {
+
+	parent := make([]int, len(mol.Atoms))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(int) int
+	find = func(x int) int {
+		for parent[x] != x {
+			parent[x] = parent[parent[x]]
+			x = parent[x]
+		}
+		return x
+	}
+
+	num := len(mol.Atoms)
+	for _, bnd := range mol.Bonds {
+		a, b := find(bnd.From), find(bnd.To)
+		if a != b {
+			parent[a] = b
+			num--
+		}
+	}
+
+	return num
+}
+
+// Rings returns the size of the smallest set of smallest rings, from the cyclomatic number
+func (mol *Molecule) Rings() int {
+
+	return len(mol.Bonds) - len(mol.Atoms) + mol.components()
+}
+
+// ringBonds flags bonds whose removal leaves their atoms connected
+func (mol *Molecule) ringBonds() []bool {
+
+	adj := make([][]int, len(mol.Atoms))
+	for k, bnd := range mol.Bonds {
+		adj[bnd.From] = append(adj[bnd.From], k)
+		adj[bnd.To] = append(adj[bnd.To], k)
+	}
+
+	inRing := make([]bool, len(mol.Bonds))
+
+	for k, bnd := range mol.Bonds {
+		seen := make([]bool, len(mol.Atoms))
+		stack := []int{bnd.From}
+		seen[bnd.From] = true
+		for len(stack) > 0 && !seen[bnd.To] {
+			cur := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			for _, b := range adj[cur] {
+				if b == k {
+					continue
+				}
+				nxt := mol.Bonds[b].From
+				if nxt == cur {
+					nxt = mol.Bonds[b].To
+				}
+				if !seen[nxt] {
+					seen[nxt] = true
+					stack = append(stack, nxt)
+				}
+			}
+		}
+		inRing[k] = seen[bnd.To]
+	}
+
+	return inRing
+}
+
+// RotatableBonds counts acyclic single bonds between non-terminal heavy atoms, excluding bonds
+// to triple-bonded atoms and amide C-N bonds
+func (mol *Molecule) RotatableBonds() int {
+
+	heavy := make([]int, len(mol.Atoms))
+	triple := make([]bool, len(mol.Atoms))
+	carbonyl := make([]bool, len(mol.Atoms))
+
+	for _, bnd := range mol.Bonds {
+		if mol.Atoms[bnd.From].Element != "H" && mol.Atoms[bnd.To].Element != "H" {
+			heavy[bnd.From]++
+			heavy[bnd.To]++
+		}
+		if bnd.Order == 3 {
+			triple[bnd.From] = true
+			triple[bnd.To] = true
+		}
+		if bnd.Order == 2 {
+			if mol.Atoms[bnd.From].Element == "C" && mol.Atoms[bnd.To].Element == "O" {
+				carbonyl[bnd.From] = true
+			} else if mol.Atoms[bnd.To].Element == "C" && mol.Atoms[bnd.From].Element == "O" {
+				carbonyl[bnd.To] = true
+			}
+		}
+	}
+
+	isAmide := func(a1, a2 int) bool {
+		return carbonyl[a1] && mol.Atoms[a2].Element == "N" && !mol.Atoms[a2].Aromatic
+	}
+
+	inRing := mol.ringBonds()
+
+	num := 0
+	for k, bnd := range mol.Bonds {
+		if bnd.Order != 1 || inRing[k] {
+			continue
+		}
+		a, b := bnd.From, bnd.To
+		if heavy[a] < 2 || heavy[b] < 2 || triple[a] || triple[b] {
+			continue
+		}
+		if isAmide(a, b) || isAmide(b, a) {
+			continue
+		}
+		num++
+	}
+
+	return num
+}
@@ -216,65 +216,32 @@ func SequenceExtract(seq, featLoc string, isOneBased bool) string {
 		return ""
 	}
 
-	ln := len(seq)
-
-	// split intervals, e.g., "201..224,1550..1920,1986..2085,2317..2404,2466..2629"
-	comma := strings.Split(featLoc, ",")
-
-	var buffer strings.Builder
-
-	for _, item := range comma {
-
-		// also allow dash separator, e.g., "201-224,1550-1920"
-		item = strings.Replace(item, "-", "..", -1)
-		// and colon separator, e.g., "201:224,1550:1920"
-		item = strings.Replace(item, ":", "..", -1)
-
-		fr, to := SplitInTwoLeft(item, "..")
-
-		fr = strings.TrimSpace(fr)
-		to = strings.TrimSpace(to)
-
-		min, err := strconv.Atoi(fr)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s ERROR: %s Unrecognized number '%s'%s\n", INVT, LOUD, fr, INIT)
-			os.Exit(1)
-		}
-		if min < 1 || min > ln {
-			fmt.Fprintf(os.Stderr, "%s ERROR: %s Starting point '%s' out of range%s\n", INVT, LOUD, fr, INIT)
-			os.Exit(1)
-		}
+	// simple interval lists, e.g., "201..224,1550..1920,1986..2085,2317..2404,2466..2629",
+	// also allow dash separator, e.g., "201-224,1550-1920", and colon separator, e.g., "201:224,1550:1920"
+	if strings.Trim(featLoc, "0123456789.,-: ") == "" {
+		featLoc = strings.Replace(featLoc, "-", "..", -1)
+		featLoc = strings.Replace(featLoc, ":", "..", -1)
+	}
 
-		max, err := strconv.Atoi(to)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s ERROR: %s Unrecognized number '%s'%s\n", INVT, LOUD, to, INIT)
-			os.Exit(1)
-		}
-		if max < 1 || max > ln {
-			fmt.Fprintf(os.Stderr, "%s ERROR: %s Ending point '%s' out of range%s\n", INVT, LOUD, to, INIT)
-			os.Exit(1)
-		}
+	// complement, join, order, partials, and between-base sites use full location grammar
+	loc, ok := ParseLocation(featLoc)
+	if !ok {
+		os.Exit(1)
+	}
 
-		if !isOneBased {
-			min++
-			max++
+	if !isOneBased {
+		for i := range loc.Intervals {
+			loc.Intervals[i].From++
+			loc.Intervals[i].To++
 		}
+	}
 
-		if min < max {
-			min--
-			sub := seq[min:max]
-			buffer.WriteString(sub)
-		} else if min > max {
-			max--
-			sub := seq[max:min]
-			sub = ReverseComplement(sub)
-			buffer.WriteString(sub)
-		} else {
-			// need more information to know strand if single point
-		}
+	str, ok := loc.Extract(seq)
+	if !ok {
+		os.Exit(1)
 	}
 
-	return buffer.String()
+	return str
 }
 
 // ReverseComplement returns the reverse complement of a sequence
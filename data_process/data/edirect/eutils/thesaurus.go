@@ -0,0 +1,297 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  thesaurus.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// MESH THESAURUS FOR QUERY-TIME SYNONYM AND HIERARCHY EXPANSION
+
+// thesaurus file in the postings directory, e.g., Postings/thesaurus.txt, has
+// one descriptor per line, with tab-delimited UI, preferred name, tree numbers,
+// and entry terms, the last two separated by vertical bars
+
+const thesaurusFile = "thesaurus.txt"
+
+// thesaurus maps entry terms to descriptors and descriptors to tree numbers
+type thesaurus struct {
+	names map[string]string
+	trees map[string][]string
+	terms map[string][]string
+	// normalized entry term, preferred name, or UI to descriptor UIs
+	entry map[string][]string
+}
+
+// thesaurusKey lowercases a term and collapses punctuation, so "Vitamin-C" matches "vitamin c"
+func thesaurusKey(str string) string {
+
+	str = strings.Map(func(ch rune) rune {
+		if unicode.IsLetter(ch) || unicode.IsDigit(ch) {
+			return unicode.ToLower(ch)
+		}
+		return ' '
+	}, str)
+
+	return strings.Join(strings.Fields(str), " ")
+}
+
+// thesaurusRecord extracts the UI, name, tree numbers, and entry terms from a DescriptorRecord
+func thesaurusRecord(text string) string {
+
+	pat := ParseRecord(text, "DescriptorRecord")
+	if pat == nil {
+		return ""
+	}
+
+	// first instance only, later DescriptorUI and DescriptorName elements refer to other records
+	first := func(prnt, match string) string {
+		res := ""
+		ExploreElements(pat, "", prnt, match, "", false, true, 1, func(str string, lvl int) {
+			if res == "" {
+				res = strings.TrimSpace(str)
+			}
+		})
+		return res
+	}
+
+	all := func(prnt, match string) []string {
+		var res []string
+		ExploreElements(pat, "", prnt, match, "", false, true, 1, func(str string, lvl int) {
+			str = strings.TrimSpace(str)
+			// vertical bar separates items in the thesaurus file
+			str = strings.Replace(str, "|", " ", -1)
+			if str != "" {
+				res = append(res, str)
+			}
+		})
+		return res
+	}
+
+	uid := first("", "DescriptorUI")
+	name := first("DescriptorName", "String")
+	if uid == "" || name == "" {
+		return ""
+	}
+
+	trees := all("TreeNumberList", "TreeNumber")
+	terms := all("Term", "String")
+
+	return uid + "\t" + name + "\t" + strings.Join(trees, "|") + "\t" + strings.Join(terms, "|")
+}
+
+// WriteThesaurus saves descriptor names, tree numbers, and entry terms from MeSH descriptor XML records
+func WriteThesaurus(prom string, inp <-chan XMLRecord) bool {
+
+	if inp == nil {
+		return false
+	}
+
+	var lines []string
+
+	for ext := range inp {
+		str := thesaurusRecord(ext.Text[:])
+		if str != "" {
+			lines = append(lines, str)
+		}
+	}
+
+	if len(lines) < 1 {
+		fmt.Fprintf(os.Stderr, "\nERROR: No DescriptorRecord elements found for thesaurus\n")
+		return false
+	}
+
+	sort.Strings(lines)
+
+	err := os.MkdirAll(prom, os.ModePerm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return false
+	}
+
+	err = os.WriteFile(path.Join(prom, thesaurusFile), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		return false
+	}
+
+	thesLock.Lock()
+	delete(thesCache, prom)
+	thesLock.Unlock()
+
+	return true
+}
+
+var (
+	thesLock  sync.Mutex
+	thesCache = make(map[string]*thesaurus)
+)
+
+// readThesaurus loads and caches the thesaurus for a postings directory, returning nil if it was never built
+func readThesaurus(prom string) *thesaurus {
+
+	thesLock.Lock()
+	defer thesLock.Unlock()
+
+	if th, ok := thesCache[prom]; ok {
+		return th
+	}
+
+	fpath := path.Join(prom, thesaurusFile)
+
+	fl, err := os.Open(fpath)
+	if err != nil {
+		thesCache[prom] = nil
+		return nil
+	}
+	defer fl.Close()
+
+	th := &thesaurus{
+		names: make(map[string]string),
+		trees: make(map[string][]string),
+		terms: make(map[string][]string),
+		entry: make(map[string][]string),
+	}
+
+	addEntry := func(str, uid string) {
+		key := thesaurusKey(str)
+		if key == "" {
+			return
+		}
+		for _, prev := range th.entry[key] {
+			if prev == uid {
+				return
+			}
+		}
+		th.entry[key] = append(th.entry[key], uid)
+	}
+
+	split := func(str string) []string {
+		if str == "" {
+			return nil
+		}
+		return strings.Split(str, "|")
+	}
+
+	scanr := bufio.NewScanner(fl)
+	scanr.Buffer(make([]byte, 0, 65536), 1048576)
+
+	for scanr.Scan() {
+		cols := strings.Split(scanr.Text(), "\t")
+		if len(cols) != 4 {
+			fmt.Fprintf(os.Stderr, "\nERROR: Damaged thesaurus '%s'\n", fpath)
+			thesCache[prom] = nil
+			return nil
+		}
+		uid, name := cols[0], cols[1]
+		th.names[uid] = name
+		th.trees[uid] = split(cols[2])
+		th.terms[uid] = split(cols[3])
+		addEntry(uid, uid)
+		addEntry(name, uid)
+		for _, term := range th.terms[uid] {
+			addEntry(term, uid)
+		}
+	}
+
+	thesCache[prom] = th
+
+	return th
+}
+
+// expandThesaurusTerm rewrites an [EXPN] phrase into the OR of the synonyms of each matching
+// descriptor, searched in the default field, and its tree numbers with all descendants
+func expandThesaurusTerm(str string, node *queryNode, rlxd, deStop bool, schema *IndexSchema) *QueryError {
+
+	if node.Fuzzy != 0 {
+		return &QueryError{str, node.Pos, "Qualifier '~fuzzy' cannot be used with [EXPN]"}
+	}
+
+	th := readThesaurus(schema.prom)
+	if th == nil {
+		return &QueryError{str, node.FieldPos, "Field [EXPN] requires a thesaurus, created with rchive -thesaurus"}
+	}
+
+	txt := strings.TrimSpace(strings.TrimSuffix(node.Text, "[EXPN]"))
+
+	uids := th.entry[thesaurusKey(txt)]
+	if len(uids) < 1 {
+		// not a MeSH term, search words as entered
+		node.Text = txt
+		node.Field = ""
+		return normalizeQueryTree(str, node, rlxd, deStop, schema)
+	}
+
+	useTree := schema.hasPostings("TREE")
+
+	var kids []*queryNode
+	seen := make(map[string]bool)
+
+	addPhrase := func(text, field string) *QueryError {
+		if text == "" {
+			return nil
+		}
+		chld := &queryNode{Kind: qryPhrase, Text: text, Pos: node.Pos}
+		if field != "" {
+			chld.Text += " [" + field + "]"
+			chld.Field = field
+		}
+		if qerr := normalizeQueryTree(str, chld, rlxd, deStop, schema); qerr != nil {
+			return qerr
+		}
+		if chld.Clause == "" || seen[chld.Clause] {
+			return nil
+		}
+		seen[chld.Clause] = true
+		kids = append(kids, chld)
+		return nil
+	}
+
+	for _, uid := range uids {
+		// strip parentheses, ampersands, and other operator characters from MeSH names and entry terms
+		if qerr := addPhrase(thesaurusKey(th.names[uid]), ""); qerr != nil {
+			return qerr
+		}
+		for _, term := range th.terms[uid] {
+			if qerr := addPhrase(thesaurusKey(term), ""); qerr != nil {
+				return qerr
+			}
+		}
+		if !useTree {
+			continue
+		}
+		// trailing wildcard explodes each tree number to include narrower descriptors
+		for _, tree := range th.trees[uid] {
+			if qerr := addPhrase(strings.ToLower(tree)+"*", "TREE"); qerr != nil {
+				return qerr
+			}
+		}
+	}
+
+	node.Kind = qryOrNode
+	node.Children = kids
+
+	return nil
+}
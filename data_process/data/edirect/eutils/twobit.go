@@ -0,0 +1,291 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  twobit.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// The .2bit layout is described at https://genome.ucsc.edu/FAQ/FAQformat.html#format7
+
+const twoBitSignature = 0x1A412743
+
+// TwoBitFile provides random access to sequences in a UCSC .2bit file
+type TwoBitFile struct {
+	file    *os.File
+	order   binary.ByteOrder
+	is64    bool
+	names   []string
+	offsets map[string]int64
+	// parsed sequence headers, so Length and Fetch read each one once
+	records map[string]*twoBitRecord
+}
+
+// twoBitRecord holds the header of one sequence, with block coordinates 0-based
+type twoBitRecord struct {
+	length     int
+	nStarts    []uint32
+	nSizes     []uint32
+	maskStarts []uint32
+	maskSizes  []uint32
+	dnaOffset  int64
+}
+
+// OpenTwoBit reads the header and sequence index of a .2bit file
+func OpenTwoBit(fname string) *TwoBitFile {
+
+	fl, err := os.Open(fname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Unable to open '%s'%s\n", INVT, LOUD, fname, INIT)
+		return nil
+	}
+
+	tb := &TwoBitFile{file: fl, offsets: make(map[string]int64), records: make(map[string]*twoBitRecord)}
+
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(fl, hdr); err != nil {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Truncated .2bit header in '%s'%s\n", INVT, LOUD, fname, INIT)
+		fl.Close()
+		return nil
+	}
+
+	// signature determines byte order
+	switch {
+	case binary.LittleEndian.Uint32(hdr) == twoBitSignature:
+		tb.order = binary.LittleEndian
+	case binary.BigEndian.Uint32(hdr) == twoBitSignature:
+		tb.order = binary.BigEndian
+	default:
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s '%s' is not a .2bit file%s\n", INVT, LOUD, fname, INIT)
+		fl.Close()
+		return nil
+	}
+
+	// version 1 uses 64-bit sequence offsets
+	tb.is64 = (tb.order.Uint32(hdr[4:]) == 1)
+	count := int(tb.order.Uint32(hdr[8:]))
+
+	var pos int64 = 16
+	size := make([]byte, 1)
+	word := make([]byte, 8)
+
+	for i := 0; i < count; i++ {
+		if _, err := fl.ReadAt(size, pos); err != nil {
+			break
+		}
+		pos++
+		name := make([]byte, int(size[0]))
+		if _, err := fl.ReadAt(name, pos); err != nil {
+			break
+		}
+		pos += int64(len(name))
+
+		var offset int64
+		if tb.is64 {
+			if _, err := fl.ReadAt(word, pos); err != nil {
+				break
+			}
+			offset = int64(tb.order.Uint64(word))
+			pos += 8
+		} else {
+			if _, err := fl.ReadAt(word[:4], pos); err != nil {
+				break
+			}
+			offset = int64(tb.order.Uint32(word))
+			pos += 4
+		}
+
+		tb.names = append(tb.names, string(name))
+		tb.offsets[string(name)] = offset
+	}
+
+	if len(tb.names) != count {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Truncated .2bit index in '%s'%s\n", INVT, LOUD, fname, INIT)
+		fl.Close()
+		return nil
+	}
+
+	return tb
+}
+
+// Close releases the underlying file
+func (tb *TwoBitFile) Close() {
+
+	if tb != nil && tb.file != nil {
+		tb.file.Close()
+	}
+}
+
+// Names returns sequence names in file order
+func (tb *TwoBitFile) Names() []string {
+
+	return tb.names
+}
+
+func (tb *TwoBitFile) readRecord(name string) *twoBitRecord {
+
+	if rec, ok := tb.records[name]; ok {
+		return rec
+	}
+
+	offset, ok := tb.offsets[name]
+	if !ok {
+		return nil
+	}
+
+	// read block counts, then each pair of block arrays in one call
+	readBytes := func(num int) []byte {
+		buf := make([]byte, num)
+		if _, err := tb.file.ReadAt(buf, offset); err != nil {
+			fmt.Fprintf(os.Stderr, "%s ERROR: %s Truncated .2bit record '%s'%s\n", INVT, LOUD, name, INIT)
+			return nil
+		}
+		offset += int64(num)
+		return buf
+	}
+
+	readArrays := func(num int) ([]uint32, []uint32, bool) {
+		buf := readBytes(num * 8)
+		if buf == nil {
+			return nil, nil, false
+		}
+		starts := make([]uint32, num)
+		sizes := make([]uint32, num)
+		for i := 0; i < num; i++ {
+			starts[i] = tb.order.Uint32(buf[i*4:])
+			sizes[i] = tb.order.Uint32(buf[(num+i)*4:])
+		}
+		return starts, sizes, true
+	}
+
+	rec := &twoBitRecord{}
+
+	hdr := readBytes(8)
+	if hdr == nil {
+		return nil
+	}
+	rec.length = int(tb.order.Uint32(hdr))
+	nCount := int(tb.order.Uint32(hdr[4:]))
+
+	if rec.nStarts, rec.nSizes, ok = readArrays(nCount); !ok {
+		return nil
+	}
+
+	cnt := readBytes(4)
+	if cnt == nil {
+		return nil
+	}
+	maskCount := int(tb.order.Uint32(cnt))
+
+	if rec.maskStarts, rec.maskSizes, ok = readArrays(maskCount); !ok {
+		return nil
+	}
+
+	// skip reserved word
+	offset += 4
+	rec.dnaOffset = offset
+
+	tb.records[name] = rec
+
+	return rec
+}
+
+// Length returns the number of bases in a named sequence, or -1 if not present
+func (tb *TwoBitFile) Length(name string) int {
+
+	rec := tb.readRecord(name)
+	if rec == nil {
+		return -1
+	}
+
+	return rec.length
+}
+
+// Fetch returns bases from..to (1-based, inclusive), with N blocks restored and soft-masked regions
+// in lower case, reading only the packed bytes that cover the region
+func (tb *TwoBitFile) Fetch(name string, from, to int) string {
+
+	rec := tb.readRecord(name)
+	if rec == nil {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Sequence '%s' not found in .2bit file%s\n", INVT, LOUD, name, INIT)
+		return ""
+	}
+
+	if from > rec.length || to > rec.length {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Region %d-%d is outside sequence '%s' of length %d%s\n", INVT, LOUD, from, to, name, rec.length, INIT)
+		return ""
+	}
+
+	if from < 1 {
+		from = 1
+	}
+	if to < 1 {
+		to = rec.length
+	}
+	if from > to {
+		return ""
+	}
+
+	start := from - 1
+	stop := to
+
+	// four bases per byte, high-order bits first, T=0, C=1, A=2, G=3
+	first := start / 4
+	last := (stop - 1) / 4
+	packed := make([]byte, last-first+1)
+	if _, err := tb.file.ReadAt(packed, rec.dnaOffset+int64(first)); err != nil && err != io.EOF {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s %s%s\n", INVT, LOUD, err.Error(), INIT)
+		return ""
+	}
+
+	const bases = "TCAG"
+
+	seq := make([]byte, stop-start)
+	for i := start; i < stop; i++ {
+		byt := packed[i/4-first]
+		shift := uint(6 - 2*(i%4))
+		seq[i-start] = bases[(byt>>shift)&3]
+	}
+
+	// apply blocks that overlap the requested region
+	applyBlocks := func(starts, sizes []uint32, proc func(int)) {
+		idx := sort.Search(len(starts), func(j int) bool { return int(starts[j])+int(sizes[j]) > start })
+		for ; idx < len(starts) && int(starts[idx]) < stop; idx++ {
+			bs := int(starts[idx])
+			be := bs + int(sizes[idx])
+			if bs < start {
+				bs = start
+			}
+			if be > stop {
+				be = stop
+			}
+			for k := bs; k < be; k++ {
+				proc(k - start)
+			}
+		}
+	}
+
+	applyBlocks(rec.nStarts, rec.nSizes, func(k int) { seq[k] = 'N' })
+	applyBlocks(rec.maskStarts, rec.maskSizes, func(k int) { seq[k] = seq[k] | 0x20 })
+
+	return string(seq)
+}
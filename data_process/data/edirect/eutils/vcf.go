@@ -0,0 +1,222 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  vcf.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Left-normalization follows Adrian Tan, Goncalo Abecasis, and Hyun Min Kang,
+// Unified Representation of Genetic Variants, Bioinformatics 31(13), 2015, p. 2202.
+
+// VCFRecord contains the fixed fields of a VCF 4.x data line with a single alternate allele
+type VCFRecord struct {
+	Chrom string
+	Pos   int
+	ID    string
+	Ref   string
+	Alt   string
+}
+
+// ParseSPDI splits "NC_000017.11:43094692:G:A" into accession, 0-based position, deleted
+// sequence or count, and inserted sequence
+func ParseSPDI(str string) (string, int, string, string, bool) {
+
+	str = strings.TrimSpace(str)
+
+	flds := strings.Split(str, ":")
+	if len(flds) != 4 {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Unrecognized SPDI '%s'%s\n", INVT, LOUD, str, INIT)
+		return "", 0, "", "", false
+	}
+
+	pos, err := strconv.Atoi(flds[1])
+	if err != nil || pos < 0 {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Unrecognized SPDI position '%s'%s\n", INVT, LOUD, flds[1], INIT)
+		return "", 0, "", "", false
+	}
+
+	return flds[0], pos, strings.ToUpper(flds[2]), strings.ToUpper(flds[3]), true
+}
+
+// NormalizeVariant trims bases shared by deleted and inserted sequences, then shifts
+// insertions and deletions to their leftmost equivalent position on the reference.
+// It returns the minimal 0-based position, deleted, and inserted sequences, with no
+// anchor base. A deletion given as a count is replaced by the reference sequence.
+func NormalizeVariant(seq string, pos int, del, ins string) (int, string, string, bool) {
+
+	seq = strings.ToUpper(seq)
+	del = strings.ToUpper(del)
+	ins = strings.ToUpper(ins)
+
+	ln := len(seq)
+
+	if IsAllDigits(del) && del != "" {
+		num, _ := strconv.Atoi(del)
+		if seq == "" || pos+num > ln {
+			fmt.Fprintf(os.Stderr, "%s ERROR: %s Deletion length %d at %d needs reference sequence%s\n", INVT, LOUD, num, pos, INIT)
+			return 0, "", "", false
+		}
+		del = seq[pos : pos+num]
+	}
+
+	if seq != "" {
+		if pos+len(del) > ln {
+			fmt.Fprintf(os.Stderr, "%s ERROR: %s Variant position %d is past end of reference length %d%s\n", INVT, LOUD, pos, ln, INIT)
+			return 0, "", "", false
+		}
+		ext := seq[pos : pos+len(del)]
+		if ext != del {
+			fmt.Fprintf(os.Stderr, "%s WARNING: %s Deleted sequence %s does not match reference %s at %d%s\n", INVT, LOUD, del, ext, pos, INIT)
+			// as in SequenceReplace, the reference bases are what actually get removed
+			del = ext
+		}
+	}
+
+	// trim common suffix
+	for len(del) > 0 && len(ins) > 0 && del[len(del)-1] == ins[len(ins)-1] {
+		del = del[:len(del)-1]
+		ins = ins[:len(ins)-1]
+	}
+
+	// trim common prefix
+	for len(del) > 0 && len(ins) > 0 && del[0] == ins[0] {
+		del = del[1:]
+		ins = ins[1:]
+		pos++
+	}
+
+	if seq == "" || (del != "" && ins != "") {
+		// substitution or complex change cannot be shifted
+		return pos, del, ins, true
+	}
+
+	// rotate pure insertion or deletion leftward while the preceding base repeats its last base
+	for pos > 0 {
+		prev := seq[pos-1]
+		if del != "" && del[len(del)-1] == prev {
+			del = string(prev) + del[:len(del)-1]
+		} else if ins != "" && ins[len(ins)-1] == prev {
+			ins = string(prev) + ins[:len(ins)-1]
+		} else {
+			break
+		}
+		pos--
+	}
+
+	return pos, del, ins, true
+}
+
+// SPDIToVCF converts a variant to a left-normalized VCF record, adding the preceding
+// reference base as anchor for insertions and deletions, or the following base at the
+// start of the sequence
+func SPDIToVCF(seq, acc string, pos int, del, ins string) (VCFRecord, bool) {
+
+	if seq == "" {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Reference sequence required for %s%s\n", INVT, LOUD, acc, INIT)
+		return VCFRecord{}, false
+	}
+
+	seq = strings.ToUpper(seq)
+
+	pos, del, ins, ok := NormalizeVariant(seq, pos, del, ins)
+	if !ok {
+		return VCFRecord{}, false
+	}
+
+	if del == "" && ins == "" {
+		// no change from reference
+		return VCFRecord{}, false
+	}
+
+	if del == "" || ins == "" {
+		if pos > 0 {
+			anchor := string(seq[pos-1])
+			del = anchor + del
+			ins = anchor + ins
+			pos--
+		} else {
+			nxt := pos + len(del)
+			if nxt >= len(seq) {
+				fmt.Fprintf(os.Stderr, "%s ERROR: %s No anchor base for variant on %s%s\n", INVT, LOUD, acc, INIT)
+				return VCFRecord{}, false
+			}
+			anchor := string(seq[nxt])
+			del += anchor
+			ins += anchor
+		}
+	}
+
+	return VCFRecord{Chrom: acc, Pos: pos + 1, ID: ".", Ref: del, Alt: ins}, true
+}
+
+// VCFToSPDI converts one alternate allele of a VCF record to a minimal SPDI, shifting
+// left if the reference sequence is available
+func VCFToSPDI(seq string, rec VCFRecord) (string, bool) {
+
+	alt := strings.ToUpper(rec.Alt)
+
+	// symbolic, breakend, and overlapping deletion alleles have no SPDI equivalent
+	if alt == "" || alt == "." || alt == "*" || strings.ContainsAny(alt, "<>[]") {
+		return "", false
+	}
+
+	if rec.Pos < 1 {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Unrecognized VCF position %d%s\n", INVT, LOUD, rec.Pos, INIT)
+		return "", false
+	}
+
+	pos, del, ins, ok := NormalizeVariant(seq, rec.Pos-1, rec.Ref, alt)
+	if !ok {
+		return "", false
+	}
+
+	return rec.Chrom + ":" + strconv.Itoa(pos) + ":" + del + ":" + ins, true
+}
+
+// ParseVCFLine splits a VCF data line into one record per alternate allele
+func ParseVCFLine(line string) []VCFRecord {
+
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+
+	cols := strings.Split(line, "\t")
+	if len(cols) < 5 {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Too few VCF columns in '%s'%s\n", INVT, LOUD, line, INIT)
+		return nil
+	}
+
+	pos, err := strconv.Atoi(cols[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s ERROR: %s Unrecognized VCF position '%s'%s\n", INVT, LOUD, cols[1], INIT)
+		return nil
+	}
+
+	var arry []VCFRecord
+
+	for _, alt := range strings.Split(cols[4], ",") {
+		rec := VCFRecord{Chrom: cols[0], Pos: pos, ID: cols[2], Ref: strings.ToUpper(cols[3]), Alt: alt}
+		arry = append(arry, rec)
+	}
+
+	return arry
+}
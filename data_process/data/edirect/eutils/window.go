@@ -0,0 +1,452 @@
+// ===========================================================================
+//
+//                              NOTICE
+//
+//  This file is not part of the NCBI EDirect distribution and is not a
+//  "United States Government Work". It was contributed to this repository's
+//  copy of EDirect and is distributed under the same terms as the rest of
+//  this repository. It is provided "as is", without warranty of any kind.
+//
+// ===========================================================================
+//
+// File Name:  window.go
+//
+// Author:  drug_fpn_lstm_vqa contributors
+//
+// ==========================================================================
+
+package eutils
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// CpG observed/expected is from Gardiner-Garden M, Frommer M. J Mol Biol. 1987 Jul 20;196(2):261-82,
+// hydropathy from Kyte J, Doolittle RF. J Mol Biol. 1982 May 5;157(1):105-32, DUST from Morgulis A,
+// et al. J Comput Biol. 2006;13(5):1028-40, and SEG from Wootton JC, Federhen S. Comput Chem. 1993;17(2):149-63
+
+// WindowStat holds composition values for one window, with 1-based inclusive Start and End
+type WindowStat struct {
+	Start      int
+	End        int
+	GC         float64
+	GCSkew     float64
+	CpGRatio   float64
+	Hydropathy float64
+}
+
+var kyteDoolittle = map[byte]float64{
+	'A': 1.8,
+	'R': -4.5,
+	'N': -3.5,
+	'D': -3.5,
+	'C': 2.5,
+	'Q': -3.5,
+	'E': -3.5,
+	'G': -0.4,
+	'H': -3.2,
+	'I': 4.5,
+	'L': 3.8,
+	'K': -3.9,
+	'M': 1.9,
+	'F': 2.8,
+	'P': -1.6,
+	'S': -0.8,
+	'T': -0.7,
+	'W': -0.9,
+	'Y': -1.3,
+	'V': 4.2,
+}
+
+// windowStarts returns 0-based window offsets, adding a final window aligned to the end of the sequence
+func windowStarts(length, size, step int) []int {
+
+	if size < 1 || step < 1 || length < 1 {
+		return nil
+	}
+
+	if size > length {
+		return []int{0}
+	}
+
+	var arry []int
+	last := 0
+	for pos := 0; pos+size <= length; pos += step {
+		arry = append(arry, pos)
+		last = pos
+	}
+	if last+size < length {
+		arry = append(arry, length-size)
+	}
+
+	return arry
+}
+
+// NucleotideWindows calculates GC fraction, GC skew, and CpG observed/expected ratio in each window
+func NucleotideWindows(seq string, size, step int) []WindowStat {
+
+	seq = strings.ToUpper(seq)
+
+	var stats []WindowStat
+
+	for _, pos := range windowStarts(len(seq), size, step) {
+
+		end := pos + size
+		if end > len(seq) {
+			end = len(seq)
+		}
+
+		a, c, g, t, cpg := 0, 0, 0, 0, 0
+		for i := pos; i < end; i++ {
+			switch seq[i] {
+			case 'A':
+				a++
+			case 'C':
+				c++
+				if i+1 < end && seq[i+1] == 'G' {
+					cpg++
+				}
+			case 'G':
+				g++
+			case 'T', 'U':
+				t++
+			}
+		}
+
+		st := WindowStat{Start: pos + 1, End: end}
+
+		// ambiguous bases are excluded from the denominators
+		if total := a + c + g + t; total > 0 {
+			st.GC = float64(g+c) / float64(total)
+			if c > 0 && g > 0 {
+				st.CpGRatio = float64(cpg) * float64(total) / (float64(c) * float64(g))
+			}
+		}
+		if g+c > 0 {
+			st.GCSkew = float64(g-c) / float64(g+c)
+		}
+
+		stats = append(stats, st)
+	}
+
+	return stats
+}
+
+// HydropathyWindows calculates the mean Kyte-Doolittle hydropathy of each window, ignoring unknown residues
+func HydropathyWindows(seq string, size, step int) []WindowStat {
+
+	seq = strings.ToUpper(seq)
+
+	var stats []WindowStat
+
+	for _, pos := range windowStarts(len(seq), size, step) {
+
+		end := pos + size
+		if end > len(seq) {
+			end = len(seq)
+		}
+
+		sum := 0.0
+		num := 0
+		for i := pos; i < end; i++ {
+			if val, ok := kyteDoolittle[seq[i]]; ok {
+				sum += val
+				num++
+			}
+		}
+
+		st := WindowStat{Start: pos + 1, End: end}
+		if num > 0 {
+			st.Hydropathy = sum / float64(num)
+		}
+
+		stats = append(stats, st)
+	}
+
+	return stats
+}
+
+// mergeMaskIntervals sorts and joins overlapping or abutting 0-based half-open intervals
+func mergeMaskIntervals(ivals [][2]int) [][2]int {
+
+	var merged [][2]int
+
+	sort.Slice(ivals, func(i, j int) bool { return ivals[i][0] < ivals[j][0] })
+
+	for _, iv := range ivals {
+		last := len(merged) - 1
+		if last >= 0 && iv[0] <= merged[last][1] {
+			if iv[1] > merged[last][1] {
+				merged[last][1] = iv[1]
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+
+	return merged
+}
+
+// dustInterval is a candidate masked region, with its triplet pair count r and length l in triplets minus one
+type dustInterval struct {
+	start  int
+	finish int
+	r      int
+	l      int
+}
+
+// DustIntervals finds low-complexity nucleotide regions with the symmetric DUST algorithm used by
+// dustmasker, scoring intervals by triplet repetition, ten times the sum of c(c-1)/2 over triplet
+// counts divided by (triplets - 1), and masking intervals within each window that score above the
+// threshold (default level 20) and at least as high as any interval they contain. Triplet counts
+// are updated incrementally as the window slides, and ambiguous bases separate independent pieces.
+func DustIntervals(seq string, window int, threshold float64) [][2]int {
+
+	if window < 4 {
+		window = 64
+	}
+
+	var (
+		// triplet codes in current window
+		wnd []int
+		// counts and pair sums for whole window, and for suffix with no triplet above half the threshold
+		cw, cv [64]int
+		rw, rv int
+		sfx    int
+		// candidate intervals in current window, in order of decreasing start
+		perf  []dustInterval
+		ivals [][2]int
+	)
+
+	// shiftWindow adds the next triplet, dropping the oldest once the window is full
+	shiftWindow := func(t int) {
+
+		if len(wnd) >= window-2 {
+			s := wnd[0]
+			wnd = wnd[1:]
+			cw[s]--
+			rw -= cw[s]
+			if sfx > len(wnd) {
+				sfx--
+				cv[s]--
+				rv -= cv[s]
+			}
+		}
+
+		wnd = append(wnd, t)
+		sfx++
+		rw += cw[t]
+		cw[t]++
+		rv += cv[t]
+		cv[t]++
+
+		// shrink suffix until the new triplet is no longer overrepresented
+		if float64(cv[t]*10) > threshold*2 {
+			for {
+				s := wnd[len(wnd)-sfx]
+				cv[s]--
+				rv -= cv[s]
+				sfx--
+				if s == t {
+					break
+				}
+			}
+		}
+	}
+
+	// saveMasked records the best candidate that starts before the window, then drops candidates that left the window
+	saveMasked := func(start int) {
+
+		last := len(perf) - 1
+		if last < 0 || perf[last].start >= start {
+			return
+		}
+
+		p := perf[last]
+		if n := len(ivals) - 1; n >= 0 && p.start <= ivals[n][1] {
+			if p.finish > ivals[n][1] {
+				ivals[n][1] = p.finish
+			}
+		} else {
+			ivals = append(ivals, [2]int{p.start, p.finish})
+		}
+
+		for last >= 0 && perf[last].start < start {
+			last--
+		}
+		perf = perf[:last+1]
+	}
+
+	// findPerfect extends the suffix leftward, keeping intervals that score at least as high as those they contain
+	findPerfect := func(start int) {
+
+		c := cv
+		r := rv
+		maxR, maxL := 0, 0
+
+		// best score among candidates contained in the current interval, which only grows as it extends left
+		j := 0
+
+		for i := len(wnd) - sfx - 1; i >= 0; i-- {
+			t := wnd[i]
+			r += c[t]
+			c[t]++
+			newR, newL := r, len(wnd)-i-1
+			if float64(newR*10) <= threshold*float64(newL) {
+				continue
+			}
+			for ; j < len(perf) && perf[j].start >= i+start; j++ {
+				p := perf[j]
+				if maxR == 0 || p.r*maxL > maxR*p.l {
+					maxR, maxL = p.r, p.l
+				}
+			}
+			if maxR != 0 && newR*maxL < maxR*newL {
+				continue
+			}
+			maxR, maxL = newR, newL
+			intv := dustInterval{start: i + start, finish: len(wnd) + 2 + start, r: newR, l: newL}
+			if j > 0 && perf[j-1].start == intv.start {
+				// longer interval from the same start scores at least as high, so it replaces the shorter one
+				perf[j-1] = intv
+				continue
+			}
+			perf = append(perf, dustInterval{})
+			copy(perf[j+1:], perf[j:])
+			perf[j] = intv
+			j++
+		}
+	}
+
+	// length of current unambiguous piece and rolling triplet code
+	ln, t := 0, 0
+
+	for i := 0; i <= len(seq); i++ {
+
+		b := 4
+		if i < len(seq) {
+			switch seq[i] {
+			case 'A', 'a':
+				b = 0
+			case 'C', 'c':
+				b = 1
+			case 'G', 'g':
+				b = 2
+			case 'T', 't', 'U', 'u':
+				b = 3
+			}
+		}
+
+		if b < 4 {
+			ln++
+			t = (t<<2 | b) & 63
+			if ln < 3 {
+				continue
+			}
+			start := i + 1 - ln
+			if ln > window {
+				start += ln - window
+			}
+			saveMasked(start)
+			shiftWindow(t)
+			if float64(rw*10) > float64(sfx)*threshold {
+				findPerfect(start)
+			}
+			continue
+		}
+
+		// ambiguous base or end of sequence, flush remaining candidates and start a new piece
+		start := i + 1 - ln
+		if ln > window-1 {
+			start += ln - window + 1
+		}
+		for len(perf) > 0 {
+			saveMasked(start)
+			start++
+		}
+
+		wnd = wnd[:0]
+		cw, cv = [64]int{}, [64]int{}
+		rw, rv, sfx = 0, 0, 0
+		ln, t = 0, 0
+	}
+
+	return mergeMaskIntervals(ivals)
+}
+
+// SegIntervals finds low-complexity protein regions, seeding at windows whose Shannon entropy in bits
+// is at or below locut and extending over adjacent windows at or below hicut
+func SegIntervals(seq string, window int, locut, hicut float64) [][2]int {
+
+	seq = strings.ToUpper(seq)
+
+	if window < 2 {
+		window = 12
+	}
+
+	starts := windowStarts(len(seq), window, 1)
+	entropy := make([]float64, len(starts))
+
+	for k, pos := range starts {
+		end := pos + window
+		if end > len(seq) {
+			end = len(seq)
+		}
+		var counts [256]int
+		for i := pos; i < end; i++ {
+			counts[seq[i]]++
+		}
+		size := float64(end - pos)
+		for _, cnt := range counts {
+			if cnt > 0 {
+				frac := float64(cnt) / size
+				entropy[k] -= frac * math.Log2(frac)
+			}
+		}
+	}
+
+	var ivals [][2]int
+
+	for k := 0; k < len(starts); k++ {
+		if entropy[k] > locut {
+			continue
+		}
+		// extend trigger window in both directions while complexity remains low
+		lft := k
+		for lft > 0 && entropy[lft-1] <= hicut {
+			lft--
+		}
+		rgt := k
+		for rgt+1 < len(starts) && entropy[rgt+1] <= hicut {
+			rgt++
+		}
+		end := starts[rgt] + window
+		if end > len(seq) {
+			end = len(seq)
+		}
+		ivals = append(ivals, [2]int{starts[lft], end})
+		k = rgt
+	}
+
+	return mergeMaskIntervals(ivals)
+}
+
+// MaskIntervals lower-cases masked regions, or replaces them with the given letter, e.g., N or X
+func MaskIntervals(seq string, ivals [][2]int, letter byte) string {
+
+	buf := []byte(seq)
+
+	for _, iv := range ivals {
+		for i := iv[0]; i < iv[1] && i < len(buf); i++ {
+			if letter != 0 {
+				buf[i] = letter
+			} else if buf[i] >= 'A' && buf[i] <= 'Z' {
+				buf[i] += 'a' - 'A'
+			}
+		}
+	}
+
+	return string(buf)
+}